@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // 内置时区数据，保证精简镜像中也能解析任务配置的时区
 
 	"fsvchart-notify/internal/config"
 	"fsvchart-notify/internal/database"
+	"fsvchart-notify/internal/render"
 	"fsvchart-notify/internal/scheduler"
 	"fsvchart-notify/internal/server"
 	"fsvchart-notify/internal/service"
@@ -63,21 +70,53 @@ func main() {
 	// 初始化认证配置
 	service.InitAuth(&cfg.Auth)
 
+	// 加载图表图片使用的字体
+	if err := render.LoadFont(cfg.Render.FontPath); err != nil {
+		log.Fatalf("LoadFont error: %v", err)
+	}
+
 	// 初始化数据库
 	_, err = database.InitDB(*dbPath)
 	if err != nil {
 		log.Fatalf("InitDB error: %v", err)
 	}
+	db := database.GetDB()
+	if db == nil {
+		log.Fatalf("GetDB error: database not available")
+	}
+
+	// 收到 SIGINT/SIGTERM 时优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// 启动定时任务
-	scheduler.StartScheduler()
+	sched := scheduler.New(db)
+	sched.Start(ctx)
+
+	// 定期清理过期的发送记录
+	service.StartSendRecordPurge(ctx, cfg.SendRecord.RetentionDays)
 
 	// 启动 Gin + Statik HTTP 服务
-	srv := server.NewServer(cfg.Server.Address, cfg.Server.Port)
+	srv := server.NewServer(cfg.Server.Address, cfg.Server.Port, sched)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.Server.Port)
 	log.Printf("fsvchart-notify %s running on %s", getVersion(), addr)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Server error: %v", err)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
 	}
+
+	// 等待正在执行的推送任务完成
+	sched.Stop()
+	log.Println("fsvchart-notify stopped")
 }
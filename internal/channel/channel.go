@@ -0,0 +1,191 @@
+// Package channel 封装不同通知渠道（飞书、钉钉等）的消息渲染和发送
+package channel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// 通知渠道类型
+const (
+	TypeFeishu    = "feishu"
+	TypeFeishuApp = "feishu_app"
+	TypeDingTalk  = "dingtalk"
+	TypeWeCom     = "wecom"
+	TypeSlack     = "slack"
+	TypeEmail     = "email"
+	TypeWebhook   = "webhook"
+	TypeTelegram  = "telegram"
+	TypeTeams     = "teams"
+)
+
+// 报告的展示模式，与飞书卡片类型对应
+const (
+	ModeChart  = "chart"
+	ModeText   = "text"
+	ModeHybrid = "hybrid"
+)
+
+// Report 一次任务执行要发送的内容，与具体渠道无关
+type Report struct {
+	TaskID        int64
+	TaskName      string
+	RunAt         time.Time // 任务执行时间
+	Start         time.Time // 图表查询的时间范围
+	End           time.Time
+	Mode          string // chart/text/hybrid
+	Title         string
+	Template      string          // 飞书卡片标题颜色，其他渠道忽略
+	Unit          string          // 任务级单位，查询未设置单位时使用
+	Buttons       []models.Button // 任务的按钮，链接已按查询时间范围渲染
+	ShowDataLabel bool
+	Location      *time.Location     // 数据时间显示使用的时区，为 nil 时使用 GMT+8
+	Mentions      []models.Mention   // 任务级的 @ 配置，目前只有飞书卡片使用
+	Layout        *models.CardLayout // 任务使用的自定义卡片布局，只有飞书卡片使用，为 nil 时使用默认卡片结构
+	Elements      []service.HybridElement
+}
+
+// Channel 通知渠道
+// 同一份报告在每种渠道只渲染一次，再发送到该渠道的所有接收方
+type Channel interface {
+	// Name 渠道的显示名称
+	Name() string
+	// Render 将报告渲染为渠道的消息体
+	Render(report *Report) (interface{}, error)
+	// Send 发送已渲染的消息体，target 提供接收方地址和签名密钥
+	// 返回实际发送的消息体：飞书开启 chart_image 时图表组件会替换为上传后的图片；发送失败时可能为 nil
+	Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error)
+}
+
+// Validator 由需要校验接收方配置的渠道实现，在保存 WebHook 时调用
+type Validator interface {
+	Validate(target models.FeishuWebhook) error
+}
+
+var (
+	channels   = make(map[string]Channel)
+	channelsMu sync.RWMutex
+)
+
+// Register 注册通知渠道，重复注册会覆盖
+func Register(typ string, ch Channel) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	channels[typ] = ch
+}
+
+// Types 返回已注册的渠道类型
+func Types() []string {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	types := make([]string, 0, len(channels))
+	for typ := range channels {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NormalizeType 校验渠道类型，空字符串视为 feishu
+func NormalizeType(typ string) (string, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if typ == "" {
+		return TypeFeishu, nil
+	}
+	channelsMu.RLock()
+	_, ok := channels[typ]
+	channelsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("无效的渠道类型 %q，可选值: %s", typ, strings.Join(Types(), "、"))
+	}
+	return typ, nil
+}
+
+// Get 返回渠道类型对应的 Channel
+func Get(typ string) (Channel, error) {
+	typ, err := NormalizeType(typ)
+	if err != nil {
+		return nil, err
+	}
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	return channels[typ], nil
+}
+
+// Validate 校验接收方的渠道类型和渠道相关的配置
+func Validate(target models.FeishuWebhook) error {
+	ch, err := Get(target.Type)
+	if err != nil {
+		return err
+	}
+	if v, ok := ch.(Validator); ok {
+		return v.Validate(target)
+	}
+	return nil
+}
+
+// SendTest 向接收方发送一条测试消息，用于检查渠道配置
+func SendTest(ctx context.Context, target models.FeishuWebhook) error {
+	ch, err := Get(target.Type)
+	if err != nil {
+		return err
+	}
+	report := &Report{
+		Mode:     ModeText,
+		Title:    "FSVChart Notify 测试消息",
+		Template: "blue",
+		Elements: []service.HybridElement{{
+			DisplayMode: "text",
+			PromQLName:  "测试",
+			TextMetrics: []service.LatestMetric{{Label: target.Name, Value: 1, Time: time.Now()}},
+		}},
+	}
+	payload, err := ch.Render(report)
+	if err != nil {
+		return err
+	}
+	_, err = ch.Send(ctx, target, report, payload)
+	return err
+}
+
+// location 返回报告的显示时区
+func (r *Report) location() *time.Location {
+	if r.Location != nil {
+		return r.Location
+	}
+	return service.ChinaTimezone
+}
+
+// unitOf 返回元素的单位，图表元素未设置单位时使用任务级单位（与飞书图表卡片一致）
+func (r *Report) unitOf(e service.HybridElement) string {
+	if e.Unit != "" || e.DisplayMode == "text" {
+		return e.Unit
+	}
+	if e.ChartData != nil && e.ChartData.Unit != "" {
+		return e.ChartData.Unit
+	}
+	return r.Unit
+}
+
+// orderedElements 返回排序后的元素副本，顺序与飞书混合卡片一致：文本在前、图表在后，各自按 display_order 和名称排序
+func (r *Report) orderedElements() []service.HybridElement {
+	elements := append([]service.HybridElement(nil), r.Elements...)
+	sort.SliceStable(elements, func(i, j int) bool {
+		ti, tj := elements[i].DisplayMode == "text", elements[j].DisplayMode == "text"
+		if ti != tj {
+			return ti
+		}
+		if elements[i].DisplayOrder != elements[j].DisplayOrder {
+			return elements[i].DisplayOrder < elements[j].DisplayOrder
+		}
+		return elements[i].PromQLName < elements[j].PromQLName
+	})
+	return elements
+}
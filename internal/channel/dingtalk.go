@@ -0,0 +1,94 @@
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+func init() {
+	Register(TypeDingTalk, dingTalk{})
+}
+
+// dingTalk 钉钉自定义机器人
+// 有按钮时发送 ActionCard（多个按钮时竖直排列），否则发送 Markdown 消息；图表转换为 Markdown 表格
+type dingTalk struct{}
+
+func (dingTalk) Name() string { return "钉钉" }
+
+func (dingTalk) Render(report *Report) (interface{}, error) {
+	parts := []string{"### " + report.Title}
+	parts = append(parts, markdownSections(report)...)
+	parts = append(parts, "---", dataTimeLine(report))
+	text := strings.Join(parts, "\n\n")
+
+	switch len(report.Buttons) {
+	case 0:
+	case 1:
+		return map[string]interface{}{
+			"msgtype": "actionCard",
+			"actionCard": map[string]interface{}{
+				"title":       report.Title,
+				"text":        text,
+				"singleTitle": report.Buttons[0].Text,
+				"singleURL":   report.Buttons[0].URL,
+			},
+		}, nil
+	default:
+		btns := make([]map[string]string, 0, len(report.Buttons))
+		for _, b := range report.Buttons {
+			btns = append(btns, map[string]string{"title": b.Text, "actionURL": b.URL})
+		}
+		return map[string]interface{}{
+			"msgtype": "actionCard",
+			"actionCard": map[string]interface{}{
+				"title":          report.Title,
+				"text":           text,
+				"btnOrientation": "0",
+				"btns":           btns,
+			},
+		}, nil
+	}
+	return map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"title": report.Title,
+			"text":  text,
+		},
+	}, nil
+}
+
+func (dingTalk) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	webhookURL := target.URL
+	if target.Secret != "" {
+		webhookURL = signDingTalkURL(webhookURL, target.Secret, time.Now())
+	}
+
+	body, err := postJSON(ctx, webhookURL, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	return payload, checkErrCode("钉钉", body)
+}
+
+// signDingTalkURL 为开启了加签的机器人追加 timestamp 和 sign 参数
+// sign = Base64(HmacSHA256(secret, timestamp + "\n" + secret))
+func signDingTalkURL(webhookURL, secret string, now time.Time) string {
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	sep := "?"
+	if strings.Contains(webhookURL, "?") {
+		sep = "&"
+	}
+	return webhookURL + sep + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+}
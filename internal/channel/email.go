@@ -0,0 +1,338 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+	"fsvchart-notify/internal/service"
+)
+
+func init() {
+	Register(TypeEmail, email{})
+}
+
+// SMTP 连接的安全模式
+const (
+	SMTPSecurityAuto     = "auto"
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// smtpTimeout 单次发送邮件的超时时间
+const smtpTimeout = 60 * time.Second
+
+// emailMessage 渲染后的邮件内容，收件人在发送时根据接收方配置填充
+type emailMessage struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	images  []emailImage
+}
+
+// emailImage 以 CID 方式内嵌在邮件正文中的图表图片
+type emailImage struct {
+	cid  string
+	data []byte
+}
+
+// emailSection 邮件正文中的一个查询
+type emailSection struct {
+	Heading string
+	Link    string       // 查询的详情链接
+	Rows    []emailRow   // 文本模式的最新值
+	Image   template.URL // 图表图片的 cid 地址
+	Note    string       // 无数据或绘图失败时的说明
+}
+
+type emailRow struct {
+	Label string
+	Value string
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="margin:0;padding:24px;background:#f5f6f7;font-family:-apple-system,'PingFang SC','Microsoft YaHei',Arial,sans-serif;color:#1f2329;">
+<div style="max-width:840px;margin:0 auto;background:#ffffff;border-radius:8px;padding:24px;">
+<h2 style="margin:0 0 16px 0;">{{.Title}}</h2>
+{{range .Sections}}
+<h3 style="margin:24px 0 8px 0;font-size:15px;">{{.Heading}}{{if .Link}} <a href="{{.Link}}" style="font-size:13px;font-weight:normal;color:#3370ff;">详情</a>{{end}}</h3>
+{{if .Rows}}<table cellpadding="6" cellspacing="0" style="border-collapse:collapse;width:100%;font-size:13px;">
+<tr style="background:#f2f3f5;"><th align="left" style="border:1px solid #dee0e3;">标签</th><th align="right" style="border:1px solid #dee0e3;">值</th></tr>
+{{range .Rows}}<tr><td style="border:1px solid #dee0e3;">{{.Label}}</td><td align="right" style="border:1px solid #dee0e3;">{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Image}}<img src="{{.Image}}" alt="{{.Heading}}" style="max-width:100%;border:1px solid #dee0e3;">{{end}}
+{{if .Note}}<p style="color:#8f959e;font-size:13px;">{{.Note}}</p>{{end}}
+{{end}}
+{{if .Buttons}}<p style="margin-top:24px;">{{range .Buttons}}<a href="{{.URL}}" style="display:inline-block;padding:8px 16px;margin-right:8px;background:#3370ff;color:#ffffff;border-radius:4px;text-decoration:none;">{{.Text}}</a>{{end}}</p>{{end}}
+<hr style="border:none;border-top:1px solid #dee0e3;margin:24px 0 12px 0;">
+<p style="color:#8f959e;font-size:12px;margin:0;">{{.DataTime}}</p>
+</div>
+</body>
+</html>
+`))
+
+// email SMTP 邮件渠道
+// 报告渲染为 HTML 邮件，文本模式的最新值以表格展示，图表以 CID 内嵌的 PNG 图片展示
+type email struct{}
+
+func (email) Name() string { return "邮件" }
+
+func (email) Validate(target models.FeishuWebhook) error {
+	opts := target.Options
+	if strings.TrimSpace(opts.SMTPHost) == "" {
+		return fmt.Errorf("邮件渠道需要配置 options.smtp_host")
+	}
+	if opts.SMTPPort < 0 || opts.SMTPPort > 65535 {
+		return fmt.Errorf("无效的 SMTP 端口: %d", opts.SMTPPort)
+	}
+	switch opts.SMTPSecurity {
+	case "", SMTPSecurityAuto, SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return fmt.Errorf("无效的 smtp_security %q，可选值: auto、starttls、tls、none", opts.SMTPSecurity)
+	}
+	if _, err := mail.ParseAddress(opts.From); err != nil {
+		return fmt.Errorf("无效的发件人地址 %q: %w", opts.From, err)
+	}
+	if len(opts.To) == 0 {
+		return fmt.Errorf("邮件渠道需要配置至少一个收件人 options.to")
+	}
+	for _, addr := range append(append([]string{}, opts.To...), opts.Cc...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("无效的收件人地址 %q: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+func (email) Render(report *Report) (interface{}, error) {
+	msg := &emailMessage{Subject: report.Title}
+	var sections []emailSection
+
+	for i, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		section := emailSection{Heading: elem.PromQLName, Link: elem.Link}
+		if unit != "" {
+			section.Heading = fmt.Sprintf("%s (%s)", elem.PromQLName, unit)
+		}
+
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			if len(elem.TextMetrics) == 0 {
+				section.Note = "暂无数据"
+			}
+			for _, line := range sortedMetrics(elem.TextMetrics) {
+				section.Rows = append(section.Rows, emailRow{Label: line.Label, Value: service.FormatValue(line.Value, unit)})
+			}
+		} else if len(elem.ChartData.DataPoints) == 0 {
+			section.Note = "暂无数据"
+		} else if image, err := render.PNG(elem.ChartData, render.Options{Unit: unit, Location: report.location()}); err != nil {
+			log.Printf("[channel] 绘制邮件图表 %s 失败: %v", elem.PromQLName, err)
+			section.Note = "图表绘制失败: " + err.Error()
+		} else {
+			cid := fmt.Sprintf("chart-%d@fsvchart-notify", i+1)
+			msg.images = append(msg.images, emailImage{cid: cid, data: image})
+			section.Image = template.URL("cid:" + cid)
+		}
+		sections = append(sections, section)
+	}
+
+	data := map[string]interface{}{
+		"Title":    report.Title,
+		"Sections": sections,
+		"Buttons":  report.Buttons,
+		"DataTime": dataTimeLine(report),
+	}
+	var buf bytes.Buffer
+	if err := emailTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("渲染邮件失败: %w", err)
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+func (email) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	msg, ok := payload.(*emailMessage)
+	if !ok {
+		return nil, fmt.Errorf("不支持的邮件消息类型 %T", payload)
+	}
+	opts := target.Options
+	from, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("无效的发件人地址 %q: %w", opts.From, err)
+	}
+	var recipients []string
+	for _, addr := range append(append([]string{}, opts.To...), opts.Cc...) {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的收件人地址 %q: %w", addr, err)
+		}
+		recipients = append(recipients, parsed.Address)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("未配置收件人")
+	}
+
+	data, err := buildMIME(from, opts.To, opts.Cc, msg)
+	if err != nil {
+		return nil, err
+	}
+	return payload, sendMail(ctx, opts, target.Secret, from.Address, recipients, data)
+}
+
+// buildMIME 构建 multipart/related 邮件，HTML 正文通过 cid 引用内嵌图片
+func buildMIME(from *mail.Address, to, cc []string, msg *emailMessage) ([]byte, error) {
+	boundary := randomToken(16)
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	header("From", from.String())
+	header("To", formatAddressList(to))
+	if len(cc) > 0 {
+		header("Cc", formatAddressList(cc))
+	}
+	header("Subject", mime.BEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@fsvchart-notify>", randomToken(12)))
+	header("MIME-Version", "1.0")
+	header("Content-Type", fmt.Sprintf(`multipart/related; boundary="%s"; type="text/html"`, boundary))
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	header("Content-Type", "text/html; charset=utf-8")
+	header("Content-Transfer-Encoding", "base64")
+	b.WriteString("\r\n")
+	writeBase64Lines(&b, []byte(msg.HTML))
+
+	for _, image := range msg.images {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		header("Content-Type", "image/png")
+		header("Content-Transfer-Encoding", "base64")
+		header("Content-ID", "<"+image.cid+">")
+		header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.png"`, strings.SplitN(image.cid, "@", 2)[0]))
+		b.WriteString("\r\n")
+		writeBase64Lines(&b, image.data)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// formatAddressList 格式化收件人列表，非 ASCII 的显示名称按 RFC 2047 编码
+func formatAddressList(addrs []string) string {
+	formatted := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if parsed, err := mail.ParseAddress(addr); err == nil {
+			addr = parsed.String()
+		}
+		formatted = append(formatted, addr)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// writeBase64Lines 以每行 76 个字符写入 base64 编码内容
+func writeBase64Lines(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
+
+// sendMail 连接 SMTP 服务器发送邮件，按 smtp_security 选择 TLS 方式，配置了用户名时进行 PLAIN 认证
+func sendMail(ctx context.Context, opts models.ChannelOptions, password, from string, recipients []string, data []byte) error {
+	port := opts.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	security := opts.SMTPSecurity
+	if security == "" || security == SMTPSecurityAuto {
+		security = SMTPSecurityAuto
+		if port == 465 {
+			security = SMTPSecurityTLS
+		}
+	}
+	host := strings.TrimSpace(opts.SMTPHost)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: host}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器 %s 失败: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if security == SMTPSecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP 握手失败: %w", err)
+	}
+	defer client.Close()
+
+	if security == SMTPSecurityStartTLS || security == SMTPSecurityAuto {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS 失败: %w", err)
+			}
+		} else if security == SMTPSecurityStartTLS {
+			return fmt.Errorf("SMTP 服务器 %s 不支持 STARTTLS", addr)
+		}
+	}
+	if opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", opts.Username, password, host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("MAIL FROM 失败: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s 失败: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA 失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("写入邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return client.Quit()
+}
+
+// randomToken 返回 n 字节的随机十六进制字符串，用于 MIME 分隔符和 Message-ID
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
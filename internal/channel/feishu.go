@@ -0,0 +1,89 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+func init() {
+	Register(TypeFeishu, feishu{})
+}
+
+// feishu 飞书自定义机器人，按报告模式构建与之前相同的卡片；配置了 secret 时对每次请求签名
+// options.chart_image 开启时图表以 PNG 图片发送，图片通过 options.app_id/app_secret 对应的飞书应用上传
+// 报告设置了自定义卡片布局时按布局渲染卡片
+type feishu struct{}
+
+func (feishu) Name() string { return "飞书" }
+
+func (feishu) Validate(target models.FeishuWebhook) error {
+	if target.Options.ChartImage && (target.Options.AppID == "" || target.Options.AppSecret == "") {
+		return fmt.Errorf("图表以图片发送时需要配置 options.app_id 和 options.app_secret，用于上传图片")
+	}
+	return nil
+}
+
+func (feishu) Render(report *Report) (interface{}, error) {
+	if report.Layout != nil {
+		return RenderFeishuLayout(report.Layout, report)
+	}
+	switch report.Mode {
+	case ModeHybrid:
+		return service.BuildFeishuHybridCard(report.Elements, report.Title, report.Template, report.Unit,
+			report.Buttons, report.ShowDataLabel, report.Location, report.Mentions), nil
+	case ModeText:
+		promqlMetrics := make(map[string][]service.LatestMetric)
+		promqlConfigs := make(map[string]struct {
+			Name              string
+			Unit              string
+			MetricLabel       string
+			CustomMetricLabel string
+			InitialUnit       string
+			Mentions          []models.Mention
+			Link              string
+		})
+		var promqlOrder []string
+		for _, elem := range report.Elements {
+			promqlMetrics[elem.PromQLName] = elem.TextMetrics
+			cfg := promqlConfigs[elem.PromQLName]
+			cfg.Name = elem.PromQLName
+			cfg.Unit = elem.Unit
+			cfg.MetricLabel = elem.MetricLabel
+			cfg.Mentions = elem.Mentions
+			cfg.Link = elem.Link
+			promqlConfigs[elem.PromQLName] = cfg
+			promqlOrder = append(promqlOrder, elem.PromQLName)
+		}
+		return service.BuildFeishuTextCard(promqlMetrics, promqlConfigs, promqlOrder, report.Title, report.Template,
+			report.Buttons, report.Location, report.Mentions), nil
+	default:
+		var dataPoints []models.QueryDataPoints
+		for _, elem := range report.Elements {
+			if elem.ChartData != nil {
+				dataPoints = append(dataPoints, *elem.ChartData)
+			}
+		}
+		return service.BuildFeishuStandardChart(dataPoints, report.Title, report.Template, report.Unit,
+			report.Buttons, report.ShowDataLabel, report.Location, report.Mentions)
+	}
+}
+
+func (feishu) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	if target.Options.ChartImage {
+		payload = feishuChartImages(ctx, feishuImageClient(target), report, payload)
+	}
+	switch card := payload.(type) {
+	case *service.FeishuCard:
+		return payload, service.SendFeishuCardMessage(ctx, target.URL, target.Secret, card)
+	case map[string]interface{}:
+		if report.Mode == ModeHybrid || report.Layout != nil {
+			return payload, service.SendFeishuCardMessageFromMap(ctx, target.URL, target.Secret, card)
+		}
+		return payload, service.PostFeishuChartPayload(ctx, target.URL, target.Secret, card)
+	default:
+		return nil, fmt.Errorf("不支持的飞书消息类型 %T", payload)
+	}
+}
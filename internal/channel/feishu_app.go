@@ -0,0 +1,331 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+func init() {
+	Register(TypeFeishuApp, feishuApp{})
+}
+
+// feishuOpenAPIBase 默认的飞书开放平台地址，渠道的 url 不为空时使用 url（如 Lark 的 https://open.larksuite.com）
+var feishuOpenAPIBase = "https://open.feishu.cn"
+
+// feishuTokenRefreshAhead 在 tenant_access_token 过期前提前刷新的时间
+const feishuTokenRefreshAhead = 5 * time.Minute
+
+// feishuReceiveIDTypes 飞书 IM API 支持的接收方 ID 类型
+var feishuReceiveIDTypes = []string{"chat_id", "open_id", "user_id", "union_id", "email"}
+
+// feishuTokenInvalidCodes tenant_access_token 无效或过期时的错误码，需要重新获取后重试
+var feishuTokenInvalidCodes = map[int]bool{
+	99991661: true, // 缺少访问凭证
+	99991663: true, // tenant_access_token 无效
+	99991664: true, // tenant_access_token 过期
+}
+
+// feishuRateLimitCodes 触发频率限制时的错误码，稍后重试可能成功
+var feishuRateLimitCodes = map[int]bool{
+	99991400: true, // 应用调用接口的频率超过限制
+	230020:   true, // 消息发送频率超过限制
+	11232:    true, // 消息发送频率超过限制（旧版接口）
+}
+
+// feishuAPIError 开放平台返回 code 不为 0 时的错误
+type feishuAPIError struct {
+	Code       int
+	Msg        string
+	StatusCode int // HTTP 状态码
+}
+
+func (e *feishuAPIError) Error() string {
+	return fmt.Sprintf("飞书开放平台 API 错误: code=%d, msg=%s", e.Code, e.Msg)
+}
+
+// feishuApp 飞书应用机器人，使用 app_id/app_secret 获取 tenant_access_token 后通过 IM API 发送卡片
+// 卡片内容与自定义机器人相同；secret 填写 app_secret，options.receive_ids 为群组或用户 ID
+type feishuApp struct {
+	feishu
+}
+
+func (feishuApp) Name() string { return "飞书应用" }
+
+func (feishuApp) Validate(target models.FeishuWebhook) error {
+	opts := target.Options
+	if opts.AppID == "" || target.Secret == "" {
+		return fmt.Errorf("飞书应用渠道需要配置 options.app_id 并在 secret 中填写 App Secret")
+	}
+	if len(opts.ReceiveIDs) == 0 {
+		return fmt.Errorf("飞书应用渠道需要配置 options.receive_ids")
+	}
+	idType := feishuReceiveIDType(opts)
+	valid := false
+	for _, t := range feishuReceiveIDTypes {
+		if idType == t {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("无效的接收方 ID 类型 %q，可选值: %s", opts.ReceiveIDType, strings.Join(feishuReceiveIDTypes, "、"))
+	}
+	if target.URL != "" && !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return fmt.Errorf("飞书开放平台地址必须以 http:// 或 https:// 开头")
+	}
+	return nil
+}
+
+func (feishuApp) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	client := newFeishuAppClient(target)
+	if target.Options.ChartImage {
+		payload = feishuChartImages(ctx, client, report, payload)
+	}
+	content, err := feishuCardContent(payload)
+	if err != nil {
+		return nil, err
+	}
+	idType := feishuReceiveIDType(target.Options)
+
+	// 逐个发送，单个接收方失败不影响其他接收方
+	var failed []string
+	for _, id := range target.Options.ReceiveIDs {
+		err := service.RetryWithBackoff(ctx, "FeishuApp", func(attempt int) error {
+			err := client.call(ctx, "/open-apis/im/v1/messages?receive_id_type="+url.QueryEscape(idType), map[string]string{
+				"receive_id": id,
+				"msg_type":   "interactive",
+				"content":    content,
+			}, nil)
+			if err != nil && !feishuRetryable(err) {
+				return service.Permanent(err)
+			}
+			return err
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", id, err))
+		}
+	}
+	if len(failed) > 0 {
+		return payload, fmt.Errorf("发送到 %d/%d 个接收方失败: %s", len(failed), len(target.Options.ReceiveIDs), strings.Join(failed, "; "))
+	}
+	return payload, nil
+}
+
+// feishuCardContent 从自定义机器人的消息体中取出卡片，编码为 IM API 的 content 字符串
+func feishuCardContent(payload interface{}) (string, error) {
+	var card interface{}
+	switch p := payload.(type) {
+	case *service.FeishuCard:
+		card = p.Card
+	case map[string]interface{}:
+		card = p["card"]
+	default:
+		return "", fmt.Errorf("不支持的飞书消息类型 %T", payload)
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", fmt.Errorf("序列化卡片失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// feishuReceiveIDType 返回接收方 ID 类型，默认 chat_id
+func feishuReceiveIDType(opts models.ChannelOptions) string {
+	if opts.ReceiveIDType == "" {
+		return "chat_id"
+	}
+	return opts.ReceiveIDType
+}
+
+// feishuAppClient 调用飞书开放平台 API 的客户端
+type feishuAppClient struct {
+	base      string
+	appID     string
+	appSecret string
+}
+
+func newFeishuAppClient(target models.FeishuWebhook) *feishuAppClient {
+	base := target.URL
+	if base == "" {
+		base = feishuOpenAPIBase
+	}
+	return &feishuAppClient{base: strings.TrimRight(base, "/"), appID: target.Options.AppID, appSecret: target.Secret}
+}
+
+// call 以 JSON 格式调用开放平台 API，result 不为 nil 时解析响应
+func (c *feishuAppClient) call(ctx context.Context, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return c.do(ctx, path, "application/json; charset=utf-8", data, result)
+}
+
+// uploadImage 上传消息图片，返回卡片 img 元素使用的 image_key
+func (c *feishuAppClient) uploadImage(ctx context.Context, image []byte) (string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("image_type", "message"); err != nil {
+		return "", err
+	}
+	part, err := w.CreateFormFile("image", "chart.png")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(image); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Data struct {
+			ImageKey string `json:"image_key"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, "/open-apis/im/v1/images", w.FormDataContentType(), buf.Bytes(), &resp); err != nil {
+		return "", fmt.Errorf("上传图片失败: %w", err)
+	}
+	return resp.Data.ImageKey, nil
+}
+
+// do 使用 tenant_access_token 调用开放平台 API，凭证失效时重新获取并重试一次
+func (c *feishuAppClient) do(ctx context.Context, path, contentType string, data []byte, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		token, err := c.token(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.post(ctx, path, map[string]string{"Authorization": "Bearer " + token}, contentType, data, result)
+		var apiErr *feishuAPIError
+		if errors.As(err, &apiErr) && feishuTokenInvalidCodes[apiErr.Code] && attempt == 0 {
+			log.Printf("[channel] 飞书应用 %s 的 tenant_access_token 已失效 (code=%d)，重新获取", c.appID, apiErr.Code)
+			feishuTokens.invalidate(c.cacheKey())
+			continue
+		}
+		return err
+	}
+}
+
+// post 发送请求并解析响应，响应中的 code 不为 0 时返回 *feishuAPIError
+func (c *feishuAppClient) post(ctx context.Context, path string, headers map[string]string, contentType string, body []byte, result interface{}) error {
+	data, httpErr := postBody(ctx, c.base+path, contentType, body, headers)
+	var status struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	// 开放平台在 HTTP 4xx 时也返回 code 和 msg，优先使用响应中的错误信息
+	if err := json.Unmarshal(data, &status); err != nil {
+		if httpErr != nil {
+			return httpErr
+		}
+		return fmt.Errorf("解析飞书开放平台响应失败: %w, 响应内容: %s", err, truncate(string(data), 200))
+	}
+	if status.Code != 0 {
+		apiErr := &feishuAPIError{Code: status.Code, Msg: status.Msg}
+		var statusErr *httpStatusError
+		if errors.As(httpErr, &statusErr) {
+			apiErr.StatusCode = statusErr.StatusCode
+		}
+		return apiErr
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("解析飞书开放平台响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// feishuRetryable 判断发送失败后是否需要重试：网络错误、HTTP 5xx 和 429、频率限制错误码需要重试，其他错误重试也不会成功
+func feishuRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *feishuAPIError
+	if errors.As(err, &apiErr) {
+		return feishuRateLimitCodes[apiErr.Code] || apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// token 返回缓存的 tenant_access_token，不存在或即将过期时重新获取
+func (c *feishuAppClient) token(ctx context.Context) (string, error) {
+	key := c.cacheKey()
+	if token, ok := feishuTokens.get(key); ok {
+		return token, nil
+	}
+
+	var resp struct {
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"` // 有效期（秒）
+	}
+	body, _ := json.Marshal(map[string]string{"app_id": c.appID, "app_secret": c.appSecret})
+	err := c.post(ctx, "/open-apis/auth/v3/tenant_access_token/internal", nil,
+		"application/json; charset=utf-8", body, &resp)
+	if err != nil {
+		return "", fmt.Errorf("获取 tenant_access_token 失败: %w", err)
+	}
+	expiresAt := time.Now().Add(time.Duration(resp.Expire)*time.Second - feishuTokenRefreshAhead)
+	feishuTokens.set(key, resp.Token, expiresAt)
+	log.Printf("[channel] 飞书应用 %s 获取 tenant_access_token 成功，有效期 %d 秒", c.appID, resp.Expire)
+	return resp.Token, nil
+}
+
+func (c *feishuAppClient) cacheKey() string {
+	return c.base + "|" + c.appID
+}
+
+// feishuTokenCache 按应用缓存 tenant_access_token
+type feishuTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]feishuCachedToken
+}
+
+type feishuCachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+var feishuTokens = &feishuTokenCache{tokens: make(map[string]feishuCachedToken)}
+
+func (c *feishuTokenCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tokens[key]
+	if !ok || time.Now().After(t.expiresAt) {
+		return "", false
+	}
+	return t.token, true
+}
+
+func (c *feishuTokenCache) set(key, token string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = feishuCachedToken{token: token, expiresAt: expiresAt}
+}
+
+func (c *feishuTokenCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tokens, key)
+}
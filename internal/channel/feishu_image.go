@@ -0,0 +1,117 @@
+package channel
+
+import (
+	"context"
+	"log"
+	"net/url"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+	"fsvchart-notify/internal/service"
+)
+
+// feishuImageClient 返回自定义机器人上传图片使用的飞书应用客户端，开放平台地址取 WebHook 地址的域名
+func feishuImageClient(target models.FeishuWebhook) *feishuAppClient {
+	base := feishuOpenAPIBase
+	if u, err := url.Parse(target.URL); err == nil && u.Scheme != "" && u.Host != "" {
+		base = u.Scheme + "://" + u.Host
+	}
+	return &feishuAppClient{base: base, appID: target.Options.AppID, appSecret: target.Options.AppSecret}
+}
+
+// feishuChartImages 将卡片中的飞书图表组件替换为服务端绘制并上传的 PNG 图片，返回新的消息体，不修改 payload
+// 图表组件与报告中有数据的图表元素按顺序对应；数量不一致或使用自定义卡片布局时不替换，单个图表绘制或上传失败时保留图表组件
+func feishuChartImages(ctx context.Context, client *feishuAppClient, report *Report, payload interface{}) interface{} {
+	if report.Layout != nil {
+		// 自定义布局中图表的位置和顺序由布局决定，无法与报告中的图表元素对应
+		log.Printf("[channel] 任务使用自定义卡片布局，保留图表组件")
+		return payload
+	}
+	message, ok := payload.(map[string]interface{})
+	if !ok {
+		return payload
+	}
+	card, ok := message["card"].(map[string]interface{})
+	if !ok {
+		return payload
+	}
+	elements, ok := card["elements"].([]interface{})
+	if !ok {
+		return payload
+	}
+
+	var positions []int
+	for i, e := range elements {
+		if m, ok := e.(map[string]interface{}); ok && m["tag"] == "chart" {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) == 0 {
+		return payload
+	}
+	charts := report.chartElements()
+	if len(charts) != len(positions) {
+		log.Printf("[channel] 飞书卡片中的图表数量 (%d) 与报告不一致 (%d)，保留图表组件", len(positions), len(charts))
+		return payload
+	}
+
+	replaced := append([]interface{}(nil), elements...)
+	for n, i := range positions {
+		elem := charts[n]
+		image, err := render.PNG(elem.ChartData, render.Options{Unit: report.unitOf(elem), Location: report.location()})
+		if err != nil {
+			log.Printf("[channel] 绘制飞书图表 %s 失败，保留图表组件: %v", elem.PromQLName, err)
+			continue
+		}
+		key, err := client.uploadImage(ctx, image)
+		if err != nil {
+			log.Printf("[channel] 飞书图表 %s %v，保留图表组件", elem.PromQLName, err)
+			continue
+		}
+		replaced[i] = map[string]interface{}{
+			"tag":     "img",
+			"img_key": key,
+			"alt":     map[string]interface{}{"tag": "plain_text", "content": elem.PromQLName},
+			"mode":    "fit_horizontal",
+			"preview": true,
+		}
+	}
+
+	newCard := make(map[string]interface{}, len(card))
+	for k, v := range card {
+		newCard[k] = v
+	}
+	newCard["elements"] = replaced
+	newMessage := make(map[string]interface{}, len(message))
+	for k, v := range message {
+		newMessage[k] = v
+	}
+	newMessage["card"] = newCard
+	return newMessage
+}
+
+// chartElements 返回有数据的图表元素，顺序与飞书卡片中的图表组件一致
+// 图表卡片按 ChartTitle 去重，只保留第一个同名的查询
+func (r *Report) chartElements() []service.HybridElement {
+	elements := r.Elements
+	if r.Mode == ModeHybrid {
+		elements = r.orderedElements()
+	}
+	seen := make(map[string]bool)
+	var charts []service.HybridElement
+	for _, e := range elements {
+		if e.ChartData == nil || (r.Mode == ModeHybrid && e.DisplayMode != "chart") {
+			continue
+		}
+		if r.Mode != ModeHybrid {
+			if seen[e.ChartData.ChartTitle] {
+				continue
+			}
+			seen[e.ChartData.ChartTitle] = true
+		}
+		if len(e.ChartData.DataPoints) > 0 {
+			charts = append(charts, e)
+		}
+	}
+	return charts
+}
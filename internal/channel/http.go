@@ -0,0 +1,97 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// httpClient 各渠道发送消息共用的 HTTP 客户端
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON 以 JSON 格式发送 payload，返回响应内容；HTTP 状态码非 2xx 时返回错误
+func postJSON(ctx context.Context, url string, payload interface{}, headers map[string]string) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return postBody(ctx, url, "application/json; charset=utf-8", data, headers)
+}
+
+// postBody 发送已编码的请求体，返回响应内容；HTTP 状态码非 2xx 时返回错误
+func postBody(ctx context.Context, url, contentType string, data []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[channel] POST %s, payload size: %d bytes, response status: %d, body: %s",
+		redactURL(url), len(data), resp.StatusCode, truncate(string(body), 500))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, &httpStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
+
+// httpStatusError postBody 在 HTTP 状态码非 2xx 时返回的错误
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, truncate(e.Body, 200))
+}
+
+// redactURL 隐藏 URL 中的查询参数（可能包含 access_token、签名等）
+func redactURL(u string) string {
+	for i := 0; i < len(u); i++ {
+		if u[i] == '?' {
+			return u[:i] + "?***"
+		}
+	}
+	return u
+}
+
+// truncate 将过长的文本截断到 n 个字符并追加 ...，不拆分多字节字符，用于日志、错误信息和有长度限制的字段
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
+
+// checkErrCode 检查钉钉、企业微信等返回 {"errcode":0,"errmsg":"ok"} 格式的响应
+func checkErrCode(name string, body []byte) error {
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w, 响应内容: %s", name, err, truncate(string(body), 200))
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("%sAPI错误: errcode=%d, errmsg=%s", name, result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
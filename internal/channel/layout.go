@@ -0,0 +1,447 @@
+package channel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// RenderFeishuLayout 按自定义卡片布局渲染飞书消息体
+// template 布局的模板生成卡片 JSON（包含 msg_type 时作为完整消息体），elements 数组中嵌套的数组会被展开；
+// blocks 布局按块列表生成卡片元素，标题和颜色使用任务的配置
+func RenderFeishuLayout(layout *models.CardLayout, report *Report) (map[string]interface{}, error) {
+	r := newLayoutRenderer(report)
+	switch layout.Kind {
+	case models.LayoutKindTemplate:
+		return r.renderTemplate(layout.Content)
+	case models.LayoutKindBlocks:
+		blocks, err := ParseLayoutBlocks(layout.Content)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := r.blocks(blocks)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"msg_type": "interactive",
+			"card": map[string]interface{}{
+				"config": map[string]interface{}{
+					"wide_screen_mode": true,
+					"enable_forward":   true,
+				},
+				"header": map[string]interface{}{
+					"title": map[string]interface{}{
+						"tag":     "plain_text",
+						"content": report.Title,
+					},
+					"template": report.Template,
+				},
+				"elements": elements,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的卡片布局类型 %q，可选值: %s、%s", layout.Kind, models.LayoutKindTemplate, models.LayoutKindBlocks)
+	}
+}
+
+// ValidateLayout 校验卡片布局：解析模板或块列表，并使用示例数据试渲染
+func ValidateLayout(layout *models.CardLayout) error {
+	if strings.TrimSpace(layout.Content) == "" {
+		return fmt.Errorf("卡片布局内容不能为空")
+	}
+	card, err := RenderFeishuLayout(layout, sampleReport())
+	if err != nil {
+		return err
+	}
+	if _, err := json.Marshal(card); err != nil {
+		return fmt.Errorf("卡片布局生成的消息体无法序列化: %w", err)
+	}
+	return nil
+}
+
+// ParseLayoutBlocks 解析 blocks 布局的块列表
+func ParseLayoutBlocks(content string) ([]models.LayoutBlock, error) {
+	var blocks []models.LayoutBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return nil, fmt.Errorf("卡片布局块列表格式无效: %w", err)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("卡片布局至少需要一个块")
+	}
+	return blocks, nil
+}
+
+// sampleReport 校验卡片布局时使用的示例报告，包含一个文本查询和一个图表查询
+func sampleReport() *Report {
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	var points []models.DataPoint
+	for i := 0; i <= 6; i++ {
+		t := start.Add(time.Duration(i) * 10 * time.Minute)
+		points = append(points,
+			models.DataPoint{Type: "node-1", Time: t.Format("15:04"), UnixTime: t.Unix(), Value: float64(40 + i*5)},
+			models.DataPoint{Type: "node-2", Time: t.Format("15:04"), UnixTime: t.Unix(), Value: float64(70 - i*3)})
+	}
+	return &Report{
+		TaskID:   1,
+		TaskName: "示例任务",
+		RunAt:    end,
+		Start:    start,
+		End:      end,
+		Mode:     ModeHybrid,
+		Title:    "示例卡片",
+		Template: "blue",
+		Unit:     "%",
+		Buttons:  []models.Button{{Text: "查看详情", URL: "https://example.com"}},
+		Elements: []service.HybridElement{
+			{
+				DisplayMode: "text",
+				PromQLName:  "示例文本查询",
+				Query:       "up",
+				Unit:        "%",
+				TextMetrics: []service.LatestMetric{
+					{Label: "node-1", Value: 95.5, Time: end},
+					{Label: "node-2", Value: 42, Time: end},
+				},
+			},
+			{
+				DisplayOrder: 1,
+				DisplayMode:  "chart",
+				PromQLName:   "示例图表查询",
+				Query:        "rate(up[5m])",
+				ChartType:    "line",
+				ChartData: &models.QueryDataPoints{
+					DataPoints: points,
+					ChartType:  "line",
+					ChartTitle: "示例图表查询",
+					Unit:       "%",
+				},
+			},
+		},
+	}
+}
+
+// layoutRenderer 渲染一份报告的卡片布局
+type layoutRenderer struct {
+	report   *Report
+	data     *WebhookData
+	elements []service.HybridElement // 按飞书混合卡片的顺序排列
+	multiDay bool
+}
+
+func newLayoutRenderer(report *Report) *layoutRenderer {
+	return &layoutRenderer{
+		report:   report,
+		data:     newWebhookData(report),
+		elements: report.orderedElements(),
+		multiDay: service.IsMultiDayData(report.Elements, report.location()),
+	}
+}
+
+// funcs 模板中可用的函数，在通用 WebHook 模板函数的基础上增加生成卡片元素的函数
+func (r *layoutRenderer) funcs() template.FuncMap {
+	toJSON := func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+	return template.FuncMap{
+		// elements 按默认样式生成查询的卡片元素数组，如 {{elements "CPU 使用率"}}，不传名称时生成所有查询
+		"elements": func(names ...string) (string, error) {
+			return toJSON(r.queryElements(names, ""))
+		},
+		// table 生成查询最新值的表格组件，如 {{table "CPU 使用率" "内存使用率"}}，不传名称时包含所有查询
+		"table": func(names ...string) (string, error) {
+			return toJSON(r.table(names))
+		},
+		// mentions 返回任务级 @ 的 Markdown 内容，没有需要 @ 的对象时为空字符串
+		"mentions": func() string {
+			return service.MentionMarkdown(r.report.Mentions, r.report.Elements)
+		},
+	}
+}
+
+// renderTemplate 执行 template 布局的模板并解析生成的卡片 JSON
+func (r *layoutRenderer) renderTemplate(text string) (map[string]interface{}, error) {
+	tmpl, err := template.New("layout").Funcs(webhookFuncs).Funcs(r.funcs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("卡片布局模板格式无效: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r.data); err != nil {
+		return nil, fmt.Errorf("渲染卡片布局模板失败: %w", err)
+	}
+	var card map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &card); err != nil {
+		return nil, fmt.Errorf("卡片布局模板生成的内容不是有效的 JSON 对象: %w", err)
+	}
+	flattenElements(card)
+	if _, ok := card["msg_type"]; ok {
+		return card, nil
+	}
+	return map[string]interface{}{"msg_type": "interactive", "card": card}, nil
+}
+
+// text 执行块中文本字段的模板
+func (r *layoutRenderer) text(s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New("block").Funcs(webhookFuncs).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("模板格式无效: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r.data); err != nil {
+		return "", fmt.Errorf("渲染模板失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// blocks 依次渲染块列表
+func (r *layoutRenderer) blocks(blocks []models.LayoutBlock) ([]interface{}, error) {
+	elements := []interface{}{}
+	for i, b := range blocks {
+		elems, err := r.block(b)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个块（%s）: %w", i+1, b.Type, err)
+		}
+		elements = append(elements, elems...)
+	}
+	return elements, nil
+}
+
+// block 渲染单个块，返回对应的卡片元素
+func (r *layoutRenderer) block(b models.LayoutBlock) ([]interface{}, error) {
+	switch b.Mode {
+	case "", "text", "chart":
+	default:
+		return nil, fmt.Errorf("无效的 mode %q，可选值: text、chart", b.Mode)
+	}
+
+	switch b.Type {
+	case "markdown":
+		if b.Content == "" {
+			return nil, fmt.Errorf("content 不能为空")
+		}
+		content, err := r.text(b.Content)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{map[string]interface{}{"tag": "markdown", "content": content}}, nil
+	case "note":
+		content, err := r.text(b.Content)
+		if err != nil {
+			return nil, err
+		}
+		if content == "" {
+			content = "⏰ 数据时间: " + r.report.RunAt.In(r.report.location()).Format("2006-01-02 15:04")
+		}
+		return []interface{}{map[string]interface{}{
+			"tag":      "note",
+			"elements": []interface{}{map[string]interface{}{"tag": "lark_md", "content": content}},
+		}}, nil
+	case "hr":
+		return []interface{}{map[string]interface{}{"tag": "hr"}}, nil
+	case "queries":
+		return r.queryElements(nil, b.Mode), nil
+	case "query":
+		if b.Query == "" {
+			return nil, fmt.Errorf("query 不能为空")
+		}
+		return r.queryElements([]string{b.Query}, b.Mode), nil
+	case "table":
+		return []interface{}{r.table(b.Queries)}, nil
+	case "columns":
+		if len(b.Columns) == 0 {
+			return nil, fmt.Errorf("columns 不能为空")
+		}
+		columns := make([]interface{}, 0, len(b.Columns))
+		for i, col := range b.Columns {
+			elements, err := r.blocks(col.Blocks)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 列: %w", i+1, err)
+			}
+			weight := col.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			columns = append(columns, map[string]interface{}{
+				"tag":            "column",
+				"width":          "weighted",
+				"weight":         weight,
+				"vertical_align": "top",
+				"elements":       elements,
+			})
+		}
+		return []interface{}{map[string]interface{}{
+			"tag":              "column_set",
+			"flex_mode":        "none",
+			"background_style": "default",
+			"columns":          columns,
+		}}, nil
+	case "image":
+		if b.ImageKey == "" {
+			return nil, fmt.Errorf("image_key 不能为空")
+		}
+		alt, err := r.text(b.Alt)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{map[string]interface{}{
+			"tag":     "img",
+			"img_key": b.ImageKey,
+			"alt":     map[string]interface{}{"tag": "plain_text", "content": alt},
+		}}, nil
+	case "buttons":
+		if len(b.Buttons) == 0 {
+			// 未配置按钮时使用任务的按钮，其链接已按查询时间范围渲染
+			if action := service.FeishuActionElement(r.report.Buttons, "default"); action != nil {
+				return []interface{}{action}, nil
+			}
+			return nil, nil
+		}
+		actions := make([]interface{}, 0, len(b.Buttons))
+		for i, btn := range b.Buttons {
+			if btn.Text == "" || btn.URL == "" {
+				return nil, fmt.Errorf("第 %d 个按钮的 text 和 url 不能为空", i+1)
+			}
+			text, err := r.text(btn.Text)
+			if err != nil {
+				return nil, err
+			}
+			url, err := r.text(btn.URL)
+			if err != nil {
+				return nil, err
+			}
+			typ := btn.Type
+			switch typ {
+			case "":
+				typ = "default"
+			case "default", "primary", "danger":
+			default:
+				return nil, fmt.Errorf("第 %d 个按钮的类型 %q 无效，可选值: default、primary、danger", i+1, btn.Type)
+			}
+			actions = append(actions, map[string]interface{}{
+				"tag":  "button",
+				"text": map[string]interface{}{"tag": "plain_text", "content": text},
+				"type": typ,
+				"url":  url,
+			})
+		}
+		return []interface{}{map[string]interface{}{"tag": "action", "actions": actions}}, nil
+	case "mentions":
+		at := service.MentionMarkdown(r.report.Mentions, r.report.Elements)
+		if at == "" {
+			return nil, nil
+		}
+		return []interface{}{map[string]interface{}{"tag": "markdown", "content": at}}, nil
+	default:
+		return nil, fmt.Errorf("不支持的块类型 %q，可选值: markdown、note、hr、queries、query、table、columns、image、buttons、mentions", b.Type)
+	}
+}
+
+// queryElements 按默认样式生成查询的卡片元素，names 为空时包含所有查询，mode 不为空时只包含该模式的元素
+func (r *layoutRenderer) queryElements(names []string, mode string) []interface{} {
+	elements := []interface{}{}
+	for _, elem := range r.elements {
+		if (mode != "" && elem.DisplayMode != mode) || !containsName(names, elem.PromQLName) {
+			continue
+		}
+		elements = append(elements, service.BuildFeishuElements(elem, r.multiDay, r.report.location())...)
+	}
+	return elements
+}
+
+// table 生成查询最新值的飞书表格组件：文本查询使用各指标的最新值，图表查询使用各序列最后一个数据点
+// 同一查询同时有文本和图表元素时只使用文本元素
+func (r *layoutRenderer) table(names []string) map[string]interface{} {
+	hasText := make(map[string]bool)
+	for _, q := range r.data.Queries {
+		if q.Mode == "text" {
+			hasText[q.Name] = true
+		}
+	}
+
+	rows := []interface{}{}
+	for _, q := range r.data.Queries {
+		if !containsName(names, q.Name) || (q.Mode == "chart" && hasText[q.Name]) {
+			continue
+		}
+		addRow := func(label string, value float64) {
+			rows = append(rows, map[string]interface{}{
+				"query": q.Name,
+				"label": label,
+				"value": service.FormatValue(value, q.Unit),
+			})
+		}
+		if q.Mode == "text" {
+			for _, m := range q.Metrics {
+				addRow(m.Label, m.Value)
+			}
+			continue
+		}
+		series := append([]WebhookSeries(nil), q.Series...)
+		sort.SliceStable(series, func(i, j int) bool { return series[i].Name < series[j].Name })
+		for _, s := range series {
+			if len(s.Points) > 0 {
+				addRow(s.Name, s.Points[len(s.Points)-1].Value)
+			}
+		}
+	}
+	return service.FeishuTable([]interface{}{
+		service.FeishuTableColumn("query", "查询", ""),
+		service.FeishuTableColumn("label", "名称", ""),
+		service.FeishuTableColumn("value", "最新值", ""),
+	}, rows)
+}
+
+// containsName 判断名称是否在列表中，列表为空时视为包含所有名称
+func containsName(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenElements 递归展开卡片 JSON 中 elements 数组内嵌套的数组，使模板可以直接插入 elements、table 函数的结果
+func flattenElements(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if list, ok := child.([]interface{}); ok && key == "elements" {
+				node[key] = flattenList(list)
+			}
+			flattenElements(node[key])
+		}
+	case []interface{}:
+		for _, child := range node {
+			flattenElements(child)
+		}
+	}
+}
+
+// flattenList 展开列表中嵌套的数组
+func flattenList(list []interface{}) []interface{} {
+	flat := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		if nested, ok := item.([]interface{}); ok {
+			flat = append(flat, flattenList(nested)...)
+		} else {
+			flat = append(flat, item)
+		}
+	}
+	return flat
+}
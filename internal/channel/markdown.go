@@ -0,0 +1,170 @@
+package channel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// Markdown 表格的大小限制，避免图表数据过多时消息超出渠道长度限制
+const (
+	maxTableRows    = 10
+	maxTableColumns = 6
+)
+
+// markdownSections 按报告元素顺序渲染 Markdown 段落，文本元素渲染为列表，图表元素渲染为表格
+func markdownSections(report *Report) []string {
+	var sections []string
+	for _, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		heading := markdownHeading(elem.PromQLName, unit, elem.Link)
+		if elem.DisplayMode == "chart" && elem.ChartData != nil {
+			sections = append(sections, markdownTable(heading, elem.ChartData, report.location()))
+		} else {
+			sections = append(sections, markdownList(heading, elem.TextMetrics, unit))
+		}
+	}
+	return sections
+}
+
+// markdownHeading 返回查询名称和单位组成的小标题，查询设置了链接时在标题后附加详情链接
+func markdownHeading(name, unit, link string) string {
+	heading := fmt.Sprintf("**%s**", name)
+	if unit != "" {
+		heading = fmt.Sprintf("**%s** (%s)", name, unit)
+	}
+	if link != "" {
+		heading += fmt.Sprintf(" [详情](%s)", link)
+	}
+	return heading
+}
+
+// markdownButtons 将按钮渲染为 Markdown 链接，多个按钮以空格分隔
+func markdownButtons(buttons []models.Button) string {
+	links := make([]string, 0, len(buttons))
+	for _, b := range buttons {
+		links = append(links, fmt.Sprintf("[%s](%s)", b.Text, b.URL))
+	}
+	return strings.Join(links, "　")
+}
+
+// markdownList 渲染文本元素，格式与飞书文本卡片一致
+func markdownList(heading string, metrics []service.LatestMetric, unit string) string {
+	return heading + "\n\n" + strings.Join(metricLines(metrics, unit), "\n\n")
+}
+
+// markdownTable 渲染图表元素为 Markdown 表格
+func markdownTable(heading string, data *models.QueryDataPoints, loc *time.Location) string {
+	table := chartTable(data, loc)
+	if table == "" {
+		return heading + "\n\n└─ 暂无数据"
+	}
+	return heading + "\n\n" + table
+}
+
+// metricLines 返回按标签排序的最新值列表，每行以 ├─/└─ 开头
+func metricLines(metrics []service.LatestMetric, unit string) []string {
+	if len(metrics) == 0 {
+		return []string{"└─ 暂无数据"}
+	}
+
+	sorted := sortedMetrics(metrics)
+	lines := make([]string, 0, len(sorted))
+	for i, metric := range sorted {
+		prefix := "├─"
+		if i == len(sorted)-1 {
+			prefix = "└─"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", prefix, metric.Label, service.FormatValue(metric.Value, unit)))
+	}
+	return lines
+}
+
+// sortedMetrics 返回按标签排序的最新值副本
+func sortedMetrics(metrics []service.LatestMetric) []service.LatestMetric {
+	sorted := append([]service.LatestMetric(nil), metrics...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Label < sorted[j].Label
+	})
+	return sorted
+}
+
+// chartTable 将图表数据转换为表格，每行一个时间点，每列一个序列，没有数据时返回空字符串
+// 只保留最近 maxTableRows 个时间点和前 maxTableColumns 个序列
+func chartTable(data *models.QueryDataPoints, loc *time.Location) string {
+	if len(data.DataPoints) == 0 {
+		return ""
+	}
+
+	values := make(map[int64]map[string]float64)
+	labels := make(map[int64]string)
+	seriesSet := make(map[string]bool)
+	for _, dp := range data.DataPoints {
+		if values[dp.UnixTime] == nil {
+			values[dp.UnixTime] = make(map[string]float64)
+			labels[dp.UnixTime] = dp.Time
+			if dp.UnixTime > 0 {
+				labels[dp.UnixTime] = time.Unix(dp.UnixTime, 0).In(loc).Format("01-02 15:04")
+			}
+		}
+		values[dp.UnixTime][dp.Type] = dp.Value
+		seriesSet[dp.Type] = true
+	}
+
+	times := make([]int64, 0, len(values))
+	for ts := range values {
+		times = append(times, ts)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if len(times) > maxTableRows {
+		times = times[len(times)-maxTableRows:]
+	}
+
+	series := make([]string, 0, len(seriesSet))
+	for s := range seriesSet {
+		series = append(series, s)
+	}
+	sort.Strings(series)
+	omitted := 0
+	if len(series) > maxTableColumns {
+		omitted = len(series) - maxTableColumns
+		series = series[:maxTableColumns]
+	}
+
+	var b strings.Builder
+	b.WriteString("| 时间 | ")
+	b.WriteString(strings.Join(escapeCells(series), " | "))
+	b.WriteString(" |\n|" + strings.Repeat(" --- |", len(series)+1))
+	for _, ts := range times {
+		b.WriteString("\n| " + labels[ts] + " |")
+		for _, s := range series {
+			cell := "-"
+			if v, ok := values[ts][s]; ok {
+				cell = service.FormatValue(v, "")
+			}
+			b.WriteString(" " + cell + " |")
+		}
+	}
+	if omitted > 0 {
+		b.WriteString(fmt.Sprintf("\n\n其余 %d 个序列未显示", omitted))
+	}
+	return b.String()
+}
+
+// escapeCells 转义表格单元格中的竖线
+func escapeCells(cells []string) []string {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = strings.ReplaceAll(c, "|", "\\|")
+	}
+	return escaped
+}
+
+// dataTimeLine 返回数据时间说明，格式与飞书卡片一致
+func dataTimeLine(report *Report) string {
+	return fmt.Sprintf("⏰ 数据时间: %s", time.Now().In(report.location()).Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,240 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+)
+
+func init() {
+	Register(TypeSlack, slack{})
+}
+
+// Slack Block Kit 的限制
+const (
+	slackMaxBlocks      = 50
+	slackMaxSectionText = 3000
+	slackMaxHeaderText  = 150
+)
+
+// slackAPIBase Slack Web API 地址
+var slackAPIBase = "https://slack.com/api/"
+
+// slackMessage 渲染后的 Slack 消息，images 为需要上传的图表图片
+type slackMessage struct {
+	Text   string                   `json:"text"`
+	Blocks []map[string]interface{} `json:"blocks"`
+	images []slackImage
+}
+
+// slackImage 图表图片
+type slackImage struct {
+	title string
+	data  []byte
+}
+
+// slack Slack 渠道
+// 只填写 Incoming Webhook 地址时图表以表格形式发送；secret 填写 Bot Token 且 options.channel_id 指定频道时，
+// 通过 chat.postMessage 发送消息，并将图表图片上传到该消息的线程中
+type slack struct{}
+
+func (slack) Name() string { return "Slack" }
+
+func (slack) Render(report *Report) (interface{}, error) {
+	msg := &slackMessage{Text: report.Title}
+	msg.Blocks = append(msg.Blocks, map[string]interface{}{
+		"type": "header",
+		"text": map[string]interface{}{"type": "plain_text", "text": truncate(report.Title, slackMaxHeaderText-3)},
+	})
+	// 查询内容的块，超过块数限制时截断，保证按钮和底部信息总是发送
+	var sections []map[string]interface{}
+	addSection := func(text string) {
+		for _, chunk := range splitMarkdown([]string{text}, slackMaxSectionText) {
+			sections = append(sections, map[string]interface{}{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": chunk},
+			})
+		}
+	}
+
+	for _, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		heading := fmt.Sprintf("*%s*", elem.PromQLName)
+		if unit != "" {
+			heading = fmt.Sprintf("*%s* (%s)", elem.PromQLName, unit)
+		}
+		if elem.Link != "" {
+			heading += fmt.Sprintf(" <%s|详情>", elem.Link)
+		}
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			addSection(heading + "\n" + strings.Join(metricLines(elem.TextMetrics, unit), "\n"))
+			continue
+		}
+
+		table := chartTable(elem.ChartData, report.location())
+		if table == "" {
+			addSection(heading + "\n└─ 暂无数据")
+			continue
+		}
+		addSection(heading + "\n```\n" + table + "\n```")
+		image, err := render.PNG(elem.ChartData, render.Options{Unit: unit, Location: report.location()})
+		if err != nil {
+			log.Printf("[channel] 绘制 Slack 图表 %s 失败: %v", elem.PromQLName, err)
+			continue
+		}
+		msg.images = append(msg.images, slackImage{title: elem.PromQLName, data: image})
+	}
+
+	tail := []map[string]interface{}{{"type": "divider"}}
+	if len(report.Buttons) > 0 {
+		buttons := make([]interface{}, 0, len(report.Buttons))
+		for _, b := range report.Buttons {
+			buttons = append(buttons, map[string]interface{}{
+				"type": "button",
+				"text": map[string]interface{}{"type": "plain_text", "text": b.Text},
+				"url":  b.URL,
+			})
+		}
+		tail = append(tail, map[string]interface{}{
+			"type":     "actions",
+			"elements": buttons,
+		})
+	}
+	tail = append(tail, map[string]interface{}{
+		"type":     "context",
+		"elements": []interface{}{map[string]interface{}{"type": "mrkdwn", "text": dataTimeLine(report)}},
+	})
+
+	if available := slackMaxBlocks - len(msg.Blocks) - len(tail); len(sections) > available {
+		log.Printf("[channel] Slack 消息块数 %d 超过限制，截断查询内容", len(msg.Blocks)+len(sections)+len(tail))
+		omitted := len(sections) - (available - 1)
+		sections = append(sections[:available-1], map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("_内容过长，省略了最后 %d 段_", omitted)},
+		})
+	}
+	msg.Blocks = append(msg.Blocks, sections...)
+	msg.Blocks = append(msg.Blocks, tail...)
+	return msg, nil
+}
+
+func (slack) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	msg, ok := payload.(*slackMessage)
+	if !ok {
+		return nil, fmt.Errorf("不支持的 Slack 消息类型 %T", payload)
+	}
+
+	if target.Secret == "" || target.Options.ChannelID == "" {
+		if len(msg.images) > 0 {
+			log.Printf("[channel] Slack WebHook (ID=%d) 未配置 Bot Token 和频道，图表仅以表格形式发送", target.ID)
+		}
+		_, err := postJSON(ctx, target.URL, msg, nil)
+		return nil, err
+	}
+
+	// Bot Token 模式：发送消息后将图表上传到消息线程
+	blocks, err := json.Marshal(msg.Blocks)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	var posted struct {
+		TS string `json:"ts"`
+	}
+	err = slackAPI(ctx, target.Secret, "chat.postMessage", url.Values{
+		"channel": {target.Options.ChannelID},
+		"text":    {msg.Text},
+		"blocks":  {string(blocks)},
+	}, &posted)
+	if err != nil {
+		return nil, err
+	}
+	for i, image := range msg.images {
+		if err := uploadSlackImage(ctx, target.Secret, target.Options.ChannelID, posted.TS, image, i); err != nil {
+			return nil, fmt.Errorf("上传图表 %s 失败: %w", image.title, err)
+		}
+	}
+	return payload, nil
+}
+
+// uploadSlackImage 使用 files.getUploadURLExternal/files.completeUploadExternal 上传图片到消息线程
+func uploadSlackImage(ctx context.Context, token, channelID, threadTS string, image slackImage, index int) error {
+	filename := fmt.Sprintf("chart-%d.png", index+1)
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	err := slackAPI(ctx, token, "files.getUploadURLExternal", url.Values{
+		"filename": {filename},
+		"length":   {strconv.Itoa(len(image.data))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, bytes.NewReader(image.data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("上传文件失败: HTTP %d: %s", resp.StatusCode, truncate(string(body), 200))
+	}
+
+	files, _ := json.Marshal([]map[string]string{{"id": upload.FileID, "title": image.title}})
+	return slackAPI(ctx, token, "files.completeUploadExternal", url.Values{
+		"files":      {string(files)},
+		"channel_id": {channelID},
+		"thread_ts":  {threadTS},
+	}, nil)
+}
+
+// slackAPI 调用 Slack Web API，响应中 ok 为 false 时返回错误；result 不为 nil 时解析响应
+func slackAPI(ctx context.Context, token, method string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackAPIBase+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("解析 Slack %s 响应失败: %w, 响应内容: %s", method, err, truncate(string(body), 200))
+	}
+	if !status.OK {
+		return fmt.Errorf("Slack API %s 错误: %s", method, status.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(body, result)
+	}
+	return nil
+}
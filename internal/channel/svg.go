@@ -0,0 +1,27 @@
+package channel
+
+import (
+	"log"
+
+	"fsvchart-notify/internal/render"
+)
+
+// ChartSVG 服务端绘制的图表图片，用于在预览中查看企业微信、Slack 等渠道发送的图表
+type ChartSVG struct {
+	Name string `json:"name"`
+	SVG  string `json:"svg"`
+}
+
+// ChartSVGs 将报告中有数据的图表元素绘制为 SVG，绘制失败的图表跳过
+func (r *Report) ChartSVGs() []ChartSVG {
+	var charts []ChartSVG
+	for _, elem := range r.chartElements() {
+		data, err := render.SVG(elem.ChartData, render.Options{Unit: r.unitOf(elem), Location: r.location()})
+		if err != nil {
+			log.Printf("[channel] 绘制图表 %s 的 SVG 失败: %v", elem.PromQLName, err)
+			continue
+		}
+		charts = append(charts, ChartSVG{Name: elem.PromQLName, SVG: string(data)})
+	}
+	return charts
+}
@@ -0,0 +1,222 @@
+package channel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+	"fsvchart-notify/internal/service"
+)
+
+func init() {
+	Register(TypeTeams, teams{})
+}
+
+// Teams 消息的限制
+const (
+	teamsMaxPayload   = 28 * 1024 // Incoming Webhook 的消息大小限制
+	teamsChartWidth   = 640
+	teamsChartHeight  = 320
+	teamsCardVersion  = "1.4"
+	teamsCardSchema   = "http://adaptivecards.io/schemas/adaptive-card.json"
+	teamsCardMimeType = "application/vnd.microsoft.card.adaptive"
+)
+
+// teamsColors 飞书卡片标题颜色对应的 Adaptive Card 文本颜色
+var teamsColors = map[string]string{
+	"blue":      "Accent",
+	"wathet":    "Accent",
+	"turquoise": "Accent",
+	"green":     "Good",
+	"yellow":    "Warning",
+	"orange":    "Warning",
+	"red":       "Attention",
+	"carmine":   "Attention",
+}
+
+// teams Microsoft Teams Incoming Webhook 渠道
+// 报告转换为 Adaptive Card：文本元素为 FactSet，图表为内嵌的 PNG 图片，按钮为 Action.OpenUrl
+type teams struct{}
+
+func (teams) Name() string { return "Teams" }
+
+func (teams) Validate(target models.FeishuWebhook) error {
+	if !strings.HasPrefix(target.URL, "https://") {
+		return fmt.Errorf("Teams WebHook 地址必须以 https:// 开头")
+	}
+	return nil
+}
+
+func (teams) Render(report *Report) (interface{}, error) {
+	title := map[string]interface{}{
+		"type":   "TextBlock",
+		"text":   report.Title,
+		"size":   "Large",
+		"weight": "Bolder",
+		"wrap":   true,
+	}
+	if color, ok := teamsColors[report.Template]; ok {
+		title["color"] = color
+	}
+	body := []interface{}{title}
+
+	// 图表先以最新值 FactSet 占位，消息大小允许时再替换为图片
+	type chartBlock struct {
+		index int
+		name  string
+		image map[string]interface{}
+	}
+	var charts []chartBlock
+	for _, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		heading := elem.PromQLName
+		if unit != "" {
+			heading = fmt.Sprintf("%s (%s)", elem.PromQLName, unit)
+		}
+		if elem.Link != "" {
+			heading += fmt.Sprintf(" [详情](%s)", elem.Link)
+		}
+		body = append(body, map[string]interface{}{
+			"type":      "TextBlock",
+			"text":      heading,
+			"weight":    "Bolder",
+			"wrap":      true,
+			"separator": true,
+			"spacing":   "Medium",
+		})
+
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			body = append(body, teamsFactSet(elem.TextMetrics, unit))
+			continue
+		}
+		body = append(body, teamsFactSet(latestMetrics(elem.ChartData), unit))
+		if len(elem.ChartData.DataPoints) == 0 {
+			continue
+		}
+		image, err := render.PNG(elem.ChartData, render.Options{
+			Width:    teamsChartWidth,
+			Height:   teamsChartHeight,
+			Unit:     unit,
+			Location: report.location(),
+		})
+		if err != nil {
+			log.Printf("[channel] 绘制 Teams 图表 %s 失败: %v", elem.PromQLName, err)
+			continue
+		}
+		charts = append(charts, chartBlock{
+			index: len(body) - 1,
+			name:  elem.PromQLName,
+			image: map[string]interface{}{
+				"type":    "Image",
+				"url":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(image),
+				"altText": elem.PromQLName,
+				"size":    "Stretch",
+			},
+		})
+	}
+	body = append(body, map[string]interface{}{
+		"type":      "TextBlock",
+		"text":      dataTimeLine(report),
+		"size":      "Small",
+		"isSubtle":  true,
+		"wrap":      true,
+		"separator": true,
+	})
+
+	card := map[string]interface{}{
+		"$schema": teamsCardSchema,
+		"type":    "AdaptiveCard",
+		"version": teamsCardVersion,
+		"body":    body,
+		"msteams": map[string]interface{}{"width": "Full"},
+	}
+	if len(report.Buttons) > 0 {
+		actions := make([]interface{}, 0, len(report.Buttons))
+		for _, b := range report.Buttons {
+			actions = append(actions, map[string]interface{}{
+				"type":  "Action.OpenUrl",
+				"title": b.Text,
+				"url":   b.URL,
+			})
+		}
+		card["actions"] = actions
+	}
+	message := map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": teamsCardMimeType,
+			"contentUrl":  nil,
+			"content":     card,
+		}},
+	}
+
+	// 按顺序将图表替换为图片，超出消息大小限制的图表保留最新值
+	size, err := jsonSize(message)
+	if err != nil {
+		return nil, err
+	}
+	for _, chart := range charts {
+		placeholder, _ := jsonSize(body[chart.index])
+		imageSize, _ := jsonSize(chart.image)
+		if size-placeholder+imageSize > teamsMaxPayload {
+			log.Printf("[channel] Teams 消息超过 %d 字节，图表 %s 以最新值展示", teamsMaxPayload, chart.name)
+			continue
+		}
+		body[chart.index] = chart.image
+		size += imageSize - placeholder
+	}
+	return message, nil
+}
+
+func (teams) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	body, err := postJSON(ctx, target.URL, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 旧版 Connector 在出错时仍返回 HTTP 200，错误信息在响应内容中
+	if text := string(body); strings.Contains(text, "HTTP error") || strings.Contains(text, "Webhook message delivery failed") {
+		return nil, fmt.Errorf("Teams 错误: %s", truncate(text, 200))
+	}
+	return payload, nil
+}
+
+// teamsFactSet 将最新值转换为 FactSet，没有数据时返回提示文本
+func teamsFactSet(metrics []service.LatestMetric, unit string) map[string]interface{} {
+	if len(metrics) == 0 {
+		return map[string]interface{}{"type": "TextBlock", "text": "暂无数据", "isSubtle": true}
+	}
+	facts := make([]map[string]string, 0, len(metrics))
+	for _, metric := range sortedMetrics(metrics) {
+		facts = append(facts, map[string]string{"title": metric.Label, "value": service.FormatValue(metric.Value, unit)})
+	}
+	return map[string]interface{}{"type": "FactSet", "facts": facts}
+}
+
+// latestMetrics 返回图表中每个序列的最新值
+func latestMetrics(data *models.QueryDataPoints) []service.LatestMetric {
+	latest := make(map[string]models.DataPoint)
+	for _, dp := range data.DataPoints {
+		if cur, ok := latest[dp.Type]; !ok || dp.UnixTime >= cur.UnixTime {
+			latest[dp.Type] = dp
+		}
+	}
+	metrics := make([]service.LatestMetric, 0, len(latest))
+	for name, dp := range latest {
+		metrics = append(metrics, service.LatestMetric{Label: name, Value: dp.Value})
+	}
+	return metrics
+}
+
+// jsonSize 返回 JSON 编码后的字节数
+func jsonSize(v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return len(data), nil
+}
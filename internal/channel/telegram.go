@@ -0,0 +1,428 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+)
+
+func init() {
+	Register(TypeTelegram, telegram{})
+}
+
+// Telegram Bot API 的限制
+const (
+	telegramMaxText    = 4096
+	telegramMaxCaption = 1024
+	telegramMaxMedia   = 10 // sendMediaGroup 一次最多发送的图片数
+	telegramMaxRetries = 3  // 触发限流（HTTP 429）时的最大重试次数
+)
+
+// 发送频率限制：同一会话每秒不超过 1 条，群组每分钟不超过 20 条
+const (
+	telegramChatInterval  = time.Second
+	telegramGroupInterval = 3 * time.Second
+)
+
+// telegramAPIBase 默认的 Bot API 地址，渠道的 url 不为空时使用 url
+var telegramAPIBase = "https://api.telegram.org"
+
+// telegramMessage 渲染后的 Telegram 消息
+type telegramMessage struct {
+	Texts   []string // MarkdownV2 文本消息，按长度限制拆分
+	Photos  []telegramPhoto
+	Buttons []models.Button // 附加在最后一条文本消息上的按钮，每个按钮一行
+}
+
+// telegramPhoto 图表图片，Caption 为 MarkdownV2 格式，长度不超过 telegramMaxCaption
+type telegramPhoto struct {
+	Caption string
+	Data    []byte
+}
+
+// telegram Telegram 机器人渠道
+// secret 填写 Bot Token，options.chat_id 指定会话；文本以 MarkdownV2 发送，图表以图片发送
+type telegram struct{}
+
+func (telegram) Name() string { return "Telegram" }
+
+func (telegram) Validate(target models.FeishuWebhook) error {
+	if target.Secret == "" {
+		return fmt.Errorf("Telegram 渠道需要在 secret 中填写 Bot Token")
+	}
+	if strings.TrimSpace(target.Options.ChatID) == "" {
+		return fmt.Errorf("Telegram 渠道需要配置 options.chat_id")
+	}
+	if target.URL != "" && !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return fmt.Errorf("Telegram Bot API 地址必须以 http:// 或 https:// 开头")
+	}
+	return nil
+}
+
+func (telegram) Render(report *Report) (interface{}, error) {
+	msg := &telegramMessage{}
+	sections := []string{"*" + escapeMarkdownV2(report.Title) + "*"}
+	for _, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		heading := telegramHeading(elem.PromQLName, unit, elem.Link)
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			sections = append(sections, heading+"\n"+escapeMarkdownV2(strings.Join(metricLines(elem.TextMetrics, unit), "\n")))
+			continue
+		}
+		if len(elem.ChartData.DataPoints) == 0 {
+			sections = append(sections, heading+"\n"+escapeMarkdownV2("└─ 暂无数据"))
+			continue
+		}
+		image, err := render.PNG(elem.ChartData, render.Options{Unit: unit, Location: report.location()})
+		if err != nil {
+			// 图片绘制失败时以表格文本发送
+			log.Printf("[channel] 绘制 Telegram 图表 %s 失败: %v", elem.PromQLName, err)
+			sections = append(sections, heading+"\n```\n"+escapePreMarkdownV2(chartTable(elem.ChartData, report.location()))+"\n```")
+			continue
+		}
+		msg.Photos = append(msg.Photos, telegramPhoto{Caption: telegramCaption(elem.PromQLName, unit, elem.Link), Data: image})
+	}
+	sections = append(sections, "_"+escapeMarkdownV2(dataTimeLine(report))+"_")
+	msg.Texts = splitTelegramMarkdown(sections, telegramMaxText)
+	msg.Buttons = report.Buttons
+	return msg, nil
+}
+
+func (telegram) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	msg, ok := payload.(*telegramMessage)
+	if !ok {
+		return nil, fmt.Errorf("不支持的 Telegram 消息类型 %T", payload)
+	}
+	bot := telegramBot{base: target.URL, token: target.Secret, chatID: strings.TrimSpace(target.Options.ChatID)}
+	if bot.base == "" {
+		bot.base = telegramAPIBase
+	}
+
+	for i, text := range msg.Texts {
+		params := map[string]interface{}{
+			"chat_id":                  bot.chatID,
+			"text":                     text,
+			"parse_mode":               "MarkdownV2",
+			"disable_web_page_preview": true,
+		}
+		// 按钮附加在最后一条文本消息上
+		if i == len(msg.Texts)-1 && len(msg.Buttons) > 0 {
+			keyboard := make([][]map[string]string, 0, len(msg.Buttons))
+			for _, b := range msg.Buttons {
+				keyboard = append(keyboard, []map[string]string{{"text": b.Text, "url": b.URL}})
+			}
+			params["reply_markup"] = map[string]interface{}{"inline_keyboard": keyboard}
+		}
+		if err := bot.call(ctx, "sendMessage", params, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for start := 0; start < len(msg.Photos); start += telegramMaxMedia {
+		end := start + telegramMaxMedia
+		if end > len(msg.Photos) {
+			end = len(msg.Photos)
+		}
+		if err := bot.sendPhotos(ctx, msg.Photos[start:end]); err != nil {
+			return nil, fmt.Errorf("发送图表图片失败: %w", err)
+		}
+	}
+	return payload, nil
+}
+
+// telegramBot 一个 Bot Token 和会话的组合
+type telegramBot struct {
+	base   string
+	token  string
+	chatID string
+}
+
+// sendPhotos 发送一组图片，单张使用 sendPhoto，多张使用 sendMediaGroup
+func (b telegramBot) sendPhotos(ctx context.Context, photos []telegramPhoto) error {
+	if len(photos) == 1 {
+		return b.call(ctx, "sendPhoto", map[string]interface{}{
+			"chat_id":    b.chatID,
+			"caption":    photos[0].Caption,
+			"parse_mode": "MarkdownV2",
+		}, map[string][]byte{"photo": photos[0].Data})
+	}
+
+	media := make([]map[string]string, len(photos))
+	files := make(map[string][]byte, len(photos))
+	for i, photo := range photos {
+		name := fmt.Sprintf("chart%d", i+1)
+		media[i] = map[string]string{
+			"type":       "photo",
+			"media":      "attach://" + name,
+			"caption":    photo.Caption,
+			"parse_mode": "MarkdownV2",
+		}
+		files[name] = photo.Data
+	}
+	return b.call(ctx, "sendMediaGroup", map[string]interface{}{"chat_id": b.chatID, "media": media}, files)
+}
+
+// call 调用 Bot API，files 不为空时以 multipart/form-data 上传
+// 调用前按会话限制发送频率，触发限流时按响应中的 retry_after 等待后重试
+func (b telegramBot) call(ctx context.Context, method string, params map[string]interface{}, files map[string][]byte) error {
+	contentType, body, err := encodeTelegramRequest(params, files)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimRight(b.base, "/") + "/bot" + b.token + "/" + method
+
+	for attempt := 0; ; attempt++ {
+		if err := telegramLimiter.wait(ctx, b.token+"|"+b.chatID, telegramInterval(b.chatID)); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			// 错误信息中的 URL 包含 Bot Token，不直接返回
+			return fmt.Errorf("Telegram API %s 请求失败: %v", method, errors.Unwrap(err))
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("[channel] Telegram %s, payload size: %d bytes, response status: %d", method, len(body), resp.StatusCode)
+
+		var result struct {
+			OK          bool   `json:"ok"`
+			ErrorCode   int    `json:"error_code"`
+			Description string `json:"description"`
+			Parameters  struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return fmt.Errorf("解析 Telegram %s 响应失败: HTTP %d: %s", method, resp.StatusCode, truncate(string(respBody), 200))
+		}
+		if result.OK {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= telegramMaxRetries {
+			return fmt.Errorf("Telegram API %s 错误: %d %s", method, result.ErrorCode, result.Description)
+		}
+
+		wait := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if wait <= 0 {
+			wait = time.Second
+		}
+		log.Printf("[channel] Telegram %s 触发限流，%v 后重试 (%d/%d)", method, wait, attempt+1, telegramMaxRetries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// encodeTelegramRequest 编码请求参数，没有文件时使用 JSON，否则使用 multipart/form-data
+func encodeTelegramRequest(params map[string]interface{}, files map[string][]byte) (string, []byte, error) {
+	if len(files) == 0 {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return "", nil, fmt.Errorf("序列化消息失败: %w", err)
+		}
+		return "application/json", data, nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for key, value := range params {
+		field, ok := value.(string)
+		if !ok {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("序列化参数 %s 失败: %w", key, err)
+			}
+			field = string(data)
+		}
+		if err := w.WriteField(key, field); err != nil {
+			return "", nil, err
+		}
+	}
+	for name, data := range files {
+		part, err := w.CreateFormFile(name, name+".png")
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := part.Write(data); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return w.FormDataContentType(), buf.Bytes(), nil
+}
+
+// telegramInterval 返回会话的最小发送间隔，群组和频道的 ID 以 - 或 @ 开头
+func telegramInterval(chatID string) time.Duration {
+	if strings.HasPrefix(chatID, "-") || strings.HasPrefix(chatID, "@") {
+		return telegramGroupInterval
+	}
+	return telegramChatInterval
+}
+
+// rateLimiter 按 key 限制调用间隔
+type rateLimiter struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+var telegramLimiter = &rateLimiter{next: make(map[string]time.Time)}
+
+// wait 等待直到 key 可以再次调用，并预留下一次调用的时间
+func (l *rateLimiter) wait(ctx context.Context, key string, interval time.Duration) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[key]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[key] = at.Add(interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// telegramHeading 返回 MarkdownV2 格式的查询名称和单位，查询设置了链接时附加详情链接
+func telegramHeading(name, unit, link string) string {
+	heading := "*" + escapeMarkdownV2(name) + "*"
+	if unit != "" {
+		heading += " " + escapeMarkdownV2("("+unit+")")
+	}
+	if link != "" {
+		// 链接部分只需转义 ) 和 \
+		heading += " [详情](" + strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(link) + ")"
+	}
+	return heading
+}
+
+// telegramCaption 返回图片的 MarkdownV2 标题，超过长度限制时在转义前截断查询名称，仍然超长时去掉详情链接
+func telegramCaption(name, unit, link string) string {
+	runes := []rune(name)
+	for _, l := range []string{link, ""} {
+		for n := len(runes); n >= 0; n-- {
+			truncated := string(runes[:n])
+			if n < len(runes) {
+				truncated += "…"
+			}
+			if caption := telegramHeading(truncated, unit, l); len(caption) <= telegramMaxCaption {
+				return caption
+			}
+		}
+	}
+	return truncateMarkdownV2(telegramHeading("", unit, ""), telegramMaxCaption)
+}
+
+// telegramFence MarkdownV2 代码块的开始和结束标记
+const telegramFence = "```"
+
+// splitTelegramMarkdown 将 MarkdownV2 段落合并为不超过 limit 字节的若干条消息
+// 与 splitMarkdown 相同，过长的段落按行拆分；代码块被拆分到多条消息时，每条消息中补齐代码块的结束和开始标记
+func splitTelegramMarkdown(sections []string, limit int) []string {
+	closing := "\n" + telegramFence
+	// 续上的代码块需要额外的开始和结束标记
+	lineLimit := limit - 2*len(closing)
+
+	var chunks []string
+	var current strings.Builder
+	empty := true // current 中没有内容，或只有续上的代码块开始标记
+	inFence := false
+	add := func(part, sep string, fence bool) {
+		reserve := 0
+		if inFence != fence {
+			// 写入后仍在代码块中，为结束标记预留空间
+			reserve = len(closing)
+		}
+		if !empty && current.Len()+len(sep)+len(part)+reserve > limit {
+			if inFence {
+				current.WriteString(closing)
+			}
+			chunks = append(chunks, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			empty = true
+			if inFence {
+				current.WriteString(telegramFence)
+			}
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(part)
+		empty = false
+		if fence {
+			inFence = !inFence
+		}
+	}
+
+	for _, section := range sections {
+		if len(section) <= limit {
+			add(section, "\n\n", false)
+			continue
+		}
+		sep := "\n\n"
+		for _, line := range strings.Split(section, "\n") {
+			add(truncateMarkdownV2(line, lineLimit), sep, strings.HasPrefix(line, telegramFence))
+			sep = "\n"
+		}
+	}
+	if !empty {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// truncateMarkdownV2 将 MarkdownV2 文本截断到不超过 n 字节，不拆分多字节字符，也不在转义符 \ 和被转义的字符之间截断
+func truncateMarkdownV2(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = truncateUTF8(s, n)
+	// 末尾连续的 \ 为奇数个时，最后一个是被截断的转义符
+	if trailing := len(s) - len(strings.TrimRight(s, "\\")); trailing%2 == 1 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// escapeMarkdownV2 转义 MarkdownV2 的特殊字符
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapePreMarkdownV2 转义代码块中的特殊字符
+func escapePreMarkdownV2(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
@@ -0,0 +1,252 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+func init() {
+	Register(TypeWebhook, webhook{})
+}
+
+// defaultBodyTemplate 未配置模板时发送完整的数据模型
+const defaultBodyTemplate = "{{json .}}"
+
+// WebhookData 通用 WebHook 模板的数据模型
+type WebhookData struct {
+	Task       WebhookTask     `json:"task"`
+	Title      string          `json:"title"`
+	Mode       string          `json:"mode"` // chart/text/hybrid
+	RunAt      time.Time       `json:"run_at"`
+	Start      time.Time       `json:"start"` // 图表查询的时间范围
+	End        time.Time       `json:"end"`
+	ButtonText string          `json:"button_text"` // 第一个按钮，兼容只有一个按钮时的模板
+	ButtonURL  string          `json:"button_url"`
+	Buttons    []models.Button `json:"buttons"`
+	Queries    []WebhookQuery  `json:"queries"`
+}
+
+// WebhookTask 推送任务信息
+type WebhookTask struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebhookQuery 单个查询的结果，文本模式填充 Metrics，图表模式填充 Series
+type WebhookQuery struct {
+	Name      string                 `json:"name"`
+	Query     string                 `json:"query"`
+	Mode      string                 `json:"mode"` // chart/text
+	Unit      string                 `json:"unit"`
+	Link      string                 `json:"link,omitempty"` // 查询的详情链接
+	ChartType string                 `json:"chart_type,omitempty"`
+	Metrics   []service.LatestMetric `json:"metrics,omitempty"`
+	Series    []WebhookSeries        `json:"series,omitempty"`
+}
+
+// WebhookSeries 图表中的一个序列，Points 按时间排序
+type WebhookSeries struct {
+	Name   string             `json:"name"`
+	Points []models.DataPoint `json:"points"`
+}
+
+// webhookFuncs 模板中可用的函数
+var webhookFuncs = template.FuncMap{
+	// json 将任意值编码为 JSON
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// formatValue 格式化数值并添加单位，如 {{formatValue .Value "%"}}
+	"formatValue": service.FormatValue,
+	// formatTime 按 Go 时间格式格式化 time.Time 或 Unix 时间戳，如 {{formatTime .RunAt "2006-01-02 15:04"}}
+	"formatTime": func(v interface{}, layout string) (string, error) {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(layout), nil
+		case int64:
+			return time.Unix(t, 0).Format(layout), nil
+		default:
+			return "", fmt.Errorf("formatTime 不支持 %T", v)
+		}
+	},
+	"join": strings.Join,
+}
+
+// webhook 通用 WebHook 渠道，请求体由用户配置的 text/template 模板生成
+type webhook struct{}
+
+func (webhook) Name() string { return "通用WebHook" }
+
+func (webhook) Validate(target models.FeishuWebhook) error {
+	if !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return fmt.Errorf("通用 WebHook 的地址必须以 http:// 或 https:// 开头")
+	}
+	switch webhookMethod(target.Options) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("不支持的请求方法 %q，可选值: POST、PUT、PATCH", target.Options.Method)
+	}
+	for _, status := range target.Options.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("无效的期望状态码: %d", status)
+		}
+	}
+	if _, err := parseBodyTemplate(target.Options.BodyTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (webhook) Render(report *Report) (interface{}, error) {
+	return newWebhookData(report), nil
+}
+
+// newWebhookData 将报告转换为模板的数据模型，查询顺序与飞书混合卡片一致
+func newWebhookData(report *Report) *WebhookData {
+	data := &WebhookData{
+		Task:    WebhookTask{ID: report.TaskID, Name: report.TaskName},
+		Title:   report.Title,
+		Mode:    report.Mode,
+		RunAt:   report.RunAt,
+		Start:   report.Start,
+		End:     report.End,
+		Buttons: report.Buttons,
+		Queries: []WebhookQuery{},
+	}
+	if data.Buttons == nil {
+		data.Buttons = []models.Button{}
+	}
+	data.ButtonText, data.ButtonURL = service.FirstButton(report.Buttons)
+	for _, elem := range report.orderedElements() {
+		q := WebhookQuery{Name: elem.PromQLName, Query: elem.Query, Mode: "text", Unit: report.unitOf(elem), Link: elem.Link}
+		if elem.DisplayMode == "chart" && elem.ChartData != nil {
+			q.Mode = "chart"
+			q.ChartType = elem.ChartData.ChartType
+			q.Series = webhookSeries(elem.ChartData.DataPoints)
+		} else {
+			q.Metrics = sortedMetrics(elem.TextMetrics)
+		}
+		data.Queries = append(data.Queries, q)
+	}
+	return data
+}
+
+func (webhook) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	data, ok := payload.(*WebhookData)
+	if !ok {
+		return nil, fmt.Errorf("不支持的通用 WebHook 消息类型 %T", payload)
+	}
+	opts := target.Options
+	tmpl, err := parseBodyTemplate(opts.BodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("渲染请求模板失败: %w", err)
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	method := webhookMethod(opts)
+
+	return payload, service.RetryWithBackoff(ctx, "GenericWebhook", func(attempt int) error {
+		req, err := http.NewRequestWithContext(ctx, method, target.URL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range opts.Headers {
+			req.Header.Set(k, v)
+		}
+		if target.Secret != "" {
+			// 接收方可使用相同的密钥校验请求体
+			mac := hmac.New(sha256.New, []byte(target.Secret))
+			mac.Write(body.Bytes())
+			req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("[GenericWebhook] %s %s, payload size: %d bytes, response status: %d (attempt %d)",
+			method, redactURL(target.URL), body.Len(), resp.StatusCode, attempt+1)
+
+		if !expectedStatus(opts.ExpectedStatus, resp.StatusCode) {
+			return fmt.Errorf("非预期的响应状态 HTTP %d: %s", resp.StatusCode, truncate(string(respBody), 200))
+		}
+		return nil
+	})
+}
+
+// webhookMethod 返回请求方法，默认 POST
+func webhookMethod(opts models.ChannelOptions) string {
+	if opts.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(opts.Method)
+}
+
+// parseBodyTemplate 解析请求体模板，为空时使用默认模板
+func parseBodyTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = defaultBodyTemplate
+	}
+	tmpl, err := template.New("body").Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("请求模板格式无效: %w", err)
+	}
+	return tmpl, nil
+}
+
+// expectedStatus 判断响应状态码是否符合预期，未配置时接受所有 2xx
+func expectedStatus(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range expected {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSeries 按序列名称分组数据点
+func webhookSeries(points []models.DataPoint) []WebhookSeries {
+	index := make(map[string]int)
+	var series []WebhookSeries
+	for _, p := range points {
+		i, ok := index[p.Type]
+		if !ok {
+			i = len(series)
+			index[p.Type] = i
+			series = append(series, WebhookSeries{Name: p.Type})
+		}
+		series[i].Points = append(series[i].Points, p)
+	}
+	for _, s := range series {
+		sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].UnixTime < s.Points[j].UnixTime })
+	}
+	return series
+}
@@ -0,0 +1,149 @@
+package channel
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/render"
+)
+
+func init() {
+	Register(TypeWeCom, weCom{})
+}
+
+// 企业微信群机器人的限制
+const (
+	weComWebhookPrefix   = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
+	weComMarkdownMaxSize = 4096            // markdown 内容最大字节数
+	weComImageMaxSize    = 2 * 1024 * 1024 // 图片编码前最大字节数
+)
+
+// weCom 企业微信群机器人
+// 文本元素渲染为 markdown 消息，图表渲染为 PNG 图片消息，超出长度限制的 markdown 拆分为多条发送
+type weCom struct{}
+
+func (weCom) Name() string { return "企业微信" }
+
+func (weCom) Render(report *Report) (interface{}, error) {
+	var messages []map[string]interface{}
+	sections := []string{"### " + report.Title}
+
+	flush := func() {
+		for _, content := range splitMarkdown(sections, weComMarkdownMaxSize) {
+			messages = append(messages, map[string]interface{}{
+				"msgtype":  "markdown",
+				"markdown": map[string]interface{}{"content": content},
+			})
+		}
+		sections = nil
+	}
+
+	for _, elem := range report.orderedElements() {
+		unit := report.unitOf(elem)
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			sections = append(sections, markdownList(markdownHeading(elem.PromQLName, unit, elem.Link), elem.TextMetrics, unit))
+			continue
+		}
+		if len(elem.ChartData.DataPoints) == 0 {
+			sections = append(sections, markdownHeading(elem.PromQLName, unit, elem.Link)+"\n\n└─ 暂无数据")
+			continue
+		}
+
+		image, err := render.PNG(elem.ChartData, render.Options{Unit: unit, Location: report.location()})
+		if err != nil {
+			return nil, fmt.Errorf("绘制图表 %s 失败: %w", elem.PromQLName, err)
+		}
+		if len(image) > weComImageMaxSize {
+			return nil, fmt.Errorf("图表 %s 的图片大小 %d 字节超过企业微信 2MB 限制", elem.PromQLName, len(image))
+		}
+		// 图片消息没有标题，先发送图表名称再发送图片
+		sections = append(sections, markdownHeading(elem.PromQLName, unit, elem.Link))
+		flush()
+		sum := md5.Sum(image)
+		messages = append(messages, map[string]interface{}{
+			"msgtype": "image",
+			"image": map[string]interface{}{
+				"base64": base64.StdEncoding.EncodeToString(image),
+				"md5":    hex.EncodeToString(sum[:]),
+			},
+		})
+	}
+
+	footer := dataTimeLine(report)
+	if len(report.Buttons) > 0 {
+		footer = markdownButtons(report.Buttons) + "\n\n" + footer
+	}
+	sections = append(sections, footer)
+	flush()
+	return messages, nil
+}
+
+func (weCom) Send(ctx context.Context, target models.FeishuWebhook, report *Report, payload interface{}) (interface{}, error) {
+	messages, ok := payload.([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("不支持的企业微信消息类型 %T", payload)
+	}
+	webhookURL := target.URL
+	if !strings.Contains(webhookURL, "://") {
+		// 只填写了机器人 key
+		webhookURL = weComWebhookPrefix + strings.TrimSpace(webhookURL)
+	}
+
+	for i, msg := range messages {
+		body, err := postJSON(ctx, webhookURL, msg, nil)
+		if err == nil {
+			err = checkErrCode("企业微信", body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("发送第 %d/%d 条消息失败: %w", i+1, len(messages), err)
+		}
+	}
+	return payload, nil
+}
+
+// splitMarkdown 将段落合并为不超过 limit 字节的若干条消息
+// 单个段落超出限制时按行拆分，单行超出限制时截断
+func splitMarkdown(sections []string, limit int) []string {
+	var chunks []string
+	var current strings.Builder
+	add := func(part, sep string) {
+		if current.Len() > 0 && current.Len()+len(sep)+len(part) > limit {
+			chunks = append(chunks, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(part)
+	}
+
+	for _, section := range sections {
+		if len(section) <= limit {
+			add(section, "\n\n")
+			continue
+		}
+		for _, line := range strings.Split(section, "\n") {
+			add(truncateUTF8(line, limit), "\n")
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// truncateUTF8 将字符串截断到不超过 n 字节，不拆分多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xc0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
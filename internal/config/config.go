@@ -11,7 +11,21 @@ type Config struct {
 		Address string `yaml:"address"`
 		Port    int    `yaml:"port"`
 	} `yaml:"server"`
-	Auth AuthConfig `yaml:"auth"`
+	Auth       AuthConfig       `yaml:"auth"`
+	SendRecord SendRecordConfig `yaml:"send_record"`
+	Render     RenderConfig     `yaml:"render"`
+}
+
+// RenderConfig 服务端图表绘制配置
+type RenderConfig struct {
+	// FontPath 图片文字使用的字体文件（TTF/OTF/TTC），为空时自动查找常见的中文字体
+	FontPath string `yaml:"font_path"`
+}
+
+// SendRecordConfig 发送记录配置
+type SendRecordConfig struct {
+	// RetentionDays 发送记录保留天数，未配置时为 30 天，小于 0 表示永久保留
+	RetentionDays int `yaml:"retention_days"`
 }
 
 // AuthConfig 认证配置
@@ -72,6 +86,9 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Auth.LDAP.DefaultRole == "" {
 		cfg.Auth.LDAP.DefaultRole = "user"
 	}
+	if cfg.SendRecord.RetentionDays == 0 {
+		cfg.SendRecord.RetentionDays = 30
+	}
 
 	return cfg, nil
 }
@@ -13,7 +13,7 @@ import (
 )
 
 // 当前数据库结构版本
-const CurrentSchemaVersion = 14 // 版本14: 添加 auth_source 字段区分本地用户和 LDAP 用户
+const CurrentSchemaVersion = 28 // 版本28: push_task_promql 表添加 stats 字段
 
 // 表结构定义，用于验证和修复
 type TableStructure struct {
@@ -38,6 +38,13 @@ var currentStructures = map[string]TableStructure{
 			"button_url":          "TEXT",
 			"show_data_label":     "INTEGER",
 			"push_mode":           "TEXT",
+			"cron_expr":           "TEXT",
+			"timezone":            "TEXT",
+			"catchup_policy":      "TEXT",
+			"catchup_grace":       "INTEGER",
+			"mentions":            "TEXT",
+			"card_layout_id":      "INTEGER",
+			"buttons":             "TEXT",
 		},
 	},
 	"push_task_promql": {
@@ -52,6 +59,9 @@ var currentStructures = map[string]TableStructure{
 			"initial_unit":        "TEXT",
 			"display_order":       "INTEGER",
 			"display_mode":        "TEXT",
+			"mentions":            "TEXT",
+			"link":                "TEXT",
+			"stats":               "TEXT",
 		},
 	},
 	// 其他表可以按需添加...
@@ -321,6 +331,182 @@ var migrations = []Migration{
 		ALTER TABLE users ADD COLUMN auth_source TEXT DEFAULT 'local';
 		`,
 	},
+	{
+		Version:     15,
+		Description: "添加 cron_expr 字段支持 cron 表达式调度",
+		SQL: `
+		-- 为 push_task 表添加 cron_expr 字段
+		-- 标准 5 段 cron 表达式（分 时 日 月 周），与 push_task_send_time 中的发送时间同时生效
+		-- 默认值为空字符串，表示仅使用发送时间调度
+		ALTER TABLE push_task ADD COLUMN cron_expr TEXT DEFAULT '';
+		`,
+	},
+	{
+		Version:     16,
+		Description: "添加 timezone 字段支持任务级时区",
+		SQL: `
+		-- 为 push_task 表添加 timezone 字段
+		-- IANA 时区名称（如 Asia/Shanghai），用于解释发送时间、cron 表达式以及图表横轴时间
+		-- 默认值为空字符串，表示使用服务器本地时区
+		ALTER TABLE push_task ADD COLUMN timezone TEXT DEFAULT '';
+		`,
+	},
+	{
+		Version:     17,
+		Description: "添加错过调度时间点的补发策略和调度状态表",
+		SQL: `
+		-- 为 push_task 表添加补发策略字段
+		-- catchup_policy: skip（跳过，默认）、once（只补发一次）、all（每个错过的时间点各补发一次）
+		-- catchup_grace: 补发宽限期（秒），超过宽限期的时间点不再补发
+		ALTER TABLE push_task ADD COLUMN catchup_policy TEXT DEFAULT 'skip';
+		ALTER TABLE push_task ADD COLUMN catchup_grace INTEGER DEFAULT 3600;
+
+		-- 调度器状态表，记录最后一次调度检查时间等键值信息
+		CREATE TABLE IF NOT EXISTS scheduler_state (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+	},
+	{
+		Version:     18,
+		Description: "添加 task_run 表记录任务执行历史",
+		SQL: `
+		-- 每次任务执行一条记录
+		-- trigger_type: schedule/catchup/manual/api
+		-- status: running/success/partial/failed/skipped
+		-- query_results/deliveries 为 JSON 数组，记录每个查询的获取结果和每个 WebHook 的发送结果
+		CREATE TABLE IF NOT EXISTS task_run (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			trigger_type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			scheduled_at TEXT DEFAULT '',
+			started_at TEXT NOT NULL,
+			finished_at TEXT DEFAULT '',
+			duration_ms INTEGER DEFAULT 0,
+			query_results TEXT DEFAULT '[]',
+			deliveries TEXT DEFAULT '[]',
+			payload_hash TEXT DEFAULT '',
+			message TEXT DEFAULT ''
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_task_run_task_id ON task_run(task_id, id);
+		`,
+	},
+	{
+		Version:     19,
+		Description: "send_record 表添加任务、WebHook 关联字段",
+		SQL: `
+		-- 发送记录改为持久化存储，按任务和 WebHook 过滤
+		ALTER TABLE send_record ADD COLUMN task_id INTEGER DEFAULT 0;
+		ALTER TABLE send_record ADD COLUMN webhook_id INTEGER DEFAULT 0;
+		ALTER TABLE send_record ADD COLUMN button_text TEXT DEFAULT '';
+		ALTER TABLE send_record ADD COLUMN button_url TEXT DEFAULT '';
+
+		CREATE INDEX IF NOT EXISTS idx_send_record_task_id ON send_record(task_id);
+		`,
+	},
+	{
+		Version:     20,
+		Description: "metrics_source 表添加认证配置",
+		SQL: `
+		-- auth_type: none/basic/bearer
+		-- headers 为 JSON 对象，ca_cert/client_cert/client_key 为 PEM 内容
+		ALTER TABLE metrics_source ADD COLUMN auth_type TEXT DEFAULT 'none';
+		ALTER TABLE metrics_source ADD COLUMN username TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN password TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN bearer_token TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN headers TEXT DEFAULT '{}';
+		ALTER TABLE metrics_source ADD COLUMN ca_cert TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN client_cert TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN client_key TEXT DEFAULT '';
+		ALTER TABLE metrics_source ADD COLUMN insecure_skip_verify INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		Version:     21,
+		Description: "metrics_source 表添加数据源类型和查询选项",
+		SQL: `
+		-- type: prometheus/victoriametrics/thanos
+		-- options 为 JSON 对象，如 extra_label、dedup、partial_response、tenant_id
+		ALTER TABLE metrics_source ADD COLUMN type TEXT DEFAULT 'prometheus';
+		ALTER TABLE metrics_source ADD COLUMN options TEXT DEFAULT '{}';
+		`,
+	},
+	{
+		Version:     22,
+		Description: "promql 表添加 source_id 字段",
+		SQL: `
+		-- 查询可以指定独立的数据源（如 Loki），为 0 时使用任务的数据源
+		ALTER TABLE promql ADD COLUMN source_id INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		Version:     23,
+		Description: "feishu_webhook 表添加 type、secret 字段",
+		SQL: `
+		-- type: 通知渠道类型，如 feishu、dingtalk
+		-- secret: 机器人加签密钥，为空时不签名
+		ALTER TABLE feishu_webhook ADD COLUMN type TEXT DEFAULT 'feishu';
+		ALTER TABLE feishu_webhook ADD COLUMN secret TEXT DEFAULT '';
+		`,
+	},
+	{
+		Version:     24,
+		Description: "feishu_webhook 表添加 options 字段",
+		SQL: `
+		-- options 为 JSON 对象，保存渠道相关的选项，如 Slack channel_id
+		ALTER TABLE feishu_webhook ADD COLUMN options TEXT DEFAULT '{}';
+		`,
+	},
+	{
+		Version:     25,
+		Description: "push_task、push_task_promql 表添加 mentions 字段",
+		SQL: `
+		-- mentions 为 JSON 数组，每项为 {"user": "ou_xxx", "op": ">", "threshold": 90}，op 为空时无条件 @
+		ALTER TABLE push_task ADD COLUMN mentions TEXT DEFAULT '[]';
+		ALTER TABLE push_task_promql ADD COLUMN mentions TEXT DEFAULT '[]';
+		`,
+	},
+	{
+		Version:     26,
+		Description: "添加 card_layout 表，push_task 表添加 card_layout_id 字段",
+		SQL: `
+		-- 自定义卡片布局，kind: template（生成飞书卡片 JSON 的 text/template 模板）/blocks（块列表 JSON）
+		CREATE TABLE IF NOT EXISTS card_layout (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			kind TEXT NOT NULL DEFAULT 'blocks',
+			content TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		-- 任务使用的卡片布局，为 0 时使用默认卡片结构
+		ALTER TABLE push_task ADD COLUMN card_layout_id INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		Version:     27,
+		Description: "push_task 表添加 buttons 字段，push_task_promql 表添加 link 字段",
+		SQL: `
+		-- 任务的按钮列表（JSON），为空时使用 button_text/button_url
+		ALTER TABLE push_task ADD COLUMN buttons TEXT DEFAULT '[]';
+		-- PromQL 的详情链接，可使用 {{.Start}}、{{.End}}、{{.Query}} 等模板变量
+		ALTER TABLE push_task_promql ADD COLUMN link TEXT DEFAULT '';
+		`,
+	},
+	{
+		Version:     28,
+		Description: "push_task_promql 表添加 stats 字段",
+		SQL: `
+		-- 图表下方显示的序列统计项（JSON 数组）：min、max、avg、last、p95、sum
+		ALTER TABLE push_task_promql ADD COLUMN stats TEXT DEFAULT '[]';
+		`,
+	},
 }
 
 var (
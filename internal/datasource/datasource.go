@@ -0,0 +1,119 @@
+// Package datasource 封装不同类型指标数据源的查询接口
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 数据源类型
+const (
+	TypePrometheus      = "prometheus"
+	TypeVictoriaMetrics = "victoriametrics"
+	TypeThanos          = "thanos"
+)
+
+// Datasource 指标数据源
+type Datasource interface {
+	// RangeQuery 查询时间范围内的数据，结果中每个序列使用 Values
+	RangeQuery(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error)
+	// InstantQuery 查询某一时刻的数据，ts 为零值时使用数据源当前时间，结果中每个序列使用 Value
+	InstantQuery(ctx context.Context, query string, ts time.Time) (*QueryResponse, error)
+	// LabelValues 查询标签的所有取值
+	LabelValues(ctx context.Context, label string) ([]string, error)
+	// Health 检查数据源是否可用（包括认证配置是否正确）
+	Health(ctx context.Context) error
+}
+
+// Series 查询结果中的一个时间序列，格式与 Prometheus HTTP API 一致
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values"` // 范围查询: [[timestamp, "value"], ...]
+	Value  []interface{}     `json:"value"`  // 即时查询: [timestamp, "value"]
+}
+
+// QueryResponse 查询结果，格式与 Prometheus HTTP API 一致
+type QueryResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string   `json:"resultType"`
+		Result     []Series `json:"result"`
+	} `json:"data"`
+	ErrorType string   `json:"errorType,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// Factory 根据数据源配置创建 Datasource
+type Factory func(source models.MetricsSource) (Datasource, error)
+
+var (
+	factories   = make(map[string]Factory)
+	factoriesMu sync.RWMutex
+)
+
+// Register 注册数据源类型，重复注册会覆盖
+func Register(typ string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[typ] = factory
+}
+
+// Types 返回已注册的数据源类型
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NormalizeType 校验数据源类型，空字符串视为 prometheus
+func NormalizeType(typ string) (string, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if typ == "" {
+		return TypePrometheus, nil
+	}
+	factoriesMu.RLock()
+	_, ok := factories[typ]
+	factoriesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("无效的数据源类型 %q，可选值: %s", typ, strings.Join(Types(), "、"))
+	}
+	return typ, nil
+}
+
+// New 根据数据源类型创建 Datasource
+func New(source models.MetricsSource) (Datasource, error) {
+	typ, err := NormalizeType(source.Type)
+	if err != nil {
+		return nil, err
+	}
+	source.Type = typ
+
+	factoriesMu.RLock()
+	factory := factories[typ]
+	factoriesMu.RUnlock()
+	return factory(source)
+}
+
+// Validate 校验数据源的类型、认证方式、证书和查询选项
+func Validate(source models.MetricsSource) error {
+	if _, err := NormalizeAuthType(source.AuthType); err != nil {
+		return err
+	}
+	if _, err := buildTLSConfig(source); err != nil {
+		return err
+	}
+	_, err := New(source)
+	return err
+}
@@ -0,0 +1,129 @@
+package datasource
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 数据源认证方式
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// requestTimeout 数据源请求超时时间
+const requestTimeout = 60 * time.Second
+
+// cachedClient 按数据源缓存的 HTTP 客户端，fingerprint 用于识别数据源 TLS 配置是否变化
+type cachedClient struct {
+	fingerprint string
+	client      *http.Client
+}
+
+var (
+	clients   = make(map[int64]*cachedClient)
+	clientsMu sync.Mutex
+)
+
+// NormalizeAuthType 校验认证方式，空字符串视为 none
+func NormalizeAuthType(authType string) (string, error) {
+	authType = strings.ToLower(strings.TrimSpace(authType))
+	switch authType {
+	case "":
+		return AuthNone, nil
+	case AuthNone, AuthBasic, AuthBearer:
+		return authType, nil
+	default:
+		return "", fmt.Errorf("无效的认证方式 %q，可选值: none、basic、bearer", authType)
+	}
+}
+
+// httpClient 返回数据源对应的 HTTP 客户端，TLS 配置变化时重新创建
+func httpClient(source models.MetricsSource) (*http.Client, error) {
+	fingerprint := tlsFingerprint(source)
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if cached, ok := clients[source.ID]; ok && cached.fingerprint == fingerprint {
+		return cached.client, nil
+	}
+
+	tlsConfig, err := buildTLSConfig(source)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport, Timeout: requestTimeout}
+
+	// 未保存的数据源（ID 为 0）不缓存
+	if source.ID > 0 {
+		if cached, ok := clients[source.ID]; ok {
+			cached.client.CloseIdleConnections()
+		}
+		clients[source.ID] = &cachedClient{fingerprint: fingerprint, client: client}
+	}
+	return client, nil
+}
+
+// applyAuth 设置认证信息和自定义请求头，自定义请求头优先
+func applyAuth(req *http.Request, source models.MetricsSource) {
+	switch source.AuthType {
+	case AuthBasic:
+		req.SetBasicAuth(source.Username, source.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+source.BearerToken)
+	}
+	for k, v := range source.Headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// buildTLSConfig 根据数据源配置构建 TLS 配置，未配置证书时使用系统默认
+func buildTLSConfig(source models.MetricsSource) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: source.InsecureSkipVerify}
+
+	if strings.TrimSpace(source.CACert) != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(source.CACert)) {
+			return nil, fmt.Errorf("数据源 %s 的 CA 证书格式无效", source.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	hasCert := strings.TrimSpace(source.ClientCert) != ""
+	hasKey := strings.TrimSpace(source.ClientKey) != ""
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("数据源 %s 的客户端证书和私钥需要同时配置", source.Name)
+	}
+	if hasCert {
+		cert, err := tls.X509KeyPair([]byte(source.ClientCert), []byte(source.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("数据源 %s 的客户端证书无效: %w", source.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// tlsFingerprint 计算数据源 TLS 配置的摘要，认证信息在每次请求时设置，不参与计算
+func tlsFingerprint(source models.MetricsSource) string {
+	data, _ := json.Marshal([]interface{}{source.CACert, source.ClientCert, source.ClientKey, source.InsecureSkipVerify})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
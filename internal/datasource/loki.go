@@ -0,0 +1,80 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// TypeLoki Loki 日志数据源，只支持返回指标的 LogQL 查询（如 count_over_time、rate）
+const TypeLoki = "loki"
+
+// loki Loki 数据源，复用 Prometheus 兼容数据源的请求和认证逻辑，查询接口位于 /loki/api/v1 下
+type loki struct {
+	api *prometheus
+}
+
+func init() {
+	Register(TypeLoki, newLoki)
+}
+
+func newLoki(source models.MetricsSource) (Datasource, error) {
+	ds, err := newPrometheus(source)
+	if err != nil {
+		return nil, err
+	}
+	return &loki{api: ds.(*prometheus)}, nil
+}
+
+func (l *loki) RangeQuery(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	// Loki 使用纳秒时间戳，截断到秒与 Prometheus 数据源的时间点保持一致
+	params.Set("start", fmt.Sprintf("%d", start.Unix()*int64(time.Second)))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()*int64(time.Second)))
+	params.Set("step", fmt.Sprintf("%d", int64(step.Seconds())))
+	return l.query(ctx, "/loki/api/v1/query_range", params)
+}
+
+func (l *loki) InstantQuery(ctx context.Context, query string, ts time.Time) (*QueryResponse, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	if !ts.IsZero() {
+		params.Set("time", fmt.Sprintf("%d", ts.Unix()*int64(time.Second)))
+	}
+	return l.query(ctx, "/loki/api/v1/query", params)
+}
+
+func (l *loki) LabelValues(ctx context.Context, label string) ([]string, error) {
+	data, err := l.api.get(ctx, "/loki/api/v1/label/"+url.PathEscape(label)+"/values", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("解析标签值失败: %w", err)
+	}
+	return values, nil
+}
+
+// Health 查询标签列表，同时验证地址和认证配置
+func (l *loki) Health(ctx context.Context) error {
+	_, err := l.api.get(ctx, "/loki/api/v1/labels", url.Values{})
+	return err
+}
+
+// query 执行 LogQL 查询，日志流结果无法转换为数据点，返回错误
+func (l *loki) query(ctx context.Context, apiPath string, params url.Values) (*QueryResponse, error) {
+	resp, err := l.api.query(ctx, apiPath, params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.ResultType == "streams" {
+		return nil, fmt.Errorf("LogQL 查询返回的是日志流，请使用 count_over_time、rate 等返回指标的查询")
+	}
+	return resp, nil
+}
@@ -0,0 +1,198 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 多租户请求头的默认值
+const (
+	defaultTenantHeader = "X-Scope-OrgID"
+	thanosTenantHeader  = "THANOS-TENANT"
+)
+
+// prometheus 兼容 Prometheus HTTP API 的数据源，VictoriaMetrics 和 Thanos 在此基础上附加各自的查询参数
+type prometheus struct {
+	source       models.MetricsSource
+	baseURL      *url.URL
+	params       url.Values // 附加到每个请求的类型相关参数
+	tenantHeader string
+}
+
+// apiResponse Prometheus HTTP API 的通用响应结构
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+}
+
+func init() {
+	Register(TypePrometheus, newPrometheus)
+	Register(TypeVictoriaMetrics, newPrometheus)
+	Register(TypeThanos, newPrometheus)
+}
+
+// newPrometheus 创建 Prometheus 兼容数据源，根据 source.Type 应用对应的查询选项
+func newPrometheus(source models.MetricsSource) (Datasource, error) {
+	u, err := url.Parse(strings.TrimSpace(source.URL))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("数据源 %s 的地址无效: %q", source.Name, source.URL)
+	}
+
+	p := &prometheus{source: source, baseURL: u, params: url.Values{}}
+	opts := source.Options
+	switch source.Type {
+	case TypeVictoriaMetrics:
+		for _, label := range opts.ExtraLabels {
+			label = strings.TrimSpace(label)
+			if name, _, ok := strings.Cut(label, "="); !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("无效的 extra_label %q，格式应为 name=value", label)
+			}
+			p.params.Add("extra_label", label)
+		}
+	case TypeThanos:
+		if opts.Dedup != nil {
+			p.params.Set("dedup", strconv.FormatBool(*opts.Dedup))
+		}
+		if opts.PartialResponse != nil {
+			p.params.Set("partial_response", strconv.FormatBool(*opts.PartialResponse))
+		}
+	}
+
+	if opts.TenantID != "" {
+		p.tenantHeader = opts.TenantHeader
+		if p.tenantHeader == "" {
+			p.tenantHeader = defaultTenantHeader
+			if source.Type == TypeThanos {
+				p.tenantHeader = thanosTenantHeader
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *prometheus) RangeQuery(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", fmt.Sprintf("%d", start.Unix()))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+	params.Set("step", fmt.Sprintf("%d", int64(step.Seconds())))
+	return p.query(ctx, "/api/v1/query_range", params)
+}
+
+func (p *prometheus) InstantQuery(ctx context.Context, query string, ts time.Time) (*QueryResponse, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	if !ts.IsZero() {
+		params.Set("time", fmt.Sprintf("%d", ts.Unix()))
+	}
+	return p.query(ctx, "/api/v1/query", params)
+}
+
+func (p *prometheus) LabelValues(ctx context.Context, label string) ([]string, error) {
+	data, err := p.get(ctx, "/api/v1/label/"+url.PathEscape(label)+"/values", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("解析标签值失败: %w", err)
+	}
+	return values, nil
+}
+
+func (p *prometheus) Health(ctx context.Context) error {
+	_, err := p.InstantQuery(ctx, "1", time.Time{})
+	return err
+}
+
+// query 执行查询请求并解析结果
+func (p *prometheus) query(ctx context.Context, apiPath string, params url.Values) (*QueryResponse, error) {
+	data, err := p.get(ctx, apiPath, params)
+	if err != nil {
+		return nil, err
+	}
+	resp := &QueryResponse{Status: "success"}
+	if err := json.Unmarshal(data, &resp.Data); err != nil {
+		return nil, fmt.Errorf("解析查询结果失败: %w", err)
+	}
+	return resp, nil
+}
+
+// get 发送 GET 请求，返回响应中的 data 字段
+func (p *prometheus) get(ctx context.Context, apiPath string, params url.Values) (json.RawMessage, error) {
+	u := *p.baseURL
+	u.Path = path.Join(u.Path, apiPath)
+	for k, values := range p.params {
+		for _, v := range values {
+			params.Add(k, v)
+		}
+	}
+	u.RawQuery = params.Encode()
+
+	client, err := httpClient(p.source)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	applyAuth(req, p.source)
+	if p.tenantHeader != "" {
+		req.Header.Set(p.tenantHeader, p.source.Options.TenantID)
+	}
+
+	log.Printf("[Datasource] Requesting URL: %s", u.String())
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("[Datasource] ERROR: HTTP request failed: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[Datasource] Response status code: %d, size: %d bytes", resp.StatusCode, len(body))
+
+	var apiResp apiResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("数据源返回 HTTP %d: %s", resp.StatusCode, truncate(string(body), 200))
+		}
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if apiResp.Status != "success" {
+		if apiResp.Error != "" {
+			return nil, fmt.Errorf("query failed (HTTP %d): %s: %s", resp.StatusCode, apiResp.ErrorType, apiResp.Error)
+		}
+		return nil, fmt.Errorf("query failed (HTTP %d): %s", resp.StatusCode, apiResp.Status)
+	}
+	for _, w := range apiResp.Warnings {
+		log.Printf("[Datasource] WARNING: %s", w)
+	}
+	return apiResp.Data, nil
+}
+
+// truncate 截断过长的文本，用于错误信息
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
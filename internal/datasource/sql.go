@@ -0,0 +1,491 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"fsvchart-notify/internal/models"
+)
+
+// TypeSQL SQL 数据源，查询返回 time、series、value 三列，分别对应时间、序列名称和数值
+const TypeSQL = "sql"
+
+// SQL 数据源支持的数据库驱动
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite3"
+)
+
+const (
+	// defaultSQLQueryTimeout SQL 查询的默认超时时间
+	defaultSQLQueryTimeout = 30 * time.Second
+
+	// sqlInstantWindow 即时查询（文本模式）时 $__from 距离查询时间的长度
+	sqlInstantWindow = 24 * time.Hour
+
+	// sqlSeriesLabel 查询结果中序列名称对应的标签名
+	sqlSeriesLabel = "series"
+)
+
+// sqlMacro 查询语句中可用的绑定参数：$__from、$__to 为查询时间范围，$__interval 为图表步长（秒）
+var sqlMacro = regexp.MustCompile(`\$__(from|to|interval)\b`)
+
+// sqlDB 按数据源缓存的数据库连接池，dsn 变化时重新创建
+type sqlDB struct {
+	dsn string
+	db  *sql.DB
+}
+
+var (
+	sqlDBs   = make(map[int64]*sqlDB)
+	sqlDBsMu sync.Mutex
+)
+
+// sqlDatasource SQL 数据源，每次查询在只读事务中执行
+type sqlDatasource struct {
+	source  models.MetricsSource
+	driver  string
+	dsn     string
+	timeout time.Duration
+}
+
+func init() {
+	Register(TypeSQL, newSQL)
+}
+
+func newSQL(source models.MetricsSource) (Datasource, error) {
+	driver := normalizeDriver(source.Options.Driver)
+	dsn, err := buildDSN(driver, strings.TrimSpace(source.URL), source.Username, source.Password)
+	if err != nil {
+		return nil, fmt.Errorf("数据源 %s: %w", source.Name, err)
+	}
+
+	timeout := defaultSQLQueryTimeout
+	if source.Options.QueryTimeout > 0 {
+		timeout = time.Duration(source.Options.QueryTimeout) * time.Second
+	}
+	return &sqlDatasource{source: source, driver: driver, dsn: dsn, timeout: timeout}, nil
+}
+
+// normalizeDriver 统一驱动名称的大小写和别名
+func normalizeDriver(driver string) string {
+	driver = strings.ToLower(strings.TrimSpace(driver))
+	switch driver {
+	case "postgresql":
+		return DriverPostgres
+	case "sqlite":
+		return DriverSQLite
+	}
+	return driver
+}
+
+// postgresPassword 匹配 PostgreSQL key=value 连接串中的 password 项
+var postgresPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// RedactDSN 将连接串中的密码替换为 mask，用于接口返回；无法解析或没有密码时原样返回
+func RedactDSN(driver, dsn, mask string) string {
+	switch normalizeDriver(driver) {
+	case DriverMySQL:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil || cfg.Passwd == "" {
+			return dsn
+		}
+		cfg.Passwd = mask
+		return cfg.FormatDSN()
+	case DriverPostgres:
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			u, err := url.Parse(dsn)
+			if err != nil || u.User == nil {
+				return dsn
+			}
+			if _, ok := u.User.Password(); !ok {
+				return dsn
+			}
+			// url.UserPassword 会转义掩码中的字符，去掉密码后再插入掩码
+			u.User = url.User(u.User.Username())
+			return strings.Replace(u.String(), "@", ":"+mask+"@", 1)
+		}
+		return postgresPassword.ReplaceAllString(dsn, "${1}"+mask)
+	default:
+		return dsn
+	}
+}
+
+// buildDSN 根据驱动生成连接串，用户名和密码单独配置时写入连接串，并强制只读
+func buildDSN(driver, dsn, username, password string) (string, error) {
+	if dsn == "" {
+		return "", fmt.Errorf("连接串不能为空")
+	}
+	switch driver {
+	case DriverMySQL:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", fmt.Errorf("MySQL 连接串无效: %w", err)
+		}
+		if username != "" {
+			cfg.User = username
+		}
+		if password != "" {
+			cfg.Passwd = password
+		}
+		// DATETIME 按服务器本地时区解析
+		cfg.ParseTime = true
+		cfg.Loc = time.Local
+		return cfg.FormatDSN(), nil
+	case DriverPostgres:
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return "", fmt.Errorf("PostgreSQL 连接串无效: %w", err)
+			}
+			if username != "" || password != "" {
+				user := u.User.Username()
+				if username != "" {
+					user = username
+				}
+				pass, _ := u.User.Password()
+				if password != "" {
+					pass = password
+				}
+				u.User = url.UserPassword(user, pass)
+			}
+			return u.String(), nil
+		}
+		if username != "" {
+			dsn += " user=" + quotePostgresValue(username)
+		}
+		if password != "" {
+			dsn += " password=" + quotePostgresValue(password)
+		}
+		return dsn, nil
+	case DriverSQLite:
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "_query_only=1", nil
+	default:
+		return "", fmt.Errorf("无效的数据库驱动 %q，可选值: mysql、postgres、sqlite3", driver)
+	}
+}
+
+// quotePostgresValue 转义 PostgreSQL key=value 连接串中的值
+func quotePostgresValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// conn 返回数据源对应的连接池，连接串变化时重新创建
+func (s *sqlDatasource) conn() (*sql.DB, error) {
+	sqlDBsMu.Lock()
+	defer sqlDBsMu.Unlock()
+
+	if cached, ok := sqlDBs[s.source.ID]; ok && cached.dsn == s.dsn {
+		return cached.db, nil
+	}
+	db, err := sql.Open(s.driver, s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	if s.source.ID > 0 {
+		if cached, ok := sqlDBs[s.source.ID]; ok {
+			cached.db.Close()
+		}
+		sqlDBs[s.source.ID] = &sqlDB{dsn: s.dsn, db: db}
+	}
+	return db, nil
+}
+
+// sqlRow 查询结果中的一行
+type sqlRow struct {
+	time   time.Time
+	series string
+	value  float64
+}
+
+// run 在只读事务中执行查询，替换绑定参数并读取 time、series、value 列
+func (s *sqlDatasource) run(ctx context.Context, query string, from, to time.Time, step time.Duration) ([]sqlRow, bool, error) {
+	db, err := s.conn()
+	if err != nil {
+		return nil, false, err
+	}
+	if s.source.ID <= 0 {
+		defer db.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query, args := s.bind(query, from, to, step)
+	log.Printf("[Datasource] SQL query (%s): %s, args: %v", s.driver, query, args)
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: s.driver != DriverSQLite})
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, false, err
+	}
+	timeIdx, seriesIdx, valueIdx := -1, -1, -1
+	for i, col := range columns {
+		switch strings.ToLower(col) {
+		case "time":
+			timeIdx = i
+		case "series":
+			seriesIdx = i
+		case "value":
+			valueIdx = i
+		}
+	}
+	if valueIdx < 0 {
+		if len(columns) != 3 {
+			return nil, false, fmt.Errorf("SQL 查询需要返回 value 列（可选 time、series 列），实际返回: %v", columns)
+		}
+		timeIdx, seriesIdx, valueIdx = 0, 1, 2
+	}
+
+	var result []sqlRow
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, false, err
+		}
+
+		var row sqlRow
+		if row.value, err = toFloat(values[valueIdx]); err != nil {
+			return nil, false, fmt.Errorf("value 列: %w", err)
+		}
+		if math.IsNaN(row.value) {
+			continue
+		}
+		if timeIdx >= 0 {
+			if row.time, err = toTime(values[timeIdx]); err != nil {
+				return nil, false, fmt.Errorf("time 列: %w", err)
+			}
+		}
+		if seriesIdx >= 0 {
+			row.series = toString(values[seriesIdx])
+		}
+		result = append(result, row)
+	}
+	return result, timeIdx >= 0, rows.Err()
+}
+
+// bind 将 $__from、$__to、$__interval 替换为驱动对应的占位符
+func (s *sqlDatasource) bind(query string, from, to time.Time, step time.Duration) (string, []interface{}) {
+	var args []interface{}
+	bound := sqlMacro.ReplaceAllStringFunc(query, func(m string) string {
+		switch m {
+		case "$__from":
+			args = append(args, from)
+		case "$__to":
+			args = append(args, to)
+		default:
+			args = append(args, int64(step.Seconds()))
+		}
+		if s.driver == DriverPostgres {
+			return fmt.Sprintf("$%d", len(args))
+		}
+		return "?"
+	})
+	return bound, args
+}
+
+func (s *sqlDatasource) RangeQuery(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error) {
+	rows, hasTime, err := s.run(ctx, query, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTime {
+		return nil, fmt.Errorf("图表模式的 SQL 查询需要返回 time 列")
+	}
+
+	resp := &QueryResponse{Status: "success"}
+	resp.Data.ResultType = "matrix"
+	index := make(map[string]int)
+	for _, row := range rows {
+		i, ok := index[row.series]
+		if !ok {
+			i = len(resp.Data.Result)
+			index[row.series] = i
+			resp.Data.Result = append(resp.Data.Result, Series{Metric: map[string]string{sqlSeriesLabel: row.series}})
+		}
+		resp.Data.Result[i].Values = append(resp.Data.Result[i].Values,
+			[]interface{}{float64(row.time.Unix()), strconv.FormatFloat(row.value, 'f', -1, 64)})
+	}
+	return resp, nil
+}
+
+// InstantQuery 查询 ts 之前 24 小时内的数据，每个序列取时间最新的一行
+func (s *sqlDatasource) InstantQuery(ctx context.Context, query string, ts time.Time) (*QueryResponse, error) {
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	rows, _, err := s.run(ctx, query, ts.Add(-sqlInstantWindow), ts, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]sqlRow)
+	var order []string
+	for _, row := range rows {
+		prev, ok := latest[row.series]
+		if !ok {
+			order = append(order, row.series)
+		}
+		// 没有 time 列时取最后一行
+		if !ok || !row.time.Before(prev.time) {
+			latest[row.series] = row
+		}
+	}
+
+	resp := &QueryResponse{Status: "success"}
+	resp.Data.ResultType = "vector"
+	for _, series := range order {
+		row := latest[series]
+		t := row.time
+		if t.IsZero() {
+			t = ts
+		}
+		resp.Data.Result = append(resp.Data.Result, Series{
+			Metric: map[string]string{sqlSeriesLabel: series},
+			Value:  []interface{}{float64(t.Unix()), strconv.FormatFloat(row.value, 'f', -1, 64)},
+		})
+	}
+	return resp, nil
+}
+
+// LabelValues SQL 数据源的序列名称来自查询语句，无法单独列出
+func (s *sqlDatasource) LabelValues(ctx context.Context, label string) ([]string, error) {
+	return nil, fmt.Errorf("SQL 数据源不支持查询标签值，序列名称由查询语句的 series 列决定")
+}
+
+func (s *sqlDatasource) Health(ctx context.Context) error {
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+	if s.source.ID <= 0 {
+		defer db.Close()
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// toFloat 将数据库返回的数值转换为 float64，NULL 返回 NaN
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case nil:
+		return math.NaN(), nil
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case []byte:
+		return strconv.ParseFloat(strings.TrimSpace(string(x)), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("不支持的数值类型 %T", v)
+	}
+}
+
+// sqlTimeLayouts 字符串类型时间列支持的格式，不带时区的按服务器本地时区解析
+var sqlTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// toTime 将数据库返回的时间转换为 time.Time，数值按 Unix 时间戳处理（超过 1e12 视为毫秒）
+func toTime(v interface{}) (time.Time, error) {
+	var s string
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case int64:
+		return unixTime(float64(x)), nil
+	case float64:
+		return unixTime(x), nil
+	case []byte:
+		s = string(x)
+	case string:
+		s = x
+	default:
+		return time.Time{}, fmt.Errorf("不支持的时间类型 %T", v)
+	}
+
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return unixTime(f), nil
+	}
+	for _, layout := range sqlTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", s)
+}
+
+func unixTime(f float64) time.Time {
+	if f > 1e12 {
+		return time.UnixMilli(int64(f))
+	}
+	return time.Unix(int64(f), 0)
+}
+
+// toString 将序列列转换为字符串，NULL 返回空字符串
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(x)
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
@@ -1,10 +1,89 @@
 package handler
 
 import (
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"fsvchart-notify/internal/service"
 )
 
+// HandleGetSendRecords 查询发送记录
+// 支持按 task_id、webhook_id、status、start、end 过滤；
+// 传入 page 时返回分页结果 {items, total, page, page_size}，否则返回最近的记录数组（兼容旧版本前端）
 func HandleGetSendRecords(c *gin.Context) {
-	c.JSON(200, service.GetSendRecords())
-} 
\ No newline at end of file
+	var filter service.SendRecordFilter
+	var err error
+
+	if v := c.Query("task_id"); v != "" {
+		if filter.TaskID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 task_id"})
+			return
+		}
+	}
+	if v := c.Query("webhook_id"); v != "" {
+		if filter.WebhookID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 webhook_id"})
+			return
+		}
+	}
+	filter.Status = c.Query("status")
+	if v := c.Query("start"); v != "" {
+		if filter.Start, err = parseRecordTime(v, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 start，格式应为 2006-01-02 15:04:05 或 RFC3339"})
+			return
+		}
+	}
+	if v := c.Query("end"); v != "" {
+		if filter.End, err = parseRecordTime(v, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 end，格式应为 2006-01-02 15:04:05 或 RFC3339"})
+			return
+		}
+	}
+
+	paged := c.Query("page") != ""
+	if paged {
+		filter.Page, _ = strconv.Atoi(c.Query("page"))
+		filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if filter.Page < 1 {
+			filter.Page = 1
+		}
+		if filter.PageSize < 1 || filter.PageSize > 200 {
+			filter.PageSize = 20
+		}
+	}
+
+	records, total, err := service.QuerySendRecords(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询发送记录失败: " + err.Error()})
+		return
+	}
+	if !paged {
+		c.JSON(http.StatusOK, records)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":     records,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
+
+// parseRecordTime 解析查询参数中的时间，不带时区的按服务器本地时间处理
+// 只有日期时视为当天 0 点；endOfDay 为 true（结束时间）时视为当天最后一秒，使结束日期当天的记录包含在结果中
+func parseRecordTime(s string, endOfDay bool) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if endOfDay {
+			return t.AddDate(0, 0, 1).Add(-time.Second), nil
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -8,12 +8,80 @@ type MetricsSource struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 	URL  string `json:"url"`
+	Type string `json:"type"` // 数据源类型：prometheus/victoriametrics/thanos/loki/sql
+
+	// 数据源类型相关的查询选项
+	Options DatasourceOptions `json:"options"`
+
+	// 认证配置，AuthType 为 none/basic/bearer
+	AuthType           string            `json:"auth_type"`
+	Username           string            `json:"username"`
+	Password           string            `json:"password"`
+	BearerToken        string            `json:"bearer_token"`
+	Headers            map[string]string `json:"headers"`
+	CACert             string            `json:"ca_cert"`     // PEM 格式的 CA 证书
+	ClientCert         string            `json:"client_cert"` // PEM 格式的客户端证书（mTLS）
+	ClientKey          string            `json:"client_key"`  // PEM 格式的客户端私钥（mTLS）
+	InsecureSkipVerify bool              `json:"insecure_skip_verify"`
+}
+
+// DatasourceOptions 数据源类型相关的查询选项，不适用于当前类型的选项会被忽略
+type DatasourceOptions struct {
+	// ExtraLabels VictoriaMetrics extra_label 参数，格式为 name=value，强制附加到所有查询
+	ExtraLabels []string `json:"extra_label,omitempty"`
+	// Dedup Thanos 查询去重，为空时使用 Thanos 默认值
+	Dedup *bool `json:"dedup,omitempty"`
+	// PartialResponse Thanos 是否允许部分响应，为空时使用 Thanos 默认值
+	PartialResponse *bool `json:"partial_response,omitempty"`
+	// TenantID 多租户场景下的租户 ID，通过 TenantHeader 指定的请求头发送
+	TenantID string `json:"tenant_id,omitempty"`
+	// TenantHeader 租户请求头，为空时 Thanos 使用 THANOS-TENANT，其余使用 X-Scope-OrgID
+	TenantHeader string `json:"tenant_header,omitempty"`
+	// Driver SQL 数据源的数据库驱动：mysql/postgres/sqlite3，连接串填写在 URL 中
+	Driver string `json:"driver,omitempty"`
+	// QueryTimeout SQL 数据源的查询超时时间（秒），为 0 时使用 30 秒
+	QueryTimeout int `json:"query_timeout,omitempty"`
 }
 
 type FeishuWebhook struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	ID      int64          `json:"id"`
+	Name    string         `json:"name"`
+	URL     string         `json:"url"`
+	Type    string         `json:"type"`    // 通知渠道类型: feishu（默认）、feishu_app、dingtalk、wecom、slack、email、webhook、telegram、teams
+	Secret  string         `json:"secret"`  // 加签密钥、机器人 Token、App Secret 或 SMTP 密码
+	Options ChannelOptions `json:"options"` // 渠道相关的选项
+}
+
+// ChannelOptions 通知渠道的附加选项，以 JSON 存储在 feishu_webhook.options 中
+type ChannelOptions struct {
+	ChannelID string `json:"channel_id,omitempty"` // Slack 频道 ID，配置 Bot Token 时用于发送消息和上传图表图片
+	ChatID    string `json:"chat_id,omitempty"`    // Telegram 会话 ID（用户或群组 ID、@频道用户名）
+
+	// 飞书应用机器人的配置，App Secret 保存在 Secret 中
+	AppID         string   `json:"app_id,omitempty"`
+	ReceiveIDType string   `json:"receive_id_type,omitempty"` // chat_id（默认）、open_id、user_id、union_id、email
+	ReceiveIDs    []string `json:"receive_ids,omitempty"`
+
+	// ChartImage 飞书图表以服务端绘制的 PNG 图片发送，图片通过飞书应用上传
+	// 自定义机器人需要同时配置 AppID 和 AppSecret（Secret 用于签名校验）
+	ChartImage bool   `json:"chart_image,omitempty"`
+	AppSecret  string `json:"app_secret,omitempty"`
+
+	// 邮件渠道的 SMTP 配置，密码保存在 Secret 中
+	SMTPHost     string   `json:"smtp_host,omitempty"`
+	SMTPPort     int      `json:"smtp_port,omitempty"`     // 默认 587，465 端口默认使用 TLS
+	SMTPSecurity string   `json:"smtp_security,omitempty"` // auto（默认，服务器支持时使用 STARTTLS）、starttls、tls、none
+	Username     string   `json:"username,omitempty"`      // SMTP 认证用户名，为空时不认证
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+
+	// 通用 WebHook 渠道的请求配置，配置了 Secret 时请求带 X-Signature-256 签名头
+	Method         string            `json:"method,omitempty"` // POST（默认）、PUT、PATCH
+	Headers        map[string]string `json:"headers,omitempty"`
+	ContentType    string            `json:"content_type,omitempty"`    // 默认 application/json
+	BodyTemplate   string            `json:"body_template,omitempty"`   // text/template 模板，为空时发送完整数据模型的 JSON
+	ExpectedStatus []int             `json:"expected_status,omitempty"` // 视为成功的状态码，为空时接受所有 2xx
 }
 
 type PushStatus struct {
@@ -37,7 +105,10 @@ type QueryDataPoints struct {
 	DataPoints []DataPoint `json:"data_points"`
 	ChartType  string      `json:"chart_type"`
 	ChartTitle string      `json:"chart_title"`
-	Unit       string      `json:"unit"` // 每个查询的独立单位
+	Unit       string      `json:"unit"`               // 每个查询的独立单位
+	Link       string      `json:"link,omitempty"`     // 查询的详情链接（已渲染模板）
+	Stats      []string    `json:"stats,omitempty"`    // 图表下方显示的序列统计项：min、max、avg、last、p95、sum
+	Mentions   []Mention   `json:"mentions,omitempty"` // 查询级 @ 配置，显示在图表标题之后
 }
 
 // 新增：发送记录结构体
@@ -47,6 +118,8 @@ type SendRecord struct {
 	Status     string    `json:"status"`
 	Message    string    `json:"message"`
 	Webhook    string    `json:"webhook"`
+	TaskID     int64     `json:"task_id"`
+	WebhookID  int64     `json:"webhook_id"`
 	TaskName   string    `json:"task_name"`
 	ButtonText string    `json:"button_text"`
 	ButtonURL  string    `json:"button_url"`
@@ -66,10 +139,62 @@ type PromQL struct {
 	Description string `json:"description"` // 查询描述
 	Query       string `json:"query"`       // 查询语句
 	Category    string `json:"category"`    // 查询分类
+	SourceID    int64  `json:"source_id"`   // 指定的数据源，为 0 时使用任务的数据源
 	CreatedAt   string `json:"created_at"`  // 创建时间
 	UpdatedAt   string `json:"updated_at"`  // 更新时间
 }
 
+// Mention 飞书卡片中 @ 的对象，Op 为空时无条件 @，否则在数值满足 Op Threshold 时才 @
+type Mention struct {
+	User      string  `json:"user"`                // open_id、user_id、邮箱或 all（所有人）
+	Op        string  `json:"op,omitempty"`        // >、>=、<、<=、==、!=
+	Threshold float64 `json:"threshold,omitempty"` // 阈值，与查询结果的最新值比较
+}
+
+// 卡片布局类型
+const (
+	LayoutKindTemplate = "template" // Content 为生成飞书卡片 JSON 的 text/template 模板
+	LayoutKindBlocks   = "blocks"   // Content 为 LayoutBlock 列表的 JSON
+)
+
+// CardLayout 自定义卡片布局，任务通过 card_layout_id 引用，替换飞书卡片的默认结构
+type CardLayout struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"` // template/blocks
+	Content     string `json:"content"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// LayoutBlock 声明式卡片布局中的一个块
+// Content、Text、URL、Alt 可使用模板语法，数据与通用 WebHook 模板相同
+type LayoutBlock struct {
+	Type     string         `json:"type"`                // markdown、note、hr、queries、query、table、columns、image、buttons、mentions
+	Content  string         `json:"content,omitempty"`   // markdown/note 的内容，note 为空时显示数据时间
+	Query    string         `json:"query,omitempty"`     // query 块引用的查询名称（PromQL 名称）
+	Queries  []string       `json:"queries,omitempty"`   // table 块包含的查询名称，为空时包含所有查询
+	Mode     string         `json:"mode,omitempty"`      // queries/query 块只展示 text 或 chart 元素，为空时都展示
+	Columns  []LayoutColumn `json:"columns,omitempty"`   // columns 块的各列
+	ImageKey string         `json:"image_key,omitempty"` // image 块的飞书图片 key
+	Alt      string         `json:"alt,omitempty"`
+	Buttons  []Button       `json:"buttons,omitempty"` // buttons 块的按钮，为空时使用任务的按钮
+}
+
+// LayoutColumn columns 块中的一列
+type LayoutColumn struct {
+	Weight int           `json:"weight,omitempty"` // 列宽权重，默认 1
+	Blocks []LayoutBlock `json:"blocks"`
+}
+
+// Button 卡片中的链接按钮，URL 可使用 {{.Start}}、{{.End}} 等模板变量
+type Button struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+	Type string `json:"type,omitempty"` // default（默认）、primary、danger
+}
+
 // TaskSendTime 任务发送时间结构体
 type TaskSendTime struct {
 	ID       int64  `json:"id"`
@@ -78,6 +203,44 @@ type TaskSendTime struct {
 	SendTime string `json:"send_time"` // 格式如 "09:00"
 }
 
+// TaskRun 推送任务的一次执行记录
+type TaskRun struct {
+	ID          int64             `json:"id"`
+	TaskID      int64             `json:"task_id"`
+	Trigger     string            `json:"trigger"` // schedule/catchup/manual/api
+	Status      string            `json:"status"`  // running/success/partial/failed/skipped
+	ScheduledAt string            `json:"scheduled_at"`
+	StartedAt   string            `json:"started_at"`
+	FinishedAt  string            `json:"finished_at"`
+	DurationMs  int64             `json:"duration_ms"`
+	Queries     []TaskRunQuery    `json:"queries"`
+	Deliveries  []TaskRunDelivery `json:"deliveries"`
+	PayloadHash string            `json:"payload_hash"`
+	Message     string            `json:"message"` // 未发送或失败的原因
+}
+
+// TaskRunQuery 一次执行中单个查询的获取结果
+type TaskRunQuery struct {
+	Name        string `json:"name"`
+	Query       string `json:"query"`
+	Mode        string `json:"mode"` // chart/text
+	SeriesCount int    `json:"series_count"`
+	PointCount  int    `json:"point_count"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TaskRunDelivery 一次执行中单个 WebHook 的发送结果
+type TaskRunDelivery struct {
+	WebhookID   int64  `json:"webhook_id"`
+	WebhookName string `json:"webhook_name"`
+	Mode        string `json:"mode"`              // chart/text/hybrid
+	Channel     string `json:"channel,omitempty"` // 通知渠道类型: feishu/feishu_app/dingtalk/wecom/slack/email/webhook/telegram/teams
+	Status      string `json:"status"`            // success/failed
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+	PayloadHash string `json:"payload_hash"`
+}
+
 // User 用户结构体
 type User struct {
 	ID          int64     `json:"id"`
@@ -0,0 +1,109 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// fontSize 图片文字的字号（像素），与 7x13 点阵字体大小接近
+const fontSize = 12
+
+// fontPaths 未配置字体文件时依次查找的中文字体
+var fontPaths = []string{
+	"/usr/share/fonts/noto/NotoSansCJK-Regular.ttc",            // Alpine: font-noto-cjk
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",   // Debian/Ubuntu: fonts-noto-cjk
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc", // Fedora/CentOS: google-noto-sans-cjk-fonts
+	"/usr/share/fonts/truetype/wqy/wqy-zenhei.ttc",             // Debian/Ubuntu: fonts-wqy-zenhei
+	"/usr/share/fonts/wqy-zenhei/wqy-zenhei.ttc",               // Alpine: font-wqy-zenhei
+}
+
+// textFont 通过 LoadFont 加载的字体，为 nil 时使用只包含 Latin-1 字符的 7x13 点阵字体
+var textFont *sfnt.Font
+
+// LoadFont 加载图片文字使用的字体，需在绘制图片之前调用
+// path 为空时依次查找常见的中文字体路径，均不存在时继续使用点阵字体，中文等字符显示为 ?
+func LoadFont(path string) error {
+	if path != "" {
+		f, err := loadFontFile(path)
+		if err != nil {
+			return err
+		}
+		textFont = f
+		log.Printf("[LoadFont] 使用字体文件: %s", path)
+		return nil
+	}
+	for _, p := range fontPaths {
+		f, err := loadFontFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[LoadFont] 跳过字体文件 %s: %v", p, err)
+			continue
+		}
+		textFont = f
+		log.Printf("[LoadFont] 使用字体文件: %s", p)
+		return nil
+	}
+	log.Printf("[LoadFont] 未找到中文字体，图片中的中文等字符将显示为 ?，可通过配置 render.font_path 指定字体文件")
+	return nil
+}
+
+// loadFontFile 解析 TTF/OTF/TTC 字体文件，字体集合中优先使用简体中文字体
+func loadFontFile(path string) (*sfnt.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体文件 %s 失败: %w", path, err)
+	}
+	var first *sfnt.Font
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			return nil, fmt.Errorf("解析字体文件 %s 失败: %w", path, err)
+		}
+		if first == nil {
+			first = f
+		}
+		if name, err := f.Name(nil, sfnt.NameIDFamily); err == nil && strings.HasSuffix(name, " SC") {
+			return f, nil
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("字体文件 %s 不包含字体", path)
+	}
+	return first, nil
+}
+
+// newFace 为一次绘制创建字体 face，opentype 的 face 不能并发使用
+func newFace() font.Face {
+	if textFont == nil {
+		return basicfont.Face7x13
+	}
+	face, err := opentype.NewFace(textFont, &opentype.FaceOptions{Size: fontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		log.Printf("[newFace] 创建字体失败，使用点阵字体: %v", err)
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+// hasGlyph 判断字体是否包含字符
+func hasGlyph(r rune) bool {
+	if textFont == nil {
+		return r <= 0xff
+	}
+	index, err := textFont.GlyphIndex(nil, r)
+	return err == nil && index != 0
+}
@@ -0,0 +1,156 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"fsvchart-notify/internal/models"
+)
+
+// PNG 将一组查询数据绘制为 PNG 图片
+func PNG(data *models.QueryDataPoints, opts Options) ([]byte, error) {
+	opts, err := prepare(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	img := raster{RGBA: image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height)), face: newFace()}
+	if err := drawChart(img, data, opts); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img.RGBA); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// raster 在内存图片上绘制的 canvas
+type raster struct {
+	*image.RGBA
+	face font.Face
+}
+
+func (img raster) rect(r image.Rectangle, c color.Color) {
+	draw.Draw(img.RGBA, r, &image.Uniform{c}, image.Point{}, draw.Over)
+}
+
+func (img raster) line(x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.SetRGBA(x0, y0, c)
+		img.SetRGBA(x0+1, y0, c)
+		img.SetRGBA(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// area 逐列填充，不包含 x1 所在的列，避免相邻线段重复填充
+func (img raster) area(x0, y0, x1, y1, base int, c color.NRGBA) {
+	if x1 <= x0 {
+		return
+	}
+	for x := x0; x < x1; x++ {
+		y := y0 + (y1-y0)*(x-x0)/(x1-x0)
+		top, bottom := y, base
+		if top > bottom {
+			top, bottom = bottom, top
+		}
+		img.rect(image.Rect(x, top, x+1, bottom), c)
+	}
+}
+
+func (img raster) dot(x, y, r int, c color.RGBA) {
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+}
+
+func (img raster) sector(cx, cy, r int, start, end float64, c color.RGBA) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > float64(r*r) {
+				continue
+			}
+			// 从 12 点方向顺时针计算角度
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			if angle >= start && angle < end {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+func (img raster) text(x, y int, s string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img.RGBA,
+		Src:  image.NewUniform(c),
+		Face: img.face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(fontLabel(s, 80))
+}
+
+func (img raster) textWidth(s string) int {
+	return font.MeasureString(img.face, fontLabel(s, 80)).Round()
+}
+
+// fontLabel 将字体不支持的字符替换为 ?，并截断到 n 个字符
+func fontLabel(s string, n int) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range s {
+		if count == n {
+			b.WriteString("...")
+			break
+		}
+		if !hasGlyph(r) {
+			r = '?'
+		}
+		b.WriteRune(r)
+		count++
+	}
+	return b.String()
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,432 @@
+// Package render 将查询数据绘制为 PNG/SVG 图片，供不支持飞书图表组件的渠道使用
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 默认图片尺寸
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 400
+)
+
+// Options 绘图参数
+type Options struct {
+	Width    int
+	Height   int
+	Unit     string         // 纵轴单位，显示在纵轴上方；饼图显示在图例的数值后
+	Location *time.Location // 横轴时间使用的时区，为 nil 时使用服务器本地时区
+}
+
+// palette 序列颜色，与飞书图表默认配色接近
+var palette = []color.RGBA{
+	{0x33, 0x70, 0xeb, 0xff},
+	{0x1c, 0xd0, 0xb4, 0xff},
+	{0xff, 0xc6, 0x0a, 0xff},
+	{0xf5, 0x4a, 0x45, 0xff},
+	{0x7f, 0x3b, 0xf5, 0xff},
+	{0x32, 0xa6, 0x45, 0xff},
+	{0xff, 0x81, 0x1a, 0xff},
+	{0x2e, 0xb8, 0xd6, 0xff},
+	{0xf0, 0x5d, 0xa3, 0xff},
+	{0x8f, 0x95, 0x9e, 0xff},
+}
+
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorAxis       = color.RGBA{0x8f, 0x95, 0x9e, 0xff}
+	colorGrid       = color.RGBA{0xe4, 0xe6, 0xeb, 0xff}
+	colorText       = color.RGBA{0x1f, 0x23, 0x29, 0xff}
+)
+
+// canvas 绘图目标，PNG 和 SVG 使用相同的布局逻辑
+type canvas interface {
+	// rect 填充矩形
+	rect(r image.Rectangle, c color.Color)
+	// line 绘制 2 像素宽的线段
+	line(x0, y0, x1, y1 int, c color.RGBA)
+	// area 填充两个数据点之间折线与基线围成的区域
+	area(x0, y0, x1, y1, base int, c color.NRGBA)
+	// dot 绘制实心圆点
+	dot(x, y, r int, c color.RGBA)
+	// sector 绘制扇形，角度为弧度，从 12 点方向顺时针计算
+	sector(cx, cy, r int, start, end float64, c color.RGBA)
+	// text 以 (x, y) 为基线左端绘制文本
+	text(x, y int, s string, c color.Color)
+	// textWidth 返回文本的像素宽度
+	textWidth(s string) int
+}
+
+// series 单个序列按时间排序后的数据
+type series struct {
+	name   string
+	times  []int64
+	values []float64
+}
+
+// last 返回序列的最新值
+func (s series) last() float64 {
+	return s.values[len(s.values)-1]
+}
+
+// prepare 校验数据并补全默认参数
+func prepare(data *models.QueryDataPoints, opts Options) (Options, error) {
+	if data == nil || len(data.DataPoints) == 0 {
+		return opts, fmt.Errorf("没有可绘制的数据")
+	}
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+	if opts.Height <= 0 {
+		opts.Height = DefaultHeight
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	return opts, nil
+}
+
+// drawChart 按图表类型绘制数据
+// 支持折线图、面积图、柱状图、散点图（气泡图按散点图绘制）和饼图，其他图表类型按折线图绘制
+func drawChart(c canvas, data *models.QueryDataPoints, opts Options) error {
+	c.rect(image.Rect(0, 0, opts.Width, opts.Height), colorBackground)
+	list, times := groupSeries(data.DataPoints)
+	if data.ChartType == "pie" {
+		return drawPie(c, list, opts)
+	}
+
+	// 图例在底部，按宽度换行
+	legendRows := layoutLegend(c, list, opts.Width-40)
+	legendHeight := len(legendRows) * 18
+
+	minV, maxV := valueRange(list)
+	ticks := niceTicks(minV, maxV, 5)
+	tickLabels := make([]string, len(ticks))
+	labelWidth := 0
+	for i, t := range ticks {
+		tickLabels[i] = formatTick(t)
+		if w := c.textWidth(tickLabels[i]); w > labelWidth {
+			labelWidth = w
+		}
+	}
+
+	plot := image.Rect(labelWidth+18, 24, opts.Width-20, opts.Height-legendHeight-36)
+	if plot.Dx() < 50 || plot.Dy() < 50 {
+		return fmt.Errorf("图片尺寸过小: %dx%d", opts.Width, opts.Height)
+	}
+	lo, hi := ticks[0], ticks[len(ticks)-1]
+	yOf := func(v float64) int {
+		return plot.Max.Y - int(math.Round((v-lo)/(hi-lo)*float64(plot.Dy())))
+	}
+
+	// 横向网格线和纵轴刻度
+	for i, t := range ticks {
+		y := yOf(t)
+		hline(c, plot.Min.X, plot.Max.X, y, colorGrid)
+		c.text(plot.Min.X-6-c.textWidth(tickLabels[i]), y+4, tickLabels[i], colorText)
+	}
+	if opts.Unit != "" {
+		c.text(8, 16, opts.Unit, colorText)
+	}
+	hline(c, plot.Min.X, plot.Max.X, plot.Max.Y, colorAxis)
+	vline(c, plot.Min.X, plot.Min.Y, plot.Max.Y, colorAxis)
+
+	// 横轴按时间点等距排列，柱状图和折线图使用相同的横坐标
+	slot := float64(plot.Dx()) / float64(len(times))
+	index := make(map[int64]int, len(times))
+	for i, t := range times {
+		index[t] = i
+	}
+	xOf := func(t int64) int {
+		return plot.Min.X + int(math.Round((float64(index[t])+0.5)*slot))
+	}
+	drawTimeAxis(c, plot, times, xOf, opts.Location)
+
+	for si, s := range list {
+		col := palette[si%len(palette)]
+		switch data.ChartType {
+		case "bar":
+			barWidth := math.Max(1, slot*0.8/float64(len(list)))
+			for i, t := range s.times {
+				x0 := plot.Min.X + int(math.Round(float64(index[t])*slot+slot*0.1+float64(si)*barWidth))
+				x1 := x0 + int(math.Max(1, math.Round(barWidth)-1))
+				y0, y1 := yOf(0), yOf(s.values[i])
+				if y1 > y0 {
+					y0, y1 = y1, y0
+				}
+				c.rect(image.Rect(x0, y1, x1, y0+1), col)
+			}
+		case "scatter", "bubble":
+			for i, t := range s.times {
+				c.dot(xOf(t), yOf(s.values[i]), 3, col)
+			}
+		default:
+			if data.ChartType == "area" {
+				fill := color.NRGBA{col.R, col.G, col.B, 0x40}
+				for i := 1; i < len(s.times); i++ {
+					c.area(xOf(s.times[i-1]), yOf(s.values[i-1]), xOf(s.times[i]), yOf(s.values[i]), yOf(math.Max(lo, 0)), fill)
+				}
+			}
+			for i := 1; i < len(s.times); i++ {
+				c.line(xOf(s.times[i-1]), yOf(s.values[i-1]), xOf(s.times[i]), yOf(s.values[i]), col)
+			}
+			if len(s.times) == 1 {
+				c.dot(xOf(s.times[0]), yOf(s.values[0]), 2, col)
+			}
+		}
+	}
+
+	// 图例
+	y := opts.Height - legendHeight + 4
+	for _, row := range legendRows {
+		x := 20
+		for _, item := range row {
+			c.rect(image.Rect(x, y, x+10, y+10), palette[item.index%len(palette)])
+			c.text(x+14, y+10, item.label, colorText)
+			x += item.width
+		}
+		y += 18
+	}
+	return nil
+}
+
+// drawPie 以各序列的最新值绘制饼图，图例在右侧显示数值和占比；非正数的序列不参与绘制
+func drawPie(c canvas, list []series, opts Options) error {
+	type slice struct {
+		index int
+		name  string
+		value float64
+	}
+	var slices []slice
+	total := 0.0
+	for i, s := range list {
+		v := s.last()
+		if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		slices = append(slices, slice{index: i, name: s.name, value: v})
+		total += v
+	}
+	if len(slices) == 0 {
+		return fmt.Errorf("饼图没有大于 0 的数据")
+	}
+
+	labels := make([]string, len(slices))
+	legendWidth := 0
+	for i, s := range slices {
+		value := formatTick(s.value)
+		if opts.Unit != "" {
+			value += " " + opts.Unit
+		}
+		labels[i] = fmt.Sprintf("%s  %s (%.1f%%)", truncateLabel(s.name, 32), value, s.value/total*100)
+		if w := c.textWidth(labels[i]) + 30; w > legendWidth {
+			legendWidth = w
+		}
+	}
+	if max := opts.Width / 2; legendWidth > max {
+		legendWidth = max
+	}
+
+	pieWidth := opts.Width - legendWidth
+	radius := int(math.Min(float64(pieWidth), float64(opts.Height))/2) - 20
+	if radius < 20 {
+		return fmt.Errorf("图片尺寸过小: %dx%d", opts.Width, opts.Height)
+	}
+	cx, cy := pieWidth/2, opts.Height/2
+	start := 0.0
+	for _, s := range slices {
+		end := start + s.value/total*2*math.Pi
+		c.sector(cx, cy, radius, start, end, palette[s.index%len(palette)])
+		start = end
+	}
+
+	// 图例垂直居中，放不下的序列合并为一行提示
+	rows := (opts.Height - 20) / 18
+	if len(slices) > rows {
+		labels = append(labels[:rows-1], fmt.Sprintf("... %d more", len(slices)-rows+1))
+		slices = slices[:rows-1]
+	}
+	y := (opts.Height-len(labels)*18)/2 + 4
+	x := pieWidth
+	for i, label := range labels {
+		if i < len(slices) {
+			c.rect(image.Rect(x, y, x+10, y+10), palette[slices[i].index%len(palette)])
+		}
+		c.text(x+14, y+10, label, colorText)
+		y += 18
+	}
+	return nil
+}
+
+// groupSeries 按序列名称分组并排序，返回序列列表和所有时间点
+func groupSeries(points []models.DataPoint) ([]series, []int64) {
+	byName := make(map[string]*series)
+	timeSet := make(map[int64]bool)
+	for _, p := range points {
+		s := byName[p.Type]
+		if s == nil {
+			s = &series{name: p.Type}
+			byName[p.Type] = s
+		}
+		s.times = append(s.times, p.UnixTime)
+		s.values = append(s.values, p.Value)
+		timeSet[p.UnixTime] = true
+	}
+
+	list := make([]series, 0, len(byName))
+	for _, s := range byName {
+		sort.Sort(byTime{s})
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	times := make([]int64, 0, len(timeSet))
+	for t := range timeSet {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return list, times
+}
+
+// byTime 按时间排序序列的数据点
+type byTime struct{ *series }
+
+func (s byTime) Len() int           { return len(s.times) }
+func (s byTime) Less(i, j int) bool { return s.times[i] < s.times[j] }
+func (s byTime) Swap(i, j int) {
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+// valueRange 返回所有序列的最小值和最大值，纵轴始终包含 0
+func valueRange(list []series) (float64, float64) {
+	minV, maxV := 0.0, 0.0
+	for _, s := range list {
+		for _, v := range s.values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			minV = math.Min(minV, v)
+			maxV = math.Max(maxV, v)
+		}
+	}
+	if minV == maxV {
+		maxV = minV + 1
+	}
+	return minV, maxV
+}
+
+// niceTicks 计算覆盖 [minV, maxV] 的整齐刻度
+func niceTicks(minV, maxV float64, count int) []float64 {
+	rawStep := (maxV - minV) / float64(count)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*magnitude >= rawStep {
+			step = m * magnitude
+			break
+		}
+	}
+	start := math.Floor(minV/step) * step
+	var ticks []float64
+	for v := start; v <= maxV+step*0.5 || len(ticks) < 2; v += step {
+		ticks = append(ticks, v)
+		if v >= maxV {
+			break
+		}
+	}
+	return ticks
+}
+
+// formatTick 格式化纵轴刻度，较大的值使用 K/M/G 缩写
+func formatTick(v float64) string {
+	magnitude := math.Abs(v)
+	suffix := ""
+	switch {
+	case magnitude >= 1e9:
+		v, suffix = v/1e9, "G"
+	case magnitude >= 1e6:
+		v, suffix = v/1e6, "M"
+	case magnitude >= 1e4:
+		v, suffix = v/1e3, "K"
+	}
+	s := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s + suffix
+}
+
+// drawTimeAxis 绘制横轴时间标签，跨天的数据显示日期
+func drawTimeAxis(c canvas, plot image.Rectangle, times []int64, xOf func(int64) int, loc *time.Location) {
+	layout := "15:04"
+	if len(times) > 1 && times[len(times)-1]-times[0] >= 24*3600 {
+		layout = "01-02 15:04"
+	}
+	labelWidth := c.textWidth(layout) + 16
+	maxLabels := plot.Dx() / labelWidth
+	if maxLabels < 1 {
+		maxLabels = 1
+	}
+	step := (len(times) + maxLabels - 1) / maxLabels
+	for i := 0; i < len(times); i += step {
+		x := xOf(times[i])
+		vline(c, x, plot.Max.Y, plot.Max.Y+4, colorAxis)
+		label := time.Unix(times[i], 0).In(loc).Format(layout)
+		c.text(x-c.textWidth(label)/2, plot.Max.Y+17, label, colorText)
+	}
+}
+
+// legendItem 图例中的一项
+type legendItem struct {
+	index int
+	label string
+	width int
+}
+
+// layoutLegend 按可用宽度将图例分行，过长的名称会被截断
+func layoutLegend(c canvas, list []series, width int) [][]legendItem {
+	var rows [][]legendItem
+	var row []legendItem
+	x := 0
+	for i, s := range list {
+		label := truncateLabel(s.name, 40)
+		item := legendItem{index: i, label: label, width: c.textWidth(label) + 30}
+		if x+item.width > width && len(row) > 0 {
+			rows = append(rows, row)
+			row, x = nil, 0
+		}
+		row = append(row, item)
+		x += item.width
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// truncateLabel 截断到 n 个字符
+func truncateLabel(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+func hline(c canvas, x0, x1, y int, col color.Color) {
+	c.rect(image.Rect(x0, y, x1+1, y+1), col)
+}
+
+func vline(c canvas, x, y0, y1 int, col color.Color) {
+	c.rect(image.Rect(x, y0, x+1, y1+1), col)
+}
@@ -0,0 +1,107 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/font"
+
+	"fsvchart-notify/internal/models"
+)
+
+// svgFontSize SVG 文字的字号，与 PNG 使用的 7x13 点阵字体大小接近
+const svgFontSize = 12
+
+// SVG 将一组查询数据绘制为 SVG 图片，布局与 PNG 相同，文字不限于 ASCII 字符
+func SVG(data *models.QueryDataPoints, opts Options) ([]byte, error) {
+	opts, err := prepare(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	doc := &svgDoc{face: newFace()}
+	if err := drawChart(doc, data, opts); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, 'PingFang SC', 'Microsoft YaHei', sans-serif" font-size="%d">`+"\n",
+		opts.Width, opts.Height, opts.Width, opts.Height, svgFontSize)
+	buf.Write(doc.buf.Bytes())
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// svgDoc 以 SVG 元素记录绘制操作的 canvas
+type svgDoc struct {
+	buf  bytes.Buffer
+	face font.Face // 加载了字体时用于计算文本宽度
+}
+
+func (d *svgDoc) rect(r image.Rectangle, c color.Color) {
+	fmt.Fprintf(&d.buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", r.Min.X, r.Min.Y, r.Dx(), r.Dy(), svgColor(c))
+}
+
+func (d *svgDoc) line(x0, y0, x1, y1 int, c color.RGBA) {
+	fmt.Fprintf(&d.buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2" stroke-linecap="round"/>`+"\n", x0, y0, x1, y1, svgColor(c))
+}
+
+func (d *svgDoc) area(x0, y0, x1, y1, base int, c color.NRGBA) {
+	if x1 <= x0 {
+		return
+	}
+	fmt.Fprintf(&d.buf, `<polygon points="%d,%d %d,%d %d,%d %d,%d" fill="%s"/>`+"\n", x0, base, x0, y0, x1, y1, x1, base, svgColor(c))
+}
+
+func (d *svgDoc) dot(x, y, r int, c color.RGBA) {
+	fmt.Fprintf(&d.buf, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n", x, y, r, svgColor(c))
+}
+
+func (d *svgDoc) sector(cx, cy, r int, start, end float64, c color.RGBA) {
+	if end-start >= 2*math.Pi-1e-9 {
+		d.dot(cx, cy, r, c)
+		return
+	}
+	point := func(a float64) (float64, float64) {
+		return float64(cx) + float64(r)*math.Sin(a), float64(cy) - float64(r)*math.Cos(a)
+	}
+	sx, sy := point(start)
+	ex, ey := point(end)
+	large := 0
+	if end-start > math.Pi {
+		large = 1
+	}
+	fmt.Fprintf(&d.buf, `<path d="M%d,%d L%.2f,%.2f A%d,%d 0 %d 1 %.2f,%.2f Z" fill="%s"/>`+"\n", cx, cy, sx, sy, r, r, large, ex, ey, svgColor(c))
+}
+
+func (d *svgDoc) text(x, y int, s string, c color.Color) {
+	fmt.Fprintf(&d.buf, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", x, y, svgColor(c), html.EscapeString(s))
+}
+
+// textWidth 返回文本宽度：加载了字体时按字体计算，否则 Latin 字符按 7 像素、其他字符（如中文）按字号估算
+func (d *svgDoc) textWidth(s string) int {
+	if textFont != nil {
+		return font.MeasureString(d.face, s).Round()
+	}
+	width := 0
+	for _, r := range s {
+		if r <= 0xff {
+			width += 7
+		} else {
+			width += svgFontSize
+		}
+	}
+	return width
+}
+
+// svgColor 将颜色转换为 CSS 颜色，透明色使用 rgba
+func svgColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", n.R, n.G, n.B, float64(n.A)/0xff)
+}
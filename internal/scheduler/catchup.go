@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 错过调度时间点后的补发策略
+const (
+	CatchUpSkip = "skip" // 跳过错过的时间点（默认，与旧版本行为一致）
+	CatchUpOnce = "once" // 无论错过多少个时间点，只补发一次
+	CatchUpAll  = "all"  // 每个错过的时间点各补发一次
+)
+
+const (
+	// DefaultCatchUpGrace 默认补发宽限期（秒），超过宽限期的时间点不再补发
+	DefaultCatchUpGrace = 3600
+
+	// tickDriftTolerance 定时器漂移容忍度，在此范围内错过的时间点按正常调度执行
+	tickDriftTolerance = 2 * time.Minute
+
+	// maxCatchUpRuns 单个任务一次最多补发的次数，避免长时间停机后集中推送
+	maxCatchUpRuns = 24
+
+	// lastTickKey scheduler_state 中记录最后一次调度检查时间的键
+	lastTickKey = "last_tick"
+)
+
+// plannedRun 一次计划执行
+type plannedRun struct {
+	scheduledAt time.Time // 对应的调度时间点
+	catchUp     bool      // 是否为补发的错过时间点
+}
+
+// NormalizeCatchUpPolicy 校验补发策略，空字符串视为 skip
+func NormalizeCatchUpPolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		return CatchUpSkip, nil
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("无效的补发策略 %q，可选值: skip、once、all", policy)
+	}
+}
+
+// planRuns 根据上次调度检查时间和补发策略，计算本次检查需要执行的时间点
+// lastTick 为零值时（首次启动）只检查当前分钟
+func planRuns(s Schedule, policy string, grace time.Duration, lastTick, now time.Time) []plannedRun {
+	nowMinute := now.Truncate(time.Minute)
+
+	// 确定回溯窗口：skip 策略只容忍定时器漂移，其余策略回溯到宽限期
+	lookback := tickDriftTolerance
+	if policy != CatchUpSkip && grace > lookback {
+		lookback = grace
+	}
+	// 系统时间被回拨导致 lastTick 晚于当前时间时，同样只检查当前分钟
+	from := nowMinute.Add(-time.Second)
+	if !lastTick.IsZero() && !lastTick.After(nowMinute) {
+		from = lastTick
+		if earliest := nowMinute.Add(-lookback); from.Before(earliest) {
+			from = earliest
+		}
+	}
+
+	// 枚举 (from, nowMinute] 区间内的时间点，区分漂移范围内的和真正错过的
+	var missed []time.Time
+	var recent time.Time
+	for t := s.Next(from); !t.IsZero() && !t.After(nowMinute); t = s.Next(t) {
+		if nowMinute.Sub(t) < tickDriftTolerance {
+			recent = t
+			continue
+		}
+		missed = append(missed, t)
+	}
+
+	var runs []plannedRun
+	switch policy {
+	case CatchUpOnce:
+		// 错过的时间点与当前时间点合并为一次执行
+		if !recent.IsZero() {
+			runs = append(runs, plannedRun{scheduledAt: recent})
+		} else if len(missed) > 0 {
+			runs = append(runs, plannedRun{scheduledAt: missed[len(missed)-1], catchUp: true})
+		}
+		return runs
+	case CatchUpAll:
+		if len(missed) > maxCatchUpRuns {
+			log.Printf("[scheduler] 错过 %d 个时间点，仅补发最近的 %d 个", len(missed), maxCatchUpRuns)
+			missed = missed[len(missed)-maxCatchUpRuns:]
+		}
+		for _, t := range missed {
+			runs = append(runs, plannedRun{scheduledAt: t, catchUp: true})
+		}
+	}
+	if !recent.IsZero() {
+		runs = append(runs, plannedRun{scheduledAt: recent})
+	}
+	return runs
+}
+
+// loadLastTick 读取持久化的最后一次调度检查时间，不存在时返回零值
+func loadLastTick(db *sql.DB) time.Time {
+	var value string
+	err := db.QueryRow("SELECT value FROM scheduler_state WHERE key = ?", lastTickKey).Scan(&value)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[scheduler] 读取调度状态失败: %v", err)
+		}
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("[scheduler] 解析调度状态失败: %v", err)
+		return time.Time{}
+	}
+	return t
+}
+
+// saveLastTick 持久化最后一次调度检查时间，重启后据此识别停机期间错过的时间点
+func saveLastTick(db *sql.DB, tick time.Time) {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO scheduler_state (key, value, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, lastTickKey, tick.Format(time.RFC3339))
+	if err != nil {
+		log.Printf("[scheduler] 保存调度状态失败: %v", err)
+	}
+}
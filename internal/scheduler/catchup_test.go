@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanRuns(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatalf("解析时间 %q 失败: %v", s, err)
+		}
+		return v
+	}
+	mustCron := func(expr string) Schedule {
+		s, err := ParseCron(expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) 返回错误: %v", expr, err)
+		}
+		return s
+	}
+	hourly := mustCron("0 * * * *")
+	every5 := mustCron("*/5 * * * *")
+	const hour = time.Hour
+
+	type run struct {
+		at      string
+		catchUp bool
+	}
+	tests := []struct {
+		name     string
+		schedule Schedule
+		policy   string
+		grace    time.Duration
+		lastTick string // 为空表示首次启动
+		now      string
+		want     []run
+	}{
+		// 正常调度
+		{"正常调度", hourly, CatchUpSkip, hour, "2026-01-01 09:59:00", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"不在调度时间点", hourly, CatchUpAll, hour, "2026-01-01 10:29:00", "2026-01-01 10:30:30", nil},
+		{"首次启动只检查当前分钟", hourly, CatchUpAll, 3 * hour, "", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"首次启动不补发", hourly, CatchUpAll, 3 * hour, "", "2026-01-01 10:30:30", nil},
+		{"系统时间回拨只检查当前分钟", hourly, CatchUpAll, 3 * hour, "2026-01-01 12:00:00", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+
+		// 定时器漂移：漂移容忍度内错过的时间点按正常调度执行，与策略无关
+		{"漂移 1 分钟 skip 正常执行", hourly, CatchUpSkip, hour, "2026-01-01 09:58:00", "2026-01-01 10:01:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"漂移 1 分钟 once 正常执行", hourly, CatchUpOnce, hour, "2026-01-01 09:58:00", "2026-01-01 10:01:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"漂移 1 分钟 all 正常执行", hourly, CatchUpAll, hour, "2026-01-01 09:58:00", "2026-01-01 10:01:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"漂移达到容忍度 skip 跳过", hourly, CatchUpSkip, hour, "2026-01-01 09:58:00", "2026-01-01 10:02:30", nil},
+		{"漂移达到容忍度 all 补发", hourly, CatchUpAll, hour, "2026-01-01 09:58:00", "2026-01-01 10:02:30",
+			[]run{{"2026-01-01 10:00:00", true}}},
+
+		// 停机后重启：上次检查 07:30，期间错过 08:00、09:00、10:00
+		{"重启后 skip 只执行当前时间点", hourly, CatchUpSkip, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"重启后 skip 不补发", hourly, CatchUpSkip, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:30:30", nil},
+		{"重启后 once 与当前时间点合并", hourly, CatchUpOnce, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"重启后 once 补发最近一次", hourly, CatchUpOnce, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:30:30",
+			[]run{{"2026-01-01 10:00:00", true}}},
+		{"重启后 all 逐个补发并执行当前时间点", hourly, CatchUpAll, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:00:30",
+			[]run{{"2026-01-01 08:00:00", true}, {"2026-01-01 09:00:00", true}, {"2026-01-01 10:00:00", false}}},
+		{"重启后 all 逐个补发", hourly, CatchUpAll, 3 * hour, "2026-01-01 07:30:00", "2026-01-01 10:30:30",
+			[]run{{"2026-01-01 08:00:00", true}, {"2026-01-01 09:00:00", true}, {"2026-01-01 10:00:00", true}}},
+
+		// 宽限期：超过宽限期的时间点不再补发
+		{"宽限期外的时间点 all 不补发", hourly, CatchUpAll, hour, "2026-01-01 07:30:00", "2026-01-01 10:30:30",
+			[]run{{"2026-01-01 10:00:00", true}}},
+		{"宽限期外的时间点 once 不补发", hourly, CatchUpOnce, 20 * time.Minute, "2026-01-01 07:30:00", "2026-01-01 10:30:30", nil},
+		{"宽限期小于漂移容忍度时按容忍度回溯", hourly, CatchUpAll, 0, "2026-01-01 09:58:00", "2026-01-01 10:01:30",
+			[]run{{"2026-01-01 10:00:00", false}}},
+		{"上次检查晚于宽限期起点时从上次检查开始", hourly, CatchUpAll, 3 * hour, "2026-01-01 09:00:00", "2026-01-01 10:30:30",
+			[]run{{"2026-01-01 10:00:00", true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lastTick time.Time
+			if tt.lastTick != "" {
+				lastTick = at(tt.lastTick)
+			}
+			got := planRuns(tt.schedule, tt.policy, tt.grace, lastTick, at(tt.now))
+			if len(got) != len(tt.want) {
+				t.Fatalf("planRuns 返回 %d 次执行 %v，期望 %d 次 %v", len(got), got, len(tt.want), tt.want)
+			}
+			for i, w := range tt.want {
+				if !got[i].scheduledAt.Equal(at(w.at)) || got[i].catchUp != w.catchUp {
+					t.Errorf("第 %d 次执行为 (%s, catchUp=%v)，期望 (%s, catchUp=%v)",
+						i+1, got[i].scheduledAt.Format(time.RFC3339), got[i].catchUp, w.at, w.catchUp)
+				}
+			}
+		})
+	}
+
+	t.Run("all 补发次数上限", func(t *testing.T) {
+		// 停机 4 小时，每 5 分钟一次，错过 47 个时间点，只补发最近的 maxCatchUpRuns 个
+		got := planRuns(every5, CatchUpAll, 5*hour, at("2026-01-01 06:00:00"), at("2026-01-01 10:00:30"))
+		if len(got) != maxCatchUpRuns+1 {
+			t.Fatalf("planRuns 返回 %d 次执行，期望 %d 次", len(got), maxCatchUpRuns+1)
+		}
+		first := at("2026-01-01 10:00:00").Add(-time.Duration(maxCatchUpRuns) * 5 * time.Minute)
+		if !got[0].scheduledAt.Equal(first) || !got[0].catchUp {
+			t.Errorf("第 1 次执行为 (%s, catchUp=%v)，期望补发 %s",
+				got[0].scheduledAt.Format(time.RFC3339), got[0].catchUp, first.Format(time.RFC3339))
+		}
+		last := got[len(got)-1]
+		if !last.scheduledAt.Equal(at("2026-01-01 10:00:00")) || last.catchUp {
+			t.Errorf("最后一次执行为 (%s, catchUp=%v)，期望正常执行 10:00",
+				last.scheduledAt.Format(time.RFC3339), last.catchUp)
+		}
+	})
+
+	t.Run("once 不受补发次数上限影响", func(t *testing.T) {
+		got := planRuns(every5, CatchUpOnce, 5*hour, at("2026-01-01 06:00:00"), at("2026-01-01 10:02:30"))
+		if len(got) != 1 || !got[0].scheduledAt.Equal(at("2026-01-01 10:00:00")) || !got[0].catchUp {
+			t.Errorf("planRuns 返回 %v，期望只补发 10:00 一次", got)
+		}
+	})
+}
+
+func TestNormalizeCatchUpPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", CatchUpSkip, false},
+		{"skip", CatchUpSkip, false},
+		{" Once ", CatchUpOnce, false},
+		{"ALL", CatchUpAll, false},
+		{"latest", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCatchUpPolicy(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeCatchUpPolicy(%q) = %q, %v，期望 %q，返回错误: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
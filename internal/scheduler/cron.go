@@ -0,0 +1,396 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 解析后的 5 段 cron 表达式：分 时 日 月 周
+//
+// 除标准语法（*、?、列表、范围、步长、月份/星期英文缩写）外，还支持：
+//   - 日字段：L（月末）、L-n（月末前 n 天）、nW（离 n 日最近的工作日，1W 即每月第一个工作日）、LW（每月最后一个工作日）
+//   - 周字段：nL（当月最后一个星期 n）、n#k（当月第 k 个星期 n），0 和 7 均表示周日
+//   - 宏：@yearly、@annually、@monthly、@weekly、@daily、@midnight、@hourly
+//
+// 日字段和周字段同时受限时，与 Vixie cron 一致，任意一个匹配即触发。
+type CronSchedule struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+	domRule []domRule
+	dowRule []dowRule
+}
+
+// domRule 日字段的特殊规则（L / L-n / nW / LW）
+type domRule struct {
+	last    bool // 以月末为基准
+	offset  int  // L-n 中的 n
+	day     int  // nW 中的 n
+	weekday bool // 取最近的工作日
+}
+
+// dowRule 周字段的特殊规则（nL / n#k）
+type dowRule struct {
+	weekday int // 0-6，0 为周日
+	nth     int // 第几个，-1 表示最后一个
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteField = cronField{name: "分钟", min: 0, max: 59}
+	hourField   = cronField{name: "小时", min: 0, max: 23}
+	domField    = cronField{name: "日", min: 1, max: 31}
+	monthField  = cronField{name: "月", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	dowField = cronField{name: "周", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// maxCronSearchDays Next 向后搜索的最大天数，超过则认为表达式永远不会触发（如 2 月 30 日）
+const maxCronSearchDays = 366 * 5
+
+// ParseCron 解析 cron 表达式
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("cron 表达式为空")
+	}
+
+	spec := expr
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := cronMacros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("不支持的 cron 宏: %s", spec)
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为 5 段（分 时 日 月 周），实际为 %d 段: %s", len(fields), expr)
+	}
+
+	s := &CronSchedule{expr: expr}
+	var err error
+	if s.minute, err = parseCronField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if err = s.parseDom(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if err = s.parseDow(fields[4]); err != nil {
+		return nil, err
+	}
+
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron 表达式在未来 %d 天内不会触发: %s", maxCronSearchDays, expr)
+	}
+	return s, nil
+}
+
+// String 返回原始表达式
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+// Next 返回严格晚于 t 的下一次触发时间（使用 t 所在时区），找不到时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	from := t.Truncate(time.Minute).Add(time.Minute)
+	y, m, d := from.Date()
+
+	for i := 0; i < maxCronSearchDays; i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, loc)
+		if !s.matchDay(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+			for min := 0; min < 60; min++ {
+				if s.minute&(1<<uint(min)) == 0 {
+					continue
+				}
+				candidate := time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, loc)
+				// 夏令时跳过的时间会被 time.Date 归一化到其他小时，这里直接忽略
+				if candidate.Hour() != h || candidate.Before(from) {
+					continue
+				}
+				return candidate
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// matchDay 判断某一天是否命中月、日、周字段
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	for _, r := range s.domRule {
+		if r.match(t) {
+			domMatch = true
+			break
+		}
+	}
+
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	for _, r := range s.dowRule {
+		if r.match(t) {
+			dowMatch = true
+			break
+		}
+	}
+
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dowMatch
+	case s.dowStar:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+func (r domRule) match(t time.Time) bool {
+	lastDay := daysInMonth(t)
+	target := r.day
+	if r.last {
+		target = lastDay - r.offset
+	}
+	if target < 1 {
+		return false
+	}
+	if target > lastDay {
+		// 例如 31W 在小月中没有对应日期
+		return false
+	}
+	if r.weekday {
+		target = nearestWeekday(t.Year(), t.Month(), target, lastDay, t.Location())
+	}
+	return t.Day() == target
+}
+
+func (r dowRule) match(t time.Time) bool {
+	if int(t.Weekday()) != r.weekday {
+		return false
+	}
+	if r.nth == -1 {
+		return t.Day()+7 > daysInMonth(t)
+	}
+	return (t.Day()-1)/7+1 == r.nth
+}
+
+// daysInMonth 返回 t 所在月份的天数
+func daysInMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+// nearestWeekday 返回离 day 最近的工作日（周一至周五），不跨月
+func nearestWeekday(year int, month time.Month, day, lastDay int, loc *time.Location) int {
+	switch time.Date(year, month, day, 0, 0, 0, 0, loc).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// parseDom 解析日字段，识别 L / L-n / LW / nW
+func (s *CronSchedule) parseDom(field string) error {
+	s.domStar = strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		upper := strings.ToUpper(part)
+		switch {
+		case upper == "L":
+			s.domRule = append(s.domRule, domRule{last: true})
+		case upper == "LW":
+			s.domRule = append(s.domRule, domRule{last: true, weekday: true})
+		case strings.HasPrefix(upper, "L-"):
+			n, err := strconv.Atoi(upper[2:])
+			if err != nil || n < 0 || n > 30 {
+				return fmt.Errorf("日字段 %q 无效: L-n 中 n 应在 0-30 之间", part)
+			}
+			s.domRule = append(s.domRule, domRule{last: true, offset: n})
+		case strings.HasSuffix(upper, "W"):
+			n, err := strconv.Atoi(strings.TrimSuffix(upper, "W"))
+			if err != nil || n < 1 || n > 31 {
+				return fmt.Errorf("日字段 %q 无效: nW 中 n 应在 1-31 之间", part)
+			}
+			s.domRule = append(s.domRule, domRule{day: n, weekday: true})
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		bits, err := parseCronField(strings.Join(plain, ","), domField)
+		if err != nil {
+			return err
+		}
+		s.dom = bits
+	}
+	return nil
+}
+
+// parseDow 解析周字段，识别 nL / n#k
+func (s *CronSchedule) parseDow(field string) error {
+	s.dowStar = strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		upper := strings.ToUpper(part)
+		switch {
+		case strings.Contains(upper, "#"):
+			pieces := strings.SplitN(upper, "#", 2)
+			weekday, err := parseCronValue(pieces[0], dowField)
+			if err != nil {
+				return err
+			}
+			nth, err := strconv.Atoi(pieces[1])
+			if err != nil || nth < 1 || nth > 5 {
+				return fmt.Errorf("周字段 %q 无效: n#k 中 k 应在 1-5 之间", part)
+			}
+			s.dowRule = append(s.dowRule, dowRule{weekday: weekday % 7, nth: nth})
+		case len(upper) > 1 && strings.HasSuffix(upper, "L"):
+			weekday, err := parseCronValue(strings.TrimSuffix(upper, "L"), dowField)
+			if err != nil {
+				return err
+			}
+			s.dowRule = append(s.dowRule, dowRule{weekday: weekday % 7, nth: -1})
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		bits, err := parseCronField(strings.Join(plain, ","), dowField)
+		if err != nil {
+			return err
+		}
+		// 7 与 0 均表示周日
+		if bits&(1<<7) != 0 {
+			bits = bits&^(1<<7) | 1
+		}
+		s.dow = bits
+	}
+	return nil
+}
+
+// parseCronField 解析逗号分隔的普通字段，返回位图
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf("%s字段 %q 无效: 存在空的列表项", f.name, field)
+		}
+
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段 %q 无效: 步长必须为正整数", f.name, part)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			lo, hi = f.min, f.max
+			if f.max == 7 {
+				hi = 6 // 周字段的 * 不需要重复包含 7
+			}
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s字段 %q 无效: 范围起点大于终点", f.name, part)
+			}
+		default:
+			var err error
+			if lo, err = parseCronValue(rangePart, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// "5/15" 表示从 5 开始每 15 个单位
+			if step > 1 {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue 解析单个取值，支持英文缩写
+func parseCronValue(s string, f cronField) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s字段取值 %q 无效", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段取值 %d 超出范围 %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
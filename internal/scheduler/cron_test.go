@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("时区 %s 不可用: %v", name, err)
+	}
+	return loc
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("解析时间 %q 失败: %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		// 标准语法
+		{"每 15 分钟", "*/15 * * * *", "2026-01-01 10:07", "2026-01-01 10:15"},
+		{"严格晚于起点", "0 9 * * *", "2026-01-01 09:00", "2026-01-02 09:00"},
+		{"工作日范围", "0 9 * * MON-FRI", "2026-01-02 10:00", "2026-01-05 09:00"},
+		{"起点加步长", "5/20 * * * *", "2026-01-01 10:26", "2026-01-01 10:45"},
+		{"月份缩写", "0 0 1 MAR *", "2026-01-15 00:00", "2026-03-01 00:00"},
+		{"7 表示周日", "0 9 * * 7", "2026-01-01 00:00", "2026-01-04 09:00"},
+
+		// L / L-n
+		{"L 月末", "0 9 L * *", "2026-02-10 00:00", "2026-02-28 09:00"},
+		{"L 跨到下月", "0 9 L * *", "2026-02-28 10:00", "2026-03-31 09:00"},
+		{"L-2 月末前 2 天", "0 9 L-2 * *", "2026-02-10 00:00", "2026-02-26 09:00"},
+		{"L-0 等同于 L", "0 9 L-0 * *", "2026-04-01 00:00", "2026-04-30 09:00"},
+
+		// nW / LW
+		{"15W 周六提前到周五", "0 9 15W * *", "2026-08-01 00:00", "2026-08-14 09:00"},
+		{"1W 周日顺延到周一", "0 9 1W * *", "2026-01-15 00:00", "2026-02-02 09:00"},
+		{"1W 周六不跨月，顺延到周一", "0 9 1W * *", "2026-07-15 00:00", "2026-08-03 09:00"},
+		{"31W 周日月末不跨月，提前到周五", "0 9 31W * *", "2026-05-01 00:00", "2026-05-29 09:00"},
+		{"31W 跳过小月", "0 9 31W * *", "2026-04-01 00:00", "2026-05-29 09:00"},
+		{"LW 月末周六提前到周五", "0 9 LW * *", "2026-02-01 00:00", "2026-02-27 09:00"},
+		{"LW 月末周日提前到周五", "0 9 LW * *", "2026-05-01 00:00", "2026-05-29 09:00"},
+
+		// nL / n#k
+		{"5L 最后一个周五", "0 9 * * 5L", "2026-01-01 00:00", "2026-01-30 09:00"},
+		{"FRIL 英文缩写", "0 9 * * FRIL", "2026-01-01 00:00", "2026-01-30 09:00"},
+		{"1#2 第二个周一", "0 9 * * 1#2", "2026-01-01 00:00", "2026-01-12 09:00"},
+		{"MON#1 第一个周一", "0 9 * * MON#1", "2026-01-06 00:00", "2026-02-02 09:00"},
+		{"4#5 没有第五个周四的月份跳过", "0 9 * * 4#5", "2026-02-01 00:00", "2026-04-30 09:00"},
+
+		// 宏
+		{"@hourly", "@hourly", "2026-01-01 10:07", "2026-01-01 11:00"},
+		{"@daily", "@daily", "2026-01-01 10:07", "2026-01-02 00:00"},
+		{"@midnight", "@midnight", "2026-01-01 10:07", "2026-01-02 00:00"},
+		{"@weekly", "@weekly", "2026-01-01 10:07", "2026-01-04 00:00"},
+		{"@monthly", "@monthly", "2026-01-01 10:07", "2026-02-01 00:00"},
+		{"@yearly", "@yearly", "2026-01-01 10:07", "2027-01-01 00:00"},
+		{"@annually 不区分大小写", "@ANNUALLY", "2026-01-01 10:07", "2027-01-01 00:00"},
+
+		// 日字段和周字段同时受限时任意一个匹配即触发
+		{"日周均受限时周匹配", "0 9 13 * 5", "2026-01-01 00:00", "2026-01-02 09:00"},
+		{"日周均受限时日匹配", "0 9 13 * 5", "2026-01-10 00:00", "2026-01-13 09:00"},
+		{"L 与周字段取并集", "0 9 L * 1", "2026-01-27 00:00", "2026-01-31 09:00"},
+		{"周字段为 * 时只看日", "0 9 13 * *", "2026-01-01 00:00", "2026-01-13 09:00"},
+		{"日字段为 ? 时只看周", "0 9 ? * 5", "2026-01-10 00:00", "2026-01-16 09:00"},
+		{"日字段以 * 开头时与 Vixie cron 一致只看周", "0 9 */10 * 1", "2026-01-06 00:00", "2026-01-12 09:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 返回错误: %v", tt.expr, err)
+			}
+			got := s.Next(utc(tt.from))
+			if want := utc(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s，期望 %s", tt.from, got.Format(time.RFC3339), want.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	at := func(s string, offset int) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("解析时间 %q 失败: %v", s, err)
+		}
+		// 以 UTC 偏移确定时刻，避免夏令时切换时本地时间的歧义
+		return v.Add(-time.Duration(offset) * time.Hour).In(ny)
+	}
+	const est, edt = -5, -4
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// 2026-03-08 02:00 EST 跳到 03:00 EDT
+		{"春季跳过的时间当天不触发", "30 2 * * *", at("2026-03-07 03:00", est), at("2026-03-09 02:30", edt)},
+		{"春季跳过的小时不触发", "0 * * * *", at("2026-03-08 01:00", est), at("2026-03-08 03:00", edt)},
+		{"春季切换后正常触发", "0 9 * * *", at("2026-03-07 10:00", est), at("2026-03-08 09:00", edt)},
+		{"春季切换前正常触发", "30 1 * * *", at("2026-03-07 03:00", est), at("2026-03-08 01:30", est)},
+
+		// 2026-11-01 02:00 EDT 回拨到 01:00 EST，01:00-01:59 出现两次
+		{"秋季重复的时间触发第一次", "30 1 * * *", at("2026-11-01 00:00", edt), at("2026-11-01 01:30", edt)},
+		{"秋季重复的时间不触发第二次", "30 1 * * *", at("2026-11-01 01:30", edt), at("2026-11-02 01:30", est)},
+		{"秋季回拨后起点在第二次之后", "30 1 * * *", at("2026-11-01 01:45", est), at("2026-11-02 01:30", est)},
+		{"秋季回拨后按本地时间的下一个小时触发", "0 * * * *", at("2026-11-01 01:00", edt), at("2026-11-01 02:00", est)},
+		{"秋季切换后正常触发", "0 9 * * *", at("2026-10-31 10:00", edt), at("2026-11-01 09:00", est)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 返回错误: %v", tt.expr, err)
+			}
+			got := s.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s，期望 %s", tt.from.Format(time.RFC3339), got.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+			if got.Location() != ny {
+				t.Errorf("Next 返回的时区为 %s，期望 %s", got.Location(), ny)
+			}
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"空表达式", ""},
+		{"段数不足", "0 9 * *"},
+		{"段数过多", "0 9 * * * *"},
+		{"未知宏", "@every5m"},
+		{"分钟超出范围", "60 * * * *"},
+		{"小时超出范围", "0 24 * * *"},
+		{"日为 0", "0 0 0 * *"},
+		{"月份超出范围", "0 0 1 13 *"},
+		{"周超出范围", "0 0 * * 8"},
+		{"范围起点大于终点", "0 0 * * 5-1"},
+		{"步长为 0", "*/0 * * * *"},
+		{"空的列表项", "0,,30 * * * *"},
+		{"L-n 超出范围", "0 0 L-31 * *"},
+		{"nW 超出范围", "0 0 32W * *"},
+		{"n#k 中 k 超出范围", "0 0 * * 1#6"},
+		{"nL 星期无效", "0 0 * * 9L"},
+		{"永远不会触发", "0 0 30 2 *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q) 期望返回错误", tt.expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/channel"
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// modeNames 报告模式在发送记录中的显示名称
+var modeNames = map[string]string{
+	channel.ModeChart:  "图表消息",
+	channel.ModeText:   "文本卡片消息",
+	channel.ModeHybrid: "混合卡片消息",
+}
+
+// renderedReport 某个渠道渲染后的消息体
+type renderedReport struct {
+	payload interface{}
+	hash    string
+	err     error
+}
+
+// deliverReport 将报告发送到任务绑定的所有 WebHook
+// 每种渠道只渲染一次，相同地址的 WebHook 只发送一次；发送结果写入执行记录、发送记录和推送状态
+// ctx 取消（调度器停止）时正在进行的发送和重试等待会尽快返回
+func deliverReport(ctx context.Context, db *sql.DB, rec *runRecorder, taskID, sourceID int64, webhooks []models.FeishuWebhook, report *channel.Report) {
+	rendered := make(map[string]*renderedReport)
+	sentWebhooks := make(map[string]bool)
+	sentCount := 0
+	skippedCount := 0
+
+	for _, webhook := range webhooks {
+		// Slack 频道、邮件收件人等保存在渠道选项中，按类型、地址和选项去重
+		options, _ := json.Marshal(webhook.Options)
+		dedupeKey := webhook.Type + "|" + webhook.URL + "|" + string(options)
+		if sentWebhooks[dedupeKey] {
+			log.Printf("[TaskQueue] 跳过重复的webhook URL: %s", webhook.URL)
+			skippedCount++
+			continue
+		}
+
+		ch, err := channel.Get(webhook.Type)
+		if err != nil {
+			log.Printf("[TaskQueue] webhook (ID=%d) 渠道无效: %v", webhook.ID, err)
+			rec.addDelivery(newDelivery(webhook.ID, webhook.Name, report.Mode, "", time.Now(), err))
+			insertPushStatus(db, sourceID, webhook.ID, err)
+			continue
+		}
+		webhook.Type, _ = channel.NormalizeType(webhook.Type)
+
+		r, ok := rendered[webhook.Type]
+		if !ok {
+			r = &renderedReport{}
+			r.payload, r.err = ch.Render(report)
+			if r.err != nil {
+				log.Printf("[TaskQueue] 构建%s消息失败: %v", ch.Name(), r.err)
+			} else {
+				r.hash = service.PayloadHash(r.payload)
+			}
+			rendered[webhook.Type] = r
+		}
+
+		log.Printf("[TaskQueue] 发送%s到%s webhook (ID=%d)", modeNames[report.Mode], ch.Name(), webhook.ID)
+
+		webhookMutex := getWebhookMutex(webhook.ID)
+		webhookMutex.Lock()
+
+		sendStart := time.Now()
+		hash := r.hash
+		err = r.err
+		if err == nil {
+			var sent interface{}
+			sent, err = ch.Send(ctx, webhook, report, r.payload)
+			// 渠道在发送时改写了消息体（如飞书将图表替换为图片）时，记录实际发送内容的哈希
+			if sent != nil {
+				hash = service.PayloadHash(sent)
+			}
+		}
+		delivery := newDelivery(webhook.ID, webhook.Name, report.Mode, hash, sendStart, err)
+		delivery.Channel = webhook.Type
+		rec.addDelivery(delivery)
+		record := models.SendRecord{TaskID: taskID, WebhookID: webhook.ID, TaskName: report.Title}
+		record.ButtonText, record.ButtonURL = service.FirstButton(report.Buttons)
+		service.AddSendRecord(webhook.URL, record, fmt.Sprintf("成功发送%s%s: %s", ch.Name(), modeNames[report.Mode], report.Title), err)
+
+		if err != nil {
+			log.Printf("[TaskQueue] 发送失败: %v", err)
+			insertPushStatus(db, sourceID, webhook.ID, err)
+
+			if strings.Contains(err.Error(), "frequency limited") || strings.Contains(err.Error(), "too many request") {
+				waitTime := 3 * time.Second
+				log.Printf("[TaskQueue] 检测到频率限制，等待 %v", waitTime)
+				time.Sleep(waitTime)
+			}
+		} else {
+			sentCount++
+			log.Printf("[TaskQueue] %s发送成功", modeNames[report.Mode])
+			insertPushStatus(db, sourceID, webhook.ID, nil)
+			sentWebhooks[dedupeKey] = true
+		}
+
+		webhookMutex.Unlock()
+	}
+
+	log.Printf("[TaskQueue] %s推送完成: 配置的webhook数: %d, 实际发送: %d, 因重复跳过: %d",
+		modeNames[report.Mode], len(webhooks), sentCount, skippedCount)
+}
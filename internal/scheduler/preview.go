@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"database/sql"
+	"log"
+
+	"fsvchart-notify/internal/channel"
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// Preview 任务预览结果：各报告渲染后的消息体和每个查询的获取结果
+type Preview struct {
+	Cards   []PreviewCard         `json:"cards"`
+	Queries []models.TaskRunQuery `json:"queries"`
+	Message string                `json:"message,omitempty"` // 没有生成消息的原因
+}
+
+// PreviewCard 一份报告在渠道中渲染后的消息体，与实际发送的内容相同（签名字段除外）
+type PreviewCard struct {
+	Mode        string      `json:"mode"` // chart/text/hybrid
+	Channel     string      `json:"channel"`
+	Payload     interface{} `json:"payload"`
+	PayloadHash string      `json:"payload_hash"`
+	Error       string      `json:"error,omitempty"`
+
+	// 服务端绘制的图表，与企业微信、Slack 等渠道发送的图片布局相同
+	Charts []channel.ChartSVG `json:"charts,omitempty"`
+}
+
+// PreviewTask 执行任务的查询并按渠道渲染消息体，不发送消息也不写入执行记录
+// channelType 为空时按飞书自定义机器人渲染
+func PreviewTask(db *sql.DB, def *TaskDefinition, channelType string) (*Preview, error) {
+	channelType, err := channel.NormalizeType(channelType)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := channel.Get(channelType)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &runRecorder{run: models.TaskRun{Queries: []models.TaskRunQuery{}}}
+	if err := def.resolveSources(rec); err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{Cards: []PreviewCard{}}
+	if len(def.Queries) == 0 {
+		rec.note("未找到任何有效查询")
+	} else {
+		for _, report := range buildReports(db, def, rec) {
+			card := PreviewCard{Mode: report.Mode, Channel: channelType, Charts: report.ChartSVGs()}
+			card.Payload, err = ch.Render(report)
+			if err != nil {
+				log.Printf("[PreviewTask] 构建%s消息失败: %v", ch.Name(), err)
+				card.Error = err.Error()
+			} else {
+				card.PayloadHash = service.PayloadHash(card.Payload)
+			}
+			preview.Cards = append(preview.Cards, card)
+		}
+	}
+	preview.Queries = rec.run.Queries
+	preview.Message = rec.run.Message
+	return preview, nil
+}
@@ -0,0 +1,548 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"fsvchart-notify/internal/channel"
+	"fsvchart-notify/internal/models"
+	"fsvchart-notify/internal/service"
+)
+
+// TaskDefinition 构建报告所需的任务配置，来自数据库中的任务或预览时未保存的任务
+type TaskDefinition struct {
+	ID                int64 // 未保存的任务为 0
+	Name              string
+	Enabled           bool
+	SourceID          int64
+	TimeRange         string
+	Step              float64 // 查询步长（秒），小于 1 时根据时间范围自动计算
+	CardTitle         string
+	CardTemplate      string
+	MetricLabel       string
+	CustomMetricLabel string
+	Unit              string
+	Buttons           []models.Button // 按钮的链接可使用模板变量，构建报告时按查询时间范围渲染
+	ShowDataLabel     bool
+	Timezone          string
+	Mentions          []models.Mention   // 任务级的 @ 配置
+	Layout            *models.CardLayout // 自定义卡片布局，为 nil 时使用默认卡片结构
+	Queries           []TaskQuery        // 按 display_order 排序，通过 AddQuery 按查询语句去重
+}
+
+// TaskQuery 任务中单个查询及其展示配置
+type TaskQuery struct {
+	Query             string
+	ChartTemplateID   int64
+	PromQLName        string
+	Unit              string
+	MetricLabel       string
+	CustomMetricLabel string
+	InitialUnit       string
+	DisplayOrder      int
+	DisplayMode       string           // chart, text, both
+	SourceID          int64            // PromQL 指定的数据源，为 0 时使用任务的数据源
+	Mentions          []models.Mention // PromQL 级的 @ 配置
+	Link              string           // 查询的详情链接，可使用模板变量
+	Stats             []string         // 图表下方显示的序列统计项
+
+	source models.MetricsSource // 实际使用的数据源，由 resolveSources 设置
+}
+
+// AddQuery 添加查询，查询语句重复时跳过并返回 false
+func (d *TaskDefinition) AddQuery(q TaskQuery) bool {
+	for _, existing := range d.Queries {
+		if existing.Query == q.Query {
+			log.Printf("[TaskQueue] 跳过重复查询: %s", q.Query)
+			return false
+		}
+	}
+	d.Queries = append(d.Queries, q)
+	log.Printf("[TaskQueue] 添加唯一查询: %s (unit=%s, label=%s, mode=%s, order=%d)", q.Query, q.Unit, q.MetricLabel, q.DisplayMode, q.DisplayOrder)
+	return true
+}
+
+// LoadTaskDefinition 读取任务配置及其查询
+// 任务没有关联 PromQL 时依次使用旧格式的 push_task_query 表和 push_task.query 字段
+func LoadTaskDefinition(db *sql.DB, taskID int64) (*TaskDefinition, error) {
+	def := &TaskDefinition{ID: taskID}
+	var enabled int
+	var showDataLabel sql.NullInt64
+	var mentionsJSON, buttonText, buttonURL, buttonsJSON string
+	var layoutID int64
+
+	err := db.QueryRow(`
+		SELECT source_id, name, time_range, step,
+		       card_title, card_template, metric_label, unit,
+		       button_text, button_url, enabled, COALESCE(show_data_label, 0) as show_data_label,
+		       COALESCE(custom_metric_label, '') as custom_metric_label,
+		       COALESCE(timezone, '') as timezone,
+		       COALESCE(mentions, '') as mentions,
+		       COALESCE(card_layout_id, 0) as card_layout_id,
+		       COALESCE(buttons, '') as buttons
+		FROM push_task
+		WHERE id = ?
+	`, taskID).Scan(&def.SourceID, &def.Name, &def.TimeRange, &def.Step,
+		&def.CardTitle, &def.CardTemplate, &def.MetricLabel, &def.Unit,
+		&buttonText, &buttonURL, &enabled, &showDataLabel, &def.CustomMetricLabel, &def.Timezone, &mentionsJSON, &layoutID, &buttonsJSON)
+	if err != nil {
+		return nil, err
+	}
+	def.Enabled = enabled == 1
+	def.ShowDataLabel = showDataLabel.Int64 == 1
+
+	// 任务级的 @ 配置，解析失败时忽略
+	def.Mentions, err = service.ParseMentions(mentionsJSON)
+	if err != nil {
+		log.Printf("[TaskQueue] 任务 @ 配置无效，已忽略: %v", err)
+	}
+
+	// 任务的按钮，解析失败时不显示按钮
+	def.Buttons, err = service.ParseButtons(buttonsJSON, buttonText, buttonURL)
+	if err != nil {
+		log.Printf("[TaskQueue] 任务按钮配置无效，已忽略: %v", err)
+	}
+
+	// 自定义卡片布局，布局不存在时使用默认卡片结构
+	if layoutID > 0 {
+		if def.Layout, err = LoadCardLayout(db, layoutID); err != nil {
+			log.Printf("[TaskQueue] 获取卡片布局 %d 失败，使用默认卡片结构: %v", layoutID, err)
+		}
+	}
+
+	// 查询任务的所有查询及其独立配置（按 display_order 排序）
+	rows, err := db.Query(`
+		SELECT p.query, ptp.chart_template_id, p.name,
+		       COALESCE(ptp.unit, '') as unit,
+		       COALESCE(ptp.metric_label, 'pod') as metric_label,
+		       COALESCE(ptp.custom_metric_label, '') as custom_metric_label,
+		       COALESCE(ptp.initial_unit, '') as initial_unit,
+		       COALESCE(ptp.display_order, 0) as display_order,
+		       COALESCE(ptp.display_mode, 'chart') as display_mode,
+		       COALESCE(p.source_id, 0) as source_id,
+		       COALESCE(ptp.mentions, '') as mentions,
+		       COALESCE(ptp.link, '') as link,
+		       COALESCE(ptp.stats, '') as stats
+		FROM push_task_promql ptp
+		JOIN promql p ON ptp.promql_id = p.id
+		WHERE ptp.task_id = ?
+		ORDER BY ptp.display_order ASC, ptp.id ASC
+	`, taskID)
+	if err != nil {
+		log.Printf("[TaskQueue] 查询PromQL失败: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var q TaskQuery
+		var promqlMentions, promqlStats string
+		if err := rows.Scan(&q.Query, &q.ChartTemplateID, &q.PromQLName,
+			&q.Unit, &q.MetricLabel, &q.CustomMetricLabel, &q.InitialUnit, &q.DisplayOrder, &q.DisplayMode, &q.SourceID, &promqlMentions, &q.Link, &promqlStats); err != nil {
+			log.Printf("[TaskQueue] 扫描PromQL行失败: %v", err)
+			continue
+		}
+		if q.Mentions, err = service.ParseMentions(promqlMentions); err != nil {
+			log.Printf("[TaskQueue] PromQL %s 的 @ 配置无效，已忽略: %v", q.PromQLName, err)
+		}
+		if q.Stats, err = service.ParseStats(promqlStats); err != nil {
+			log.Printf("[TaskQueue] PromQL %s 的统计项配置无效，已忽略: %v", q.PromQLName, err)
+		}
+		def.AddQuery(q)
+	}
+	if len(def.Queries) > 0 {
+		return def, nil
+	}
+
+	// 如果没有找到查询，尝试使用旧的单查询格式
+	log.Printf("[TaskQueue] 未找到PromQL查询，尝试使用旧格式查询")
+	legacy := func(query string, chartTemplateID int64) TaskQuery {
+		// 使用任务级别的配置作为默认值，旧格式没有 initial_unit 和 display_order，默认为图表模式
+		return TaskQuery{
+			Query:             query,
+			ChartTemplateID:   chartTemplateID,
+			Unit:              def.Unit,
+			MetricLabel:       def.MetricLabel,
+			CustomMetricLabel: def.CustomMetricLabel,
+			DisplayMode:       "chart",
+		}
+	}
+
+	queryRows, err := db.Query(`
+		SELECT query, chart_template_id
+		FROM push_task_query
+		WHERE task_id = ?
+	`, taskID)
+	if err == nil {
+		defer queryRows.Close()
+		for queryRows.Next() {
+			var query string
+			var chartTemplateID int64
+			if err := queryRows.Scan(&query, &chartTemplateID); err != nil {
+				log.Printf("[TaskQueue] 扫描查询行失败: %v", err)
+				continue
+			}
+			def.AddQuery(legacy(query, chartTemplateID))
+		}
+	}
+
+	// 如果仍然没有查询，尝试从push_task表中获取
+	if len(def.Queries) == 0 {
+		var query string
+		var chartTemplateID int64
+		err := db.QueryRow(`
+			SELECT query, chart_template_id
+			FROM push_task
+			WHERE id = ?
+		`, taskID).Scan(&query, &chartTemplateID)
+		if err == nil && query != "" {
+			def.AddQuery(legacy(query, chartTemplateID))
+		}
+	}
+	return def, nil
+}
+
+// LoadCardLayout 读取卡片布局
+func LoadCardLayout(db *sql.DB, id int64) (*models.CardLayout, error) {
+	var layout models.CardLayout
+	err := db.QueryRow(`
+		SELECT id, name, COALESCE(description, ''), kind, content, created_at, updated_at
+		FROM card_layout
+		WHERE id = ?
+	`, id).Scan(&layout.ID, &layout.Name, &layout.Description, &layout.Kind, &layout.Content, &layout.CreatedAt, &layout.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// resolveSources 确定每个查询使用的数据源，PromQL 可以指定独立的数据源（如 Loki）
+// 任务数据源获取失败时返回错误；单个查询的数据源获取失败时记录到 rec 并移除该查询
+func (d *TaskDefinition) resolveSources(rec *runRecorder) error {
+	source, err := service.GetMetricsSource(d.SourceID)
+	if err != nil {
+		log.Printf("[TaskQueue] 获取数据源失败: %v", err)
+		return err
+	}
+	querySources := map[int64]models.MetricsSource{d.SourceID: source}
+	resolved := d.Queries[:0]
+	for _, q := range d.Queries {
+		id := q.SourceID
+		if id <= 0 {
+			id = d.SourceID
+		}
+		qs, ok := querySources[id]
+		if !ok {
+			qs, err = service.GetMetricsSource(id)
+			if err != nil {
+				log.Printf("[TaskQueue] 获取查询 %s 的数据源失败: %v", q.PromQLName, err)
+				rec.addQuery(models.TaskRunQuery{Name: q.PromQLName, Query: q.Query, Mode: q.DisplayMode, Error: err.Error()})
+				continue
+			}
+			querySources[id] = qs
+		}
+		q.source = qs
+		resolved = append(resolved, q)
+	}
+	d.Queries = resolved
+	return nil
+}
+
+// buildReports 执行任务的查询并构建报告，查询结果记录到 rec
+// 存在 both 模式的查询或使用自定义卡片布局时生成一份混合报告，否则文本和图表查询各生成一份报告；没有数据时返回空列表并在 rec 中记录原因
+func buildReports(db *sql.DB, def *TaskDefinition, rec *runRecorder) []*channel.Report {
+	// 解析任务时区，用于图表横轴和卡片时间；未配置时各卡片沿用原有默认时区
+	loc, err := service.LoadTimezone(def.Timezone)
+	if err != nil {
+		log.Printf("[TaskQueue] %v，使用默认时区", err)
+		loc = nil
+	}
+
+	// 解析time_range为持续时间
+	duration := parseDurationString(def.TimeRange)
+	end := time.Now()
+	start := end.Add(-duration)
+
+	// 如果 step 为 0 或太小（< 1 秒），根据 duration 自动计算最优 step
+	step := def.Step
+	if step < 1 {
+		calculatedStep := service.GetDurationStep(duration)
+		step = calculatedStep.Seconds()
+		log.Printf("[TaskQueue] Step 为 0 或太小，根据时间范围 %s 自动计算: %v (%.0f 秒)",
+			def.TimeRange, calculatedStep, step)
+	}
+
+	log.Printf("[TaskQueue] 查询时间范围: start=%s, end=%s, step=%ds",
+		start.Format("2006-01-02 15:04:05"),
+		end.Format("2006-01-02 15:04:05"),
+		int64(step))
+
+	// 按钮和查询链接中的模板变量使用本次查询的时间范围
+	buttons := service.RenderButtons(def.Buttons, service.NewLinkData(start, end, ""))
+	linkOf := func(q TaskQuery) string {
+		link, err := service.RenderLink(q.Link, service.NewLinkData(start, end, q.Query))
+		if err != nil {
+			log.Printf("[TaskQueue] 查询 %s 的链接无效，已忽略: %v", q.PromQLName, err)
+			return ""
+		}
+		return link
+	}
+
+	// newReport 构建发送到各渠道的报告
+	newReport := func(mode string, elements []service.HybridElement) *channel.Report {
+		return &channel.Report{
+			TaskID:        def.ID,
+			TaskName:      def.Name,
+			RunAt:         end,
+			Start:         start,
+			End:           end,
+			Mode:          mode,
+			Title:         def.CardTitle,
+			Template:      def.CardTemplate,
+			Unit:          def.Unit,
+			Buttons:       buttons,
+			ShowDataLabel: def.ShowDataLabel,
+			Location:      loc,
+			Mentions:      def.Mentions,
+			Layout:        def.Layout,
+			Elements:      elements,
+		}
+	}
+
+	// chartTypeOf 获取查询使用的图表类型
+	chartTypeOf := func(q TaskQuery) string {
+		var chartType string
+		err := db.QueryRow("SELECT chart_type FROM chart_template WHERE id = ?", q.ChartTemplateID).Scan(&chartType)
+		if err != nil {
+			log.Printf("[TaskQueue] 获取图表类型失败 (ID=%d): %v，使用默认类型 'area'", q.ChartTemplateID, err)
+			chartType = "area"
+		}
+		return service.GetSupportedChartType(chartType)
+	}
+
+	// labelsOf 确定查询使用的标签，未配置时使用任务级别的标签
+	labelsOf := func(q TaskQuery) (string, string) {
+		if q.MetricLabel == "" {
+			return def.MetricLabel, q.CustomMetricLabel
+		}
+		return q.MetricLabel, q.CustomMetricLabel
+	}
+	fetchRange := func(q TaskQuery) ([]models.DataPoint, error) {
+		metricLabel, customLabel := labelsOf(q)
+		return service.FetchMetrics(q.source, q.Query, start, end, time.Duration(step)*time.Second, metricLabel, customLabel, q.InitialUnit, q.Unit, loc)
+	}
+	fetchLatest := func(q TaskQuery) ([]service.LatestMetric, error) {
+		metricLabel, customLabel := labelsOf(q)
+		return service.FetchLatestMetrics(q.source, q.Query, metricLabel, customLabel, q.InitialUnit, q.Unit)
+	}
+
+	log.Printf("[TaskQueue] 使用 PromQL 级别的展示模式配置")
+
+	// 检查是否需要使用混合卡片，自定义卡片布局使用所有查询的结果生成一张卡片
+	needHybridCard := def.Layout != nil
+	for _, query := range def.Queries {
+		if query.DisplayMode == "both" {
+			needHybridCard = true
+			break
+		}
+	}
+
+	log.Printf("[TaskQueue] 是否使用混合卡片: %v", needHybridCard)
+
+	// 如果需要混合卡片，构建混合元素列表
+	if needHybridCard {
+		var hybridElements []service.HybridElement
+
+		for _, query := range def.Queries {
+			mode := query.DisplayMode
+			if mode == "" {
+				mode = "chart" // 默认为图表模式
+			}
+
+			promqlName := query.PromQLName
+			if promqlName == "" {
+				promqlName = "查询"
+			}
+			metricLabel, _ := labelsOf(query)
+
+			if mode == "text" || mode == "both" {
+				log.Printf("[TaskQueue] 获取文本数据: %s", query.Query)
+				latestMetrics, err := fetchLatest(query)
+				rec.addQuery(newTextQuery(promqlName, query.Query, len(latestMetrics), err))
+				if err != nil {
+					log.Printf("[TaskQueue] 获取最新指标值失败: %v", err)
+				} else {
+					hybridElements = append(hybridElements, service.HybridElement{
+						DisplayOrder: query.DisplayOrder,
+						DisplayMode:  "text",
+						PromQLName:   promqlName,
+						Query:        query.Query,
+						Link:         linkOf(query),
+						TextMetrics:  latestMetrics,
+						Unit:         query.Unit,
+						MetricLabel:  metricLabel,
+						Mentions:     query.Mentions,
+					})
+					log.Printf("[TaskQueue] 添加文本元素: %s (order=%d)", promqlName, query.DisplayOrder)
+				}
+			}
+
+			if mode == "chart" || mode == "both" {
+				log.Printf("[TaskQueue] 获取图表数据: %s", query.Query)
+				chartType := chartTypeOf(query)
+				dataPoints, err := fetchRange(query)
+				rec.addQuery(newChartQuery(promqlName, query.Query, dataPoints, err))
+				if err != nil {
+					log.Printf("[TaskQueue] 获取指标数据失败: %v", err)
+				} else {
+					hybridElements = append(hybridElements, service.HybridElement{
+						DisplayOrder: query.DisplayOrder,
+						DisplayMode:  "chart",
+						PromQLName:   promqlName,
+						Query:        query.Query,
+						Link:         linkOf(query),
+						ChartData: &models.QueryDataPoints{
+							DataPoints: dataPoints,
+							ChartType:  chartType,
+							ChartTitle: promqlName,
+							Unit:       query.Unit,
+							Link:       linkOf(query),
+							Stats:      query.Stats,
+						},
+						ChartType:     chartType,
+						ShowDataLabel: def.ShowDataLabel,
+						Mentions:      query.Mentions,
+					})
+					log.Printf("[TaskQueue] 添加图表元素: %s (order=%d, points=%d)", promqlName, query.DisplayOrder, len(dataPoints))
+				}
+			}
+		}
+
+		if len(hybridElements) == 0 {
+			log.Printf("[TaskQueue] 未获取到任何混合元素")
+			rec.note("未获取到任何查询数据")
+			return nil
+		}
+		log.Printf("[TaskQueue] 共收集到 %d 个混合元素", len(hybridElements))
+		return []*channel.Report{newReport(channel.ModeHybrid, hybridElements)}
+	}
+
+	// 按 PromQL 的 display_mode 分组数据（非混合模式）
+	var chartQueries, textQueries []TaskQuery
+	for _, query := range def.Queries {
+		switch query.DisplayMode {
+		case "text":
+			textQueries = append(textQueries, query)
+		case "chart", "":
+			chartQueries = append(chartQueries, query)
+		}
+	}
+
+	log.Printf("[TaskQueue] 图表模式查询数: %d, 文本模式查询数: %d", len(chartQueries), len(textQueries))
+
+	var reports []*channel.Report
+
+	// 文本模式：为每个 PromQL 获取最新值，按查询顺序生成文本元素
+	if len(textQueries) > 0 {
+		log.Printf("[TaskQueue] 执行文本模式推送，获取最新指标值")
+		var textElements []service.HybridElement
+		for i, query := range textQueries {
+			log.Printf("[TaskQueue] 获取查询 %d 的最新指标值: %s", i+1, query.Query)
+			promqlName := query.PromQLName
+			if promqlName == "" {
+				promqlName = fmt.Sprintf("查询 %d", i+1)
+			}
+			metricLabel, _ := labelsOf(query)
+
+			latestMetrics, err := fetchLatest(query)
+			rec.addQuery(newTextQuery(promqlName, query.Query, len(latestMetrics), err))
+			if err != nil {
+				log.Printf("[TaskQueue] 获取最新指标值失败: %v", err)
+				continue
+			}
+
+			textElements = append(textElements, service.HybridElement{
+				DisplayOrder: query.DisplayOrder,
+				DisplayMode:  "text",
+				PromQLName:   promqlName,
+				Query:        query.Query,
+				Link:         linkOf(query),
+				TextMetrics:  latestMetrics,
+				Unit:         query.Unit,
+				MetricLabel:  metricLabel,
+				Mentions:     query.Mentions,
+			})
+			log.Printf("[TaskQueue] PromQL '%s' 获取到 %d 个最新指标", promqlName, len(latestMetrics))
+		}
+
+		if len(textElements) == 0 {
+			log.Printf("[TaskQueue] 未获取到任何最新指标")
+			rec.note("未获取到任何查询数据")
+			return nil
+		}
+		reports = append(reports, newReport(channel.ModeText, textElements))
+	}
+
+	// 图表模式：获取时间序列数据，相同标题的系列只保留第一个
+	if len(chartQueries) > 0 {
+		log.Printf("[TaskQueue] 执行图表模式推送，获取时间序列数据")
+		var chartElements []service.HybridElement
+		seenSeries := make(map[string]bool)
+
+		for i, query := range chartQueries {
+			chartType := chartTypeOf(query)
+			log.Printf("[TaskQueue] 查询 %d: 使用图表类型 %s", i+1, chartType)
+
+			chartTitle := query.PromQLName
+			if chartTitle == "" {
+				chartTitle = fmt.Sprintf("查询 %d", i+1)
+			}
+
+			log.Printf("[TaskQueue] 开始获取查询 %d 的指标数据: %s", i+1, query.Query)
+			dataPoints, err := fetchRange(query)
+			rec.addQuery(newChartQuery(chartTitle, query.Query, dataPoints, err))
+			if err != nil {
+				log.Printf("[TaskQueue] 获取指标数据失败: %v", err)
+				continue
+			}
+			if seenSeries[chartTitle] {
+				log.Printf("[TaskQueue] 跳过重复的数据系列: %s", chartTitle)
+				continue
+			}
+			seenSeries[chartTitle] = true
+
+			// 每个查询系列使用自己的单位，未设置时渠道使用任务级别的单位
+			chartElements = append(chartElements, service.HybridElement{
+				DisplayOrder: len(chartElements),
+				DisplayMode:  "chart",
+				PromQLName:   chartTitle,
+				Query:        query.Query,
+				Link:         linkOf(query),
+				ChartData: &models.QueryDataPoints{
+					DataPoints: dataPoints,
+					ChartType:  chartType,
+					ChartTitle: chartTitle,
+					Unit:       query.Unit,
+					Link:       linkOf(query),
+					Stats:      query.Stats,
+					Mentions:   query.Mentions,
+				},
+				ChartType:     chartType,
+				ShowDataLabel: def.ShowDataLabel,
+				Unit:          query.Unit,
+				Mentions:      query.Mentions,
+			})
+			log.Printf("[TaskQueue] 添加新的数据系列: %s (包含 %d 个数据点, 单位: %s)", chartTitle, len(dataPoints), query.Unit)
+		}
+
+		if len(chartElements) == 0 {
+			log.Printf("[TaskQueue] 未获取到任何数据点")
+			rec.note("未获取到任何查询数据")
+			return reports
+		}
+		reports = append(reports, newReport(channel.ModeChart, chartElements))
+	}
+	return reports
+}
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 任务执行的触发方式
+const (
+	TriggerSchedule = "schedule" // 按调度时间触发
+	TriggerCatchUp  = "catchup"  // 补发错过的调度时间点
+	TriggerManual   = "manual"   // 页面上手动执行
+	TriggerAPI      = "api"      // 通过 API 调用执行
+)
+
+// 任务执行状态
+const (
+	RunStatusRunning = "running"
+	RunStatusSuccess = "success"
+	RunStatusPartial = "partial" // 部分 WebHook 发送失败
+	RunStatusFailed  = "failed"
+	RunStatusSkipped = "skipped" // 没有可发送的内容或接收方
+)
+
+// runRecorder 记录一次任务执行的过程，执行结束时写入 task_run 表
+// 记录失败只打印日志，不影响任务本身的执行
+type runRecorder struct {
+	db        *sql.DB
+	mu        sync.Mutex
+	run       models.TaskRun
+	startedAt time.Time
+}
+
+// startTaskRun 创建执行记录，scheduledAt 为零值表示非调度触发
+func startTaskRun(db *sql.DB, taskID int64, trigger string, scheduledAt time.Time) *runRecorder {
+	rec := &runRecorder{
+		db:        db,
+		startedAt: time.Now(),
+		run: models.TaskRun{
+			TaskID:     taskID,
+			Trigger:    trigger,
+			Status:     RunStatusRunning,
+			Queries:    []models.TaskRunQuery{},
+			Deliveries: []models.TaskRunDelivery{},
+		},
+	}
+	rec.run.StartedAt = rec.startedAt.Format("2006-01-02 15:04:05")
+	if !scheduledAt.IsZero() {
+		rec.run.ScheduledAt = scheduledAt.Format("2006-01-02 15:04:05")
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO task_run (task_id, trigger_type, status, scheduled_at, started_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, taskID, trigger, RunStatusRunning, rec.run.ScheduledAt, rec.run.StartedAt)
+	if err != nil {
+		log.Printf("[TaskRun] 创建执行记录失败: %v", err)
+		return rec
+	}
+	rec.run.ID, _ = result.LastInsertId()
+	return rec
+}
+
+// addQuery 记录单个查询的获取结果
+func (r *runRecorder) addQuery(q models.TaskRunQuery) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Queries = append(r.run.Queries, q)
+}
+
+// addDelivery 记录单个 WebHook 的发送结果
+func (r *runRecorder) addDelivery(d models.TaskRunDelivery) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Deliveries = append(r.run.Deliveries, d)
+	if d.PayloadHash != "" {
+		r.run.PayloadHash = d.PayloadHash
+	}
+}
+
+// note 记录任务未发送或提前结束的原因
+func (r *runRecorder) note(format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Message = fmt.Sprintf(format, args...)
+}
+
+// finish 根据发送结果汇总执行状态并写入数据库
+func (r *runRecorder) finish(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finishedAt := time.Now()
+	r.run.FinishedAt = finishedAt.Format("2006-01-02 15:04:05")
+	r.run.DurationMs = finishedAt.Sub(r.startedAt).Milliseconds()
+
+	var succeeded, failed int
+	for _, d := range r.run.Deliveries {
+		if d.Status == RunStatusSuccess {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	switch {
+	case err != nil:
+		r.run.Status = RunStatusFailed
+		r.run.Message = err.Error()
+	case succeeded == 0 && failed == 0:
+		r.run.Status = RunStatusSkipped
+	case failed == 0:
+		r.run.Status = RunStatusSuccess
+	case succeeded == 0:
+		r.run.Status = RunStatusFailed
+	default:
+		r.run.Status = RunStatusPartial
+	}
+
+	if r.run.ID == 0 {
+		return
+	}
+	queries, _ := json.Marshal(r.run.Queries)
+	deliveries, _ := json.Marshal(r.run.Deliveries)
+	_, dbErr := r.db.Exec(`
+		UPDATE task_run
+		SET status = ?, finished_at = ?, duration_ms = ?, query_results = ?,
+		    deliveries = ?, payload_hash = ?, message = ?
+		WHERE id = ?
+	`, r.run.Status, r.run.FinishedAt, r.run.DurationMs, string(queries),
+		string(deliveries), r.run.PayloadHash, r.run.Message, r.run.ID)
+	if dbErr != nil {
+		log.Printf("[TaskRun] 更新执行记录失败: %v", dbErr)
+	}
+}
+
+// ListTaskRuns 分页查询任务的执行记录，按开始时间倒序
+func ListTaskRuns(db *sql.DB, taskID int64, page, pageSize int) ([]models.TaskRun, int, error) {
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM task_run WHERE task_id = ?", taskID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, task_id, trigger_type, status,
+		       COALESCE(scheduled_at, ''), started_at, COALESCE(finished_at, ''),
+		       COALESCE(duration_ms, 0), COALESCE(query_results, '[]'),
+		       COALESCE(deliveries, '[]'), COALESCE(payload_hash, ''), COALESCE(message, '')
+		FROM task_run
+		WHERE task_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, taskID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := []models.TaskRun{}
+	for rows.Next() {
+		var run models.TaskRun
+		var queries, deliveries string
+		if err := rows.Scan(&run.ID, &run.TaskID, &run.Trigger, &run.Status,
+			&run.ScheduledAt, &run.StartedAt, &run.FinishedAt,
+			&run.DurationMs, &queries, &deliveries, &run.PayloadHash, &run.Message); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(queries), &run.Queries); err != nil {
+			log.Printf("[TaskRun] 解析查询结果失败 (run=%d): %v", run.ID, err)
+		}
+		if err := json.Unmarshal([]byte(deliveries), &run.Deliveries); err != nil {
+			log.Printf("[TaskRun] 解析发送结果失败 (run=%d): %v", run.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, total, rows.Err()
+}
+
+// newDelivery 根据发送结果构建 WebHook 发送记录
+func newDelivery(webhookID int64, webhookName, mode, payloadHash string, start time.Time, err error) models.TaskRunDelivery {
+	d := models.TaskRunDelivery{
+		WebhookID:   webhookID,
+		WebhookName: webhookName,
+		Mode:        mode,
+		Status:      RunStatusSuccess,
+		DurationMs:  time.Since(start).Milliseconds(),
+		PayloadHash: payloadHash,
+	}
+	if err != nil {
+		d.Status = RunStatusFailed
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// newChartQuery 根据时间序列数据构建查询结果记录
+func newChartQuery(name, query string, points []models.DataPoint, err error) models.TaskRunQuery {
+	q := models.TaskRunQuery{Name: name, Query: query, Mode: "chart", PointCount: len(points)}
+	if err != nil {
+		q.Error = err.Error()
+		return q
+	}
+	series := make(map[string]bool)
+	for _, p := range points {
+		series[p.Type] = true
+	}
+	q.SeriesCount = len(series)
+	return q
+}
+
+// newTextQuery 根据最新值数据构建查询结果记录
+func newTextQuery(name, query string, metrics int, err error) models.TaskRunQuery {
+	q := models.TaskRunQuery{Name: name, Query: query, Mode: "text", SeriesCount: metrics, PointCount: metrics}
+	if err != nil {
+		q.Error = err.Error()
+	}
+	return q
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// Schedule 描述任务的触发时间
+type Schedule interface {
+	// Next 返回严格晚于 t 的下一次触发时间，没有则返回零值
+	Next(t time.Time) time.Time
+}
+
+// weeklySlot 每周固定的一个发送时间点
+type weeklySlot struct {
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+// WeeklySchedule 由 push_task_send_time 中的「星期 + HH:MM」记录组成的调度
+type WeeklySchedule struct {
+	slots []weeklySlot
+}
+
+// NewWeeklySchedule 根据发送时间记录构建调度，weekday 取值 1-7（周一到周日）
+// 无法解析的记录会被跳过并记录日志
+func NewWeeklySchedule(sendTimes []models.TaskSendTime) *WeeklySchedule {
+	ws := &WeeklySchedule{}
+	for _, st := range sendTimes {
+		if st.Weekday < 1 || st.Weekday > 7 {
+			log.Printf("[scheduler] 忽略无效的星期配置: weekday=%d", st.Weekday)
+			continue
+		}
+		hour, minute, err := parseSendTime(st.SendTime)
+		if err != nil {
+			log.Printf("[scheduler] 忽略无效的发送时间: %v", err)
+			continue
+		}
+		ws.slots = append(ws.slots, weeklySlot{
+			weekday: time.Weekday(st.Weekday % 7),
+			hour:    hour,
+			minute:  minute,
+		})
+	}
+	return ws
+}
+
+// Next 返回严格晚于 t 的下一个发送时间点
+func (ws *WeeklySchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	loc := t.Location()
+	y, m, d := t.Date()
+	for _, slot := range ws.slots {
+		// 最多向后看 7 天即可覆盖所有星期
+		for i := 0; i <= 7; i++ {
+			day := time.Date(y, m, d+i, 0, 0, 0, 0, loc)
+			if day.Weekday() != slot.weekday {
+				continue
+			}
+			candidate := time.Date(day.Year(), day.Month(), day.Day(), slot.hour, slot.minute, 0, 0, loc)
+			if !candidate.After(t) {
+				continue
+			}
+			if next.IsZero() || candidate.Before(next) {
+				next = candidate
+			}
+			break
+		}
+	}
+	return next
+}
+
+// multiSchedule 多个调度取最早的触发时间
+type multiSchedule []Schedule
+
+func (ms multiSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range ms {
+		candidate := s.Next(t)
+		if candidate.IsZero() {
+			continue
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// BuildTaskSchedule 组合任务的 cron 表达式和每周发送时间，任意一个命中即触发
+func BuildTaskSchedule(cronExpr string, sendTimes []models.TaskSendTime) (Schedule, error) {
+	var schedules multiSchedule
+	if strings.TrimSpace(cronExpr) != "" {
+		cron, err := ParseCron(cronExpr)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, cron)
+	}
+	if weekly := NewWeeklySchedule(sendTimes); len(weekly.slots) > 0 {
+		schedules = append(schedules, weekly)
+	}
+	return schedules, nil
+}
+
+// IsDue 判断调度在 now 所在的分钟是否应当触发
+func IsDue(s Schedule, now time.Time) bool {
+	minute := now.Truncate(time.Minute)
+	return s.Next(minute.Add(-time.Second)).Equal(minute)
+}
+
+// NextRunAt 计算任务下一次执行时间，没有可用调度时返回零值
+func NextRunAt(cronExpr string, sendTimes []models.TaskSendTime, now time.Time) (time.Time, error) {
+	s, err := BuildTaskSchedule(cronExpr, sendTimes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.Next(now), nil
+}
+
+// parseSendTime 解析 "HH:MM" 格式的发送时间
+func parseSendTime(s string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("发送时间格式应为 HH:MM: %q", s)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("发送时间小时无效: %q", s)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("发送时间分钟无效: %q", s)
+	}
+	return hour, minute, nil
+}
+
+// LoadTaskSendTimes 读取任务的每周发送时间
+func LoadTaskSendTimes(db *sql.DB, taskID int64) ([]models.TaskSendTime, error) {
+	rows, err := db.Query(`
+		SELECT id, task_id, weekday, send_time
+		FROM push_task_send_time
+		WHERE task_id = ?
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sendTimes []models.TaskSendTime
+	for rows.Next() {
+		var st models.TaskSendTime
+		if err := rows.Scan(&st.ID, &st.TaskID, &st.Weekday, &st.SendTime); err != nil {
+			return nil, err
+		}
+		sendTimes = append(sendTimes, st)
+	}
+	return sendTimes, rows.Err()
+}
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,9 +10,6 @@ import (
 	"sync"
 	"time"
 
-	// 假设你的项目中有：
-	"fsvchart-notify/internal/database"
-	"fsvchart-notify/internal/models"
 	"fsvchart-notify/internal/service"
 )
 
@@ -24,12 +22,21 @@ type TaskStatus struct {
 	LastSuccess  time.Time
 }
 
+// queuedRun 队列中的一次待执行任务
+type queuedRun struct {
+	taskID      int64
+	scheduledAt time.Time // 对应的调度时间点
+	catchUp     bool      // 补发错过的时间点，不受最小执行间隔限制
+	cron        bool      // 任务配置了 cron 表达式，执行频率由表达式决定，不受最小执行间隔限制
+}
+
 // TaskQueue 任务队列
 type TaskQueue struct {
-	tasks    chan int64
+	tasks    chan queuedRun
 	running  sync.Map
 	interval time.Duration
-	status   sync.Map // map[int64]*TaskStatus
+	status   sync.Map   // map[int64]*TaskStatus，存储后不再修改，更新时替换为新的副本
+	statusMu sync.Mutex // 串行化 updateTaskStatus 的读取、修改和写回
 }
 
 const (
@@ -40,7 +47,7 @@ const (
 var (
 	// 全局任务队列
 	taskQueue = &TaskQueue{
-		tasks:    make(chan int64, 100),
+		tasks:    make(chan queuedRun, 100),
 		interval: 500 * time.Millisecond,
 	}
 )
@@ -124,11 +131,28 @@ func (q *TaskQueue) canExecuteTask(taskID int64) bool {
 	return true
 }
 
+// canExecuteRun 检查队列中的一次执行是否可以进行，补发和 cron 任务只要求任务未在运行
+func (q *TaskQueue) canExecuteRun(run queuedRun) bool {
+	if !run.catchUp && !run.cron {
+		return q.canExecuteTask(run.taskID)
+	}
+	if status, exists := q.status.Load(run.taskID); exists && status.(*TaskStatus).IsRunning {
+		log.Printf("[TaskQueue] 任务 %d 正在运行中，跳过补发", run.taskID)
+		return false
+	}
+	return true
+}
+
 // updateTaskStatus 更新任务状态
+// 已存储的 TaskStatus 可能正被其他 goroutine 读取，这里复制一份修改后替换，不修改原值
 func (q *TaskQueue) updateTaskStatus(taskID int64, running bool, err error) {
+	q.statusMu.Lock()
+	defer q.statusMu.Unlock()
+
 	var status *TaskStatus
 	if existingStatus, exists := q.status.Load(taskID); exists {
-		status = existingStatus.(*TaskStatus)
+		copied := *existingStatus.(*TaskStatus)
+		status = &copied
 		status.IsRunning = running
 		if err != nil {
 			status.LastRunError = err
@@ -151,39 +175,29 @@ func (q *TaskQueue) updateTaskStatus(taskID int64, running bool, err error) {
 	q.status.Store(taskID, status)
 }
 
-// StartScheduler: 启动调度器
-func StartScheduler() {
-	log.Println("[scheduler] Starting scheduler...")
-
-	// 启动任务执行器
-	go taskQueue.run()
-
-	// 创建定时器，每1分钟检查一次
-	ticker := time.NewTicker(1 * time.Minute)
-
-	// 启动后台goroutine持续运行
-	go func() {
-		defer ticker.Stop()
-		for range ticker.C {
-			log.Println("[scheduler] Ticker triggered, processing tasks...")
-			processPushTasks()
-		}
-	}()
-
-	log.Println("[scheduler] Scheduler started successfully")
-}
-
-// run 运行任务队列
-func (q *TaskQueue) run() {
+// run 运行任务队列，ctx 取消后在当前任务执行完成时退出
+func (q *TaskQueue) run(ctx context.Context, db *sql.DB) {
 	log.Printf("[TaskQueue] 任务队列启动运行")
 	taskCount := 0
 
-	for taskID := range q.tasks {
+	for {
+		var run queuedRun
+		select {
+		case <-ctx.Done():
+			log.Printf("[TaskQueue] 任务队列停止运行，队列中剩余 %d 个任务", len(q.tasks))
+			return
+		case run = <-q.tasks:
+		}
+
+		taskID := run.taskID
 		taskCount++
 		log.Printf("[TaskQueue] ====== 开始处理第 %d 个任务 [ID=%d] ======", taskCount, taskID)
+		if run.catchUp {
+			log.Printf("[TaskQueue] 任务 %d 补发错过的时间点: %s", taskID, run.scheduledAt.Format("2006-01-02 15:04:05"))
+		}
 
 		// 检查任务是否可以执行
-		if !q.canExecuteTask(taskID) {
+		if !q.canExecuteRun(run) {
 			continue
 		}
 
@@ -195,7 +209,11 @@ func (q *TaskQueue) run() {
 		log.Printf("[TaskQueue] 任务 %d 开始执行，时间: %s", taskID, startTime.Format("2006-01-02 15:04:05"))
 
 		// 执行任务
-		err := runSingleTaskPush(database.GetDB(), taskID)
+		trigger := TriggerSchedule
+		if run.catchUp {
+			trigger = TriggerCatchUp
+		}
+		err := runSingleTaskPush(ctx, db, taskID, trigger, run.scheduledAt)
 
 		// 更新任务状态为已完成
 		q.updateTaskStatus(taskID, false, err)
@@ -208,16 +226,21 @@ func (q *TaskQueue) run() {
 
 		// 添加间隔，避免频率限制
 		log.Printf("[TaskQueue] 等待 %v 后处理下一个任务...", q.interval)
-		time.Sleep(q.interval)
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.interval):
+		}
 
 		log.Printf("[TaskQueue] ====== 任务 [ID=%d] 处理完成 ======\n", taskID)
 	}
 }
 
 // addTask 添加任务到队列
-func (q *TaskQueue) addTask(taskID int64) {
+func (q *TaskQueue) addTask(run queuedRun) {
+	taskID := run.taskID
+
 	// 检查任务是否可以执行
-	if !q.canExecuteTask(taskID) {
+	if !q.canExecuteRun(run) {
 		return
 	}
 
@@ -226,7 +249,7 @@ func (q *TaskQueue) addTask(taskID int64) {
 	log.Printf("[TaskQueue] 当前队列中有 %d 个任务等待执行", queueLen)
 
 	select {
-	case q.tasks <- taskID:
+	case q.tasks <- run:
 		log.Printf("[TaskQueue] 成功将任务 %d 加入队列，当前队列长度: %d", taskID, queueLen+1)
 	default:
 		log.Printf("[TaskQueue] 队列已满（容量=%d），任务 %d 被丢弃", cap(q.tasks), taskID)
@@ -234,70 +257,107 @@ func (q *TaskQueue) addTask(taskID int64) {
 }
 
 // processPushTasks 处理所有已启用的任务
-func processPushTasks() {
-	db := database.GetDB()
+func processPushTasks(db *sql.DB) {
 	now := time.Now()
 	log.Printf("[scheduler] %s processPushTasks start...", now.Format("2006/01/02 15:04:05"))
 
-	currentWeekday := int(now.Weekday())
-	if currentWeekday == 0 {
-		currentWeekday = 7 // 将周日从0改为7
-	}
-	currentTime := now.Format("15:04")
+	// 上次调度检查的时间，用于识别停机或定时器停顿期间错过的时间点
+	lastTick := loadLastTick(db)
+	defer saveLastTick(db, now.Truncate(time.Minute))
 
-	// 查询所有启用的任务及其发送时间
+	// 查询所有启用的任务
 	rows, err := db.Query(`
-		SELECT DISTINCT 
-			p.id, p.source_id, COALESCE(p.last_run_at, '') as last_run_at,
-			p.schedule_interval, pts.weekday, pts.send_time
-		FROM push_task p
-		LEFT JOIN push_task_send_time pts ON p.id = pts.task_id
-		WHERE p.enabled = 1
+		SELECT id, COALESCE(last_run_at, '') as last_run_at,
+		       COALESCE(schedule_interval, 0) as schedule_interval,
+		       COALESCE(cron_expr, '') as cron_expr,
+		       COALESCE(timezone, '') as timezone,
+		       COALESCE(catchup_policy, 'skip') as catchup_policy,
+		       COALESCE(catchup_grace, 0) as catchup_grace
+		FROM push_task
+		WHERE enabled = 1
 	`)
 	if err != nil {
 		log.Printf("[scheduler] Query push_task error: %v", err)
 		return
 	}
-	defer rows.Close()
 
+	var tasks []struct {
+		ID            int64
+		LastRunAt     string
+		SchedInterval int
+		CronExpr      string
+		Timezone      string
+		CatchUpPolicy string
+		CatchUpGrace  int
+	}
 	for rows.Next() {
 		var task struct {
 			ID            int64
-			SourceID      int64
 			LastRunAt     string
 			SchedInterval int
-			Weekday       sql.NullInt64
-			SendTime      sql.NullString
+			CronExpr      string
+			Timezone      string
+			CatchUpPolicy string
+			CatchUpGrace  int
 		}
+		if err := rows.Scan(&task.ID, &task.LastRunAt, &task.SchedInterval, &task.CronExpr, &task.Timezone,
+			&task.CatchUpPolicy, &task.CatchUpGrace); err != nil {
+			log.Printf("[scheduler] Scan task error: %v", err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
 
-		err := rows.Scan(
-			&task.ID, &task.SourceID, &task.LastRunAt,
-			&task.SchedInterval, &task.Weekday, &task.SendTime,
-		)
+	for _, task := range tasks {
+		sendTimes, err := LoadTaskSendTimes(db, task.ID)
 		if err != nil {
-			log.Printf("[scheduler] Scan task error: %v", err)
+			log.Printf("[scheduler] 读取任务 %d 的发送时间失败: %v", task.ID, err)
 			continue
 		}
 
-		// 检查是否是当前星期几
-		if !task.Weekday.Valid || int(task.Weekday.Int64) != currentWeekday {
+		schedule, err := BuildTaskSchedule(task.CronExpr, sendTimes)
+		if err != nil {
+			log.Printf("[scheduler] 任务 %d 的调度配置无效: %v", task.ID, err)
 			continue
 		}
 
-		// 检查是否到达发送时间
-		if !task.SendTime.Valid || task.SendTime.String != currentTime {
+		// 发送时间和 cron 表达式按任务时区解释，未配置时使用服务器本地时区
+		loc, err := service.LoadTimezone(task.Timezone)
+		if err != nil {
+			log.Printf("[scheduler] 任务 %d 的时区配置无效: %v", task.ID, err)
 			continue
 		}
+		if loc == nil {
+			loc = time.Local
+		}
+
+		policy, err := NormalizeCatchUpPolicy(task.CatchUpPolicy)
+		if err != nil {
+			log.Printf("[scheduler] 任务 %d %v，按 skip 处理", task.ID, err)
+			policy = CatchUpSkip
+		}
+		grace := time.Duration(task.CatchUpGrace) * time.Second
+		if task.CatchUpGrace <= 0 {
+			grace = DefaultCatchUpGrace * time.Second
+		}
 
-		// 检查任务状态
-		if !taskQueue.canExecuteTask(task.ID) {
+		// 计算自上次检查以来命中的时间点（cron 表达式或发送时间），按补发策略决定执行次数
+		runs := planRuns(schedule, policy, grace, lastTick.In(loc), now.In(loc))
+		if len(runs) == 0 {
+			continue
+		}
+
+		// 检查任务状态（补发和 cron 任务不受最小执行间隔限制）
+		isCron := task.CronExpr != ""
+		if !taskQueue.canExecuteRun(queuedRun{taskID: task.ID, catchUp: runs[0].catchUp, cron: isCron}) {
 			log.Printf("[scheduler] 任务 %d 不满足执行条件，跳过", task.ID)
 			continue
 		}
 
-		// 检查上次运行时间
-		if task.LastRunAt != "" {
-			lastRun, err := time.Parse("2006-01-02 15:04:05", task.LastRunAt)
+		// 检查上次运行时间（cron 表达式自身决定执行频率，不再受调度间隔限制）
+		if task.LastRunAt != "" && task.CronExpr == "" {
+			lastRun, err := time.ParseInLocation("2006-01-02 15:04:05", task.LastRunAt, time.Local)
 			if err != nil {
 				log.Printf("[scheduler] Parse last_run_at error: %v", err)
 				continue
@@ -320,7 +380,14 @@ func processPushTasks() {
 		}
 
 		// 将任务添加到队列
-		taskQueue.addTask(task.ID)
+		for i, run := range runs {
+			if run.catchUp {
+				log.Printf("[scheduler] 任务 %d 错过时间点 %s，按 %s 策略补发",
+					task.ID, run.scheduledAt.Format("2006-01-02 15:04:05"), policy)
+			}
+			// 同一批次中后续的执行紧跟在前一次之后，同样不受最小执行间隔限制
+			taskQueue.addTask(queuedRun{taskID: task.ID, scheduledAt: run.scheduledAt, catchUp: run.catchUp || i > 0, cron: isCron})
+		}
 	}
 }
 
@@ -384,654 +451,67 @@ func insertPushStatus(db *sql.DB, sourceID, webhookID int64, err error) {
 }
 
 // runSingleTaskPushWithoutLock 执行单个任务的推送（不加锁版本）
-// 此函数假设调用者已经获取了任务锁
-func runSingleTaskPushWithoutLock(db *sql.DB, taskID int64) error {
+// 此函数假设调用者已经获取了任务锁，执行过程记录到 rec，ctx 用于取消发送
+func runSingleTaskPushWithoutLock(ctx context.Context, db *sql.DB, taskID int64, rec *runRecorder) error {
 	log.Printf("[TaskQueue] ===== 开始执行任务 ID=%d =====", taskID)
 
-	// 获取任务详情
-	var sourceID int64
-	var name, timeRange, cardTitle, cardTemplate, metricLabel, unit, buttonText, buttonURL, customMetricLabel, pushMode string
-	var step float64
-	var enabled int
-	var showDataLabel sql.NullInt64
-
-	err := db.QueryRow(`
-		SELECT source_id, name, time_range, step, 
-		       card_title, card_template, metric_label, unit,
-		       button_text, button_url, enabled, COALESCE(show_data_label, 0) as show_data_label,
-		       COALESCE(custom_metric_label, '') as custom_metric_label,
-		       COALESCE(push_mode, 'chart') as push_mode
-		FROM push_task 
-		WHERE id = ?
-	`, taskID).Scan(&sourceID, &name, &timeRange, &step,
-		&cardTitle, &cardTemplate, &metricLabel, &unit,
-		&buttonText, &buttonURL, &enabled, &showDataLabel, &customMetricLabel, &pushMode)
+	// 获取任务详情及其查询
+	def, err := LoadTaskDefinition(db, taskID)
 	if err != nil {
 		log.Printf("[TaskQueue] 获取任务详情失败: %v", err)
 		return err
 	}
 
-	log.Printf("[TaskQueue] 任务信息: name=%s, timeRange=%s, step=%v", name, timeRange, step)
+	log.Printf("[TaskQueue] 任务信息: name=%s, timeRange=%s, step=%v, timezone=%s", def.Name, def.TimeRange, def.Step, def.Timezone)
 
 	// 检查任务是否启用
-	if enabled != 1 {
+	if !def.Enabled {
 		log.Printf("[TaskQueue] 任务未启用，跳过执行")
+		rec.note("任务未启用")
 		return nil
 	}
 
-	// 获取数据源URL
-	var sourceURL string
-	err = db.QueryRow("SELECT url FROM metrics_source WHERE id = ?", sourceID).Scan(&sourceURL)
-	if err != nil {
-		log.Printf("[TaskQueue] 获取数据源失败: %v", err)
-		return err
-	}
-
-	// 使用map进行查询去重
-	seenQueries := make(map[string]bool)
-	var uniqueQueries []struct {
-		Query             string
-		ChartTemplateID   int64
-		PromQLName        string
-		Unit              string
-		MetricLabel       string
-		CustomMetricLabel string
-		InitialUnit       string
-		DisplayOrder      int
-		DisplayMode       string // chart, text, both
-	}
-
-	// 查询任务的所有查询及其独立配置（按 display_order 排序）
-	rows, err := db.Query(`
-		SELECT ptp.promql_id, p.query, ptp.chart_template_id, p.name,
-		       COALESCE(ptp.unit, '') as unit,
-		       COALESCE(ptp.metric_label, 'pod') as metric_label,
-		       COALESCE(ptp.custom_metric_label, '') as custom_metric_label,
-		       COALESCE(ptp.initial_unit, '') as initial_unit,
-		       COALESCE(ptp.display_order, 0) as display_order,
-		       COALESCE(ptp.display_mode, 'chart') as display_mode
-		FROM push_task_promql ptp
-		JOIN promql p ON ptp.promql_id = p.id
-		WHERE ptp.task_id = ?
-		ORDER BY ptp.display_order ASC, ptp.id ASC
-	`, taskID)
-	if err != nil {
-		log.Printf("[TaskQueue] 查询PromQL失败: %v", err)
+	// 获取数据源（地址和认证配置）
+	if err := def.resolveSources(rec); err != nil {
 		return err
 	}
-	defer rows.Close()
-
-	// 处理查询结果
-	for rows.Next() {
-		var promqlID int64
-		var q struct {
-			Query             string
-			ChartTemplateID   int64
-			PromQLName        string
-			Unit              string
-			MetricLabel       string
-			CustomMetricLabel string
-			InitialUnit       string
-			DisplayOrder      int
-			DisplayMode       string
-		}
-		if err := rows.Scan(&promqlID, &q.Query, &q.ChartTemplateID, &q.PromQLName,
-			&q.Unit, &q.MetricLabel, &q.CustomMetricLabel, &q.InitialUnit, &q.DisplayOrder, &q.DisplayMode); err != nil {
-			log.Printf("[TaskQueue] 扫描PromQL行失败: %v", err)
-			continue
-		}
-
-		// 使用查询内容作为去重键
-		if !seenQueries[q.Query] {
-			seenQueries[q.Query] = true
-			uniqueQueries = append(uniqueQueries, q)
-			log.Printf("[TaskQueue] 添加唯一查询: %s (unit=%s, label=%s, mode=%s, order=%d)", q.Query, q.Unit, q.MetricLabel, q.DisplayMode, q.DisplayOrder)
-		} else {
-			log.Printf("[TaskQueue] 跳过重复查询: %s", q.Query)
-		}
-	}
-
-	// 如果没有找到查询，尝试使用旧的单查询格式
-	if len(uniqueQueries) == 0 {
-		log.Printf("[TaskQueue] 未找到PromQL查询，尝试使用旧格式查询")
-
-		// 尝试从push_task_query表中获取查询
-		queryRows, err := db.Query(`
-			SELECT query, chart_template_id
-			FROM push_task_query
-			WHERE task_id = ?
-		`, taskID)
-		if err == nil {
-			defer queryRows.Close()
-			for queryRows.Next() {
-				var q struct {
-					Query             string
-					ChartTemplateID   int64
-					PromQLName        string
-					Unit              string
-					MetricLabel       string
-					CustomMetricLabel string
-					InitialUnit       string
-					DisplayOrder      int
-					DisplayMode       string
-				}
-				if err := queryRows.Scan(&q.Query, &q.ChartTemplateID); err != nil {
-					log.Printf("[TaskQueue] 扫描查询行失败: %v", err)
-					continue
-				}
-
-				// 使用任务级别的配置作为默认值
-				q.Unit = unit
-				q.MetricLabel = metricLabel
-				q.CustomMetricLabel = customMetricLabel
-				q.InitialUnit = ""      // 旧格式没有 initial_unit
-				q.DisplayOrder = 0      // 旧格式没有 display_order
-				q.DisplayMode = "chart" // 旧格式默认为图表模式
-
-				// 使用查询内容作为去重键
-				if !seenQueries[q.Query] {
-					seenQueries[q.Query] = true
-					uniqueQueries = append(uniqueQueries, q)
-					log.Printf("[TaskQueue] 添加唯一旧格式查询: %s", q.Query)
-				}
-			}
-		}
-
-		// 如果仍然没有查询，尝试从push_task表中获取
-		if len(uniqueQueries) == 0 {
-			var query string
-			var chartTemplateID int64
-			err := db.QueryRow(`
-				SELECT query, chart_template_id
-				FROM push_task
-				WHERE id = ?
-			`, taskID).Scan(&query, &chartTemplateID)
-
-		if err == nil && query != "" && !seenQueries[query] {
-			uniqueQueries = append(uniqueQueries, struct {
-				Query             string
-				ChartTemplateID   int64
-				PromQLName        string
-				Unit              string
-				MetricLabel       string
-				CustomMetricLabel string
-				InitialUnit       string
-				DisplayOrder      int
-				DisplayMode       string
-			}{
-				Query:             query,
-				ChartTemplateID:   chartTemplateID,
-				PromQLName:        "",
-				Unit:              unit,
-				MetricLabel:       metricLabel,
-				CustomMetricLabel: customMetricLabel,
-				InitialUnit:       "",      // 旧格式没有 initial_unit
-				DisplayOrder:      0,       // 旧格式没有 display_order
-				DisplayMode:       "chart", // 旧格式默认为图表模式
-				})
-				log.Printf("[TaskQueue] 添加任务表中的查询: %s", query)
-			}
-		}
-	}
 
-	log.Printf("[TaskQueue] 共找到 %d 个唯一查询", len(uniqueQueries))
+	log.Printf("[TaskQueue] 共找到 %d 个唯一查询", len(def.Queries))
 
 	// 如果仍然没有查询，记录错误并返回
-	if len(uniqueQueries) == 0 {
+	if len(def.Queries) == 0 {
 		log.Printf("[TaskQueue] 未找到任何有效查询，任务终止")
+		rec.note("未找到任何有效查询")
 		return nil
 	}
 
-	// 解析time_range为持续时间
-	duration := parseDurationString(timeRange)
-	end := time.Now()
-	start := end.Add(-duration)
-	
-	// 如果 step 为 0 或太小（< 1 秒），根据 duration 自动计算最优 step
-	if step < 1 {
-		calculatedStep := service.GetDurationStep(duration)
-		step = calculatedStep.Seconds()
-		log.Printf("[TaskQueue] Step 为 0 或太小，根据时间范围 %s 自动计算: %v (%.0f 秒)", 
-			timeRange, calculatedStep, step)
-	}
-	
-	log.Printf("[TaskQueue] 查询时间范围: start=%s, end=%s, step=%ds",
-		start.Format("2006-01-02 15:04:05"),
-		end.Format("2006-01-02 15:04:05"),
-		int64(step))
-
 	// 获取所有绑定的webhook
-	webhookRows, err := db.Query(`
-		SELECT w.id, w.url 
-		FROM feishu_webhook w
-		JOIN push_task_webhook ptw ON w.id = ptw.webhook_id
-		WHERE ptw.task_id = ?
-	`, taskID)
+	webhooks, err := service.GetTaskWebhooks(taskID)
 	if err != nil {
 		log.Printf("[TaskQueue] 获取webhook失败: %v", err)
 		return err
 	}
-	defer webhookRows.Close()
-
-	var webhooks []struct {
-		ID  int64
-		URL string
-	}
-
-	for webhookRows.Next() {
-		var wh struct {
-			ID  int64
-			URL string
-		}
-		if err := webhookRows.Scan(&wh.ID, &wh.URL); err != nil {
-			log.Printf("[TaskQueue] 扫描webhook行失败: %v", err)
-			continue
-		}
-		webhooks = append(webhooks, wh)
-	}
 
 	if len(webhooks) == 0 {
 		log.Printf("[TaskQueue] 未找到webhook配置，任务终止")
+		rec.note("任务未绑定 WebHook")
 		return nil
 	}
 
 	log.Printf("[TaskQueue] 找到 %d 个webhook配置", len(webhooks))
-	log.Printf("[TaskQueue] 使用 PromQL 级别的展示模式配置")
-
-	// 检查是否需要使用混合卡片
-	needHybridCard := false
-	for _, query := range uniqueQueries {
-		if query.DisplayMode == "both" {
-			needHybridCard = true
-			break
-		}
-	}
-
-	log.Printf("[TaskQueue] 是否使用混合卡片: %v", needHybridCard)
-
-	// 如果需要混合卡片，构建混合元素列表
-	if needHybridCard {
-		var hybridElements []service.HybridElement
-
-		for _, query := range uniqueQueries {
-			mode := query.DisplayMode
-			if mode == "" {
-				mode = "chart" // 默认为图表模式
-			}
-
-			promqlName := query.PromQLName
-			if promqlName == "" {
-				promqlName = "查询"
-			}
-
-			// 确定使用哪个标签
-			queryMetricLabel := query.MetricLabel
-			queryCustomLabel := query.CustomMetricLabel
-			if queryMetricLabel == "" {
-				queryMetricLabel = metricLabel
-			}
-
-			if mode == "text" || mode == "both" {
-				// 获取文本数据
-				log.Printf("[TaskQueue] 获取文本数据: %s", query.Query)
-				latestMetrics, err := service.FetchLatestMetrics(sourceURL, query.Query, queryMetricLabel, queryCustomLabel, query.InitialUnit, query.Unit)
-				if err != nil {
-					log.Printf("[TaskQueue] 获取最新指标值失败: %v", err)
-				} else {
-					hybridElements = append(hybridElements, service.HybridElement{
-						DisplayOrder: query.DisplayOrder,
-						DisplayMode:  "text",
-						PromQLName:   promqlName,
-						TextMetrics:  latestMetrics,
-						Unit:         query.Unit,
-						MetricLabel:  queryMetricLabel,
-					})
-					log.Printf("[TaskQueue] 添加文本元素: %s (order=%d)", promqlName, query.DisplayOrder)
-				}
-			}
-
-			if mode == "chart" || mode == "both" {
-				// 获取图表数据
-				log.Printf("[TaskQueue] 获取图表数据: %s", query.Query)
-				log.Printf("[TaskQueue] 查询时间范围: start=%s, end=%s, step=%ds", 
-					start.Format("2006-01-02 15:04:05"), 
-					end.Format("2006-01-02 15:04:05"), 
-					step)
-				
-				// 获取图表类型
-				var chartType string
-				err := db.QueryRow("SELECT chart_type FROM chart_template WHERE id = ?", query.ChartTemplateID).Scan(&chartType)
-				if err != nil {
-					log.Printf("[TaskQueue] 获取图表类型失败 (ID=%d): %v，使用默认类型 'area'", query.ChartTemplateID, err)
-					chartType = "area"
-				}
-				chartType = service.GetSupportedChartType(chartType)
-
-				// 获取数据点
-				dataPoints, err := service.FetchMetrics(sourceURL, query.Query, start, end, time.Duration(step)*time.Second, queryMetricLabel, queryCustomLabel, query.InitialUnit, query.Unit)
-				if err != nil {
-					log.Printf("[TaskQueue] 获取指标数据失败: %v", err)
-				} else {
-					hybridElements = append(hybridElements, service.HybridElement{
-						DisplayOrder: query.DisplayOrder,
-						DisplayMode:  "chart",
-						PromQLName:   promqlName,
-						ChartData: &models.QueryDataPoints{
-							DataPoints: dataPoints,
-							ChartType:  chartType,
-							ChartTitle: promqlName,
-							Unit:       query.Unit,
-						},
-						ChartType:     chartType,
-						ShowDataLabel: showDataLabel.Int64 == 1,
-					})
-					log.Printf("[TaskQueue] 添加图表元素: %s (order=%d, points=%d)", promqlName, query.DisplayOrder, len(dataPoints))
-				}
-			}
-		}
-
-		if len(hybridElements) == 0 {
-			log.Printf("[TaskQueue] 未获取到任何混合元素，任务终止")
-			return nil
-		}
-
-		log.Printf("[TaskQueue] 共收集到 %d 个混合元素", len(hybridElements))
-
-		// 发送混合卡片到所有 webhook
-		sentWebhooks := make(map[string]bool)
-		sentCount := 0
-		skippedCount := 0
 
-		for _, webhook := range webhooks {
-			if sentWebhooks[webhook.URL] {
-				log.Printf("[TaskQueue] 跳过重复的webhook URL: %s", webhook.URL)
-				skippedCount++
-				continue
-			}
-
-			log.Printf("[TaskQueue] 发送混合卡片到webhook (ID=%d)", webhook.ID)
-
-			webhookMutex := getWebhookMutex(webhook.ID)
-			webhookMutex.Lock()
-
-			err = service.SendFeishuHybridCard(webhook.URL, hybridElements, cardTitle, cardTemplate,
-				unit, buttonText, buttonURL, showDataLabel.Int64 == 1)
-
-			if err != nil {
-				log.Printf("[TaskQueue] 发送失败: %v", err)
-				insertPushStatus(db, sourceID, webhook.ID, err)
-			} else {
-				sentCount++
-				log.Printf("[TaskQueue] 混合卡片发送成功")
-				insertPushStatus(db, sourceID, webhook.ID, nil)
-				sentWebhooks[webhook.URL] = true
-			}
-
-			webhookMutex.Unlock()
-		}
-
-		log.Printf("[TaskQueue] 混合卡片推送完成: 配置的webhook数: %d, 实际发送: %d, 因重复跳过: %d",
-			len(webhooks), sentCount, skippedCount)
-		log.Printf("[TaskQueue] ===== 任务 ID=%d 执行完成 (混合模式) =====\n", taskID)
-		return nil
+	reports := buildReports(db, def, rec)
+	for _, report := range reports {
+		deliverReport(ctx, db, rec, taskID, def.SourceID, webhooks, report)
 	}
 
-	// 按 PromQL 的 display_mode 分组数据（非混合模式）
-	var chartQueries []struct {
-		Query             string
-		ChartTemplateID   int64
-		PromQLName        string
-		Unit              string
-		MetricLabel       string
-		CustomMetricLabel string
-		InitialUnit       string
-		DisplayOrder      int
-		DisplayMode       string
-	}
-	var textQueries []struct {
-		Query             string
-		ChartTemplateID   int64
-		PromQLName        string
-		Unit              string
-		MetricLabel       string
-		CustomMetricLabel string
-		InitialUnit       string
-		DisplayOrder      int
-		DisplayMode       string
-	}
-
-	for _, query := range uniqueQueries {
-		mode := query.DisplayMode
-		if mode == "" {
-			mode = "chart" // 默认为图表模式
-		}
-
-		if mode == "text" {
-			// 仅文本模式
-			textQueries = append(textQueries, query)
-		} else if mode == "chart" {
-			// 仅图表模式
-			chartQueries = append(chartQueries, query)
-		}
-	}
-
-	log.Printf("[TaskQueue] 图表模式查询数: %d, 文本模式查询数: %d", len(chartQueries), len(textQueries))
-
-	// 文本模式推送逻辑
-	hasTextMode := len(textQueries) > 0
-	if hasTextMode {
-		// 文本模式：获取每个 PromQL 的最新值
-		log.Printf("[TaskQueue] 执行文本模式推送，获取最新指标值")
-
-	// 为每个 PromQL 获取最新值
-	promqlMetrics := make(map[string][]service.LatestMetric)
-	promqlConfigs := make(map[string]struct {
-		Name              string
-		Unit              string
-		MetricLabel       string
-		CustomMetricLabel string
-		InitialUnit       string
-	})
-	var promqlOrder []string // 保持 PromQL 的显示顺序
-
-	for i, query := range textQueries {
-		log.Printf("[TaskQueue] 获取查询 %d 的最新指标值: %s", i+1, query.Query)
-
-		// 确定使用哪个标签
-		queryMetricLabel := query.MetricLabel
-		queryCustomLabel := query.CustomMetricLabel
-		if queryMetricLabel == "" {
-			queryMetricLabel = metricLabel
-		}
-
-		// 获取最新指标值（应用单位转换）
-		latestMetrics, err := service.FetchLatestMetrics(sourceURL, query.Query, queryMetricLabel, queryCustomLabel, query.InitialUnit, query.Unit)
-		if err != nil {
-			log.Printf("[TaskQueue] 获取最新指标值失败: %v", err)
-			continue
-		}
-
-		promqlName := query.PromQLName
-		if promqlName == "" {
-			promqlName = fmt.Sprintf("查询 %d", i+1)
-		}
-
-		promqlMetrics[promqlName] = latestMetrics
-		promqlConfigs[promqlName] = struct {
-			Name              string
-			Unit              string
-			MetricLabel       string
-			CustomMetricLabel string
-			InitialUnit       string
-		}{
-			Name:              promqlName,
-			Unit:              query.Unit,
-			MetricLabel:       queryMetricLabel,
-			CustomMetricLabel: queryCustomLabel,
-			InitialUnit:       query.InitialUnit,
-		}
-		promqlOrder = append(promqlOrder, promqlName) // 记录顺序
-
-		log.Printf("[TaskQueue] PromQL '%s' 获取到 %d 个最新指标", promqlName, len(latestMetrics))
-	}
-
-		if len(promqlMetrics) == 0 {
-			log.Printf("[TaskQueue] 未获取到任何最新指标，任务终止")
-			return nil
-		}
-
-		// 发送文本卡片到所有 webhook
-		sentWebhooks := make(map[string]bool)
-		sentCount := 0
-		skippedCount := 0
-
-		for _, webhook := range webhooks {
-			if sentWebhooks[webhook.URL] {
-				log.Printf("[TaskQueue] 跳过重复的webhook URL: %s", webhook.URL)
-				skippedCount++
-				continue
-			}
-
-		log.Printf("[TaskQueue] 发送文本卡片到webhook (ID=%d)", webhook.ID)
-
-		webhookMutex := getWebhookMutex(webhook.ID)
-		webhookMutex.Lock()
-
-		err = service.SendFeishuTextCard(webhook.URL, promqlMetrics, promqlConfigs, promqlOrder,
-			cardTitle, cardTemplate, buttonText, buttonURL)
-
-			if err != nil {
-				log.Printf("[TaskQueue] 发送失败: %v", err)
-				insertPushStatus(db, sourceID, webhook.ID, err)
-			} else {
-				sentCount++
-				log.Printf("[TaskQueue] 文本卡片发送成功")
-				insertPushStatus(db, sourceID, webhook.ID, nil)
-				sentWebhooks[webhook.URL] = true
-			}
-
-			webhookMutex.Unlock()
-		}
-
-		log.Printf("[TaskQueue] 文本模式推送完成: 配置的webhook数: %d, 实际发送: %d, 因重复跳过: %d",
-			len(webhooks), sentCount, skippedCount)
-	}
-
-	// 图表模式推送逻辑
-	hasChartMode := len(chartQueries) > 0
-	if hasChartMode {
-		// 图表模式：获取时间序列数据
-		log.Printf("[TaskQueue] 执行图表模式推送，获取时间序列数据")
-
-		var allDataPoints []models.QueryDataPoints
-		seenSeries := make(map[string]bool) // 用于系列去重
-
-		for i, query := range chartQueries {
-			// 获取图表类型
-			var chartType string
-			err = db.QueryRow("SELECT chart_type FROM chart_template WHERE id = ?", query.ChartTemplateID).Scan(&chartType)
-			if err != nil {
-				log.Printf("[TaskQueue] 获取图表类型失败 (ID=%d): %v，使用默认类型 'area'", query.ChartTemplateID, err)
-				chartType = "area"
-			}
-
-			chartType = service.GetSupportedChartType(chartType)
-			log.Printf("[TaskQueue] 查询 %d: 使用图表类型 %s", i+1, chartType)
-
-		// 确定使用哪个标签
-		queryMetricLabel := query.MetricLabel
-		queryCustomLabel := query.CustomMetricLabel
-		if queryMetricLabel == "" {
-			queryMetricLabel = metricLabel
-		}
-
-		// 获取数据点（应用单位转换）
-		log.Printf("[TaskQueue] 开始获取查询 %d 的指标数据: %s (label=%s)", i+1, query.Query, queryMetricLabel)
-		dataPoints, err := service.FetchMetrics(sourceURL, query.Query, start, end, time.Duration(step)*time.Second, queryMetricLabel, queryCustomLabel, query.InitialUnit, query.Unit)
-		if err != nil {
-			log.Printf("[TaskQueue] 获取指标数据失败: %v", err)
-			continue
-		}
-
-			// 生成图表标题
-			chartTitle := query.PromQLName
-			if chartTitle == "" {
-				chartTitle = fmt.Sprintf("查询 %d", i+1)
-			}
-
-		// 检查系列是否重复
-		if !seenSeries[chartTitle] {
-			seenSeries[chartTitle] = true
-			allDataPoints = append(allDataPoints, models.QueryDataPoints{
-				DataPoints: dataPoints,
-				ChartType:  chartType,
-				ChartTitle: chartTitle,
-				Unit:       query.Unit, // 使用每个查询的独立单位
-			})
-			log.Printf("[TaskQueue] 添加新的数据系列: %s (包含 %d 个数据点, 单位: %s)", chartTitle, len(dataPoints), query.Unit)
-		} else {
-			log.Printf("[TaskQueue] 跳过重复的数据系列: %s", chartTitle)
-		}
-		}
-
-		if len(allDataPoints) == 0 {
-			log.Printf("[TaskQueue] 未获取到任何数据点，任务终止")
-			return nil
-		}
-
-		log.Printf("[TaskQueue] 共收集到 %d 个唯一数据系列", len(allDataPoints))
-
-		// 发送到所有绑定的webhook
-		sentWebhooks := make(map[string]bool)
-		sentCount := 0
-		skippedCount := 0
-
-		for _, webhook := range webhooks {
-			if sentWebhooks[webhook.URL] {
-				log.Printf("[TaskQueue] 跳过重复的webhook URL: %s", webhook.URL)
-				skippedCount++
-				continue
-			}
-
-			log.Printf("[TaskQueue] 准备发送到webhook (ID=%d)", webhook.ID)
-
-		webhookMutex := getWebhookMutex(webhook.ID)
-		webhookMutex.Lock()
-
-		// 图表模式：每个查询系列使用自己的单位（已在 QueryDataPoints 中保存）
-		// 为了向后兼容，如果没有设置单位，使用任务级别的单位
-		err = service.SendFeishuStandardChart(webhook.URL, allDataPoints, cardTitle, cardTemplate,
-			unit, buttonText, buttonURL, showDataLabel.Int64 == 1)
-
-			if err != nil {
-				log.Printf("[TaskQueue] 发送失败: %v", err)
-				insertPushStatus(db, sourceID, webhook.ID, err)
-
-				if strings.Contains(err.Error(), "frequency limited") || strings.Contains(err.Error(), "too many request") {
-					waitTime := 3 * time.Second
-					log.Printf("[TaskQueue] 检测到频率限制，等待 %v", waitTime)
-					time.Sleep(waitTime)
-				}
-			} else {
-				sentCount++
-				log.Printf("[TaskQueue] 发送成功: %d 个数据系列", len(allDataPoints))
-				insertPushStatus(db, sourceID, webhook.ID, nil)
-				sentWebhooks[webhook.URL] = true
-			}
-
-			webhookMutex.Unlock()
-		}
-
-		log.Printf("[TaskQueue] 图表模式推送完成: 配置的webhook数: %d, 实际发送: %d, 因重复跳过: %d",
-			len(webhooks), sentCount, skippedCount)
-	}
-
-	log.Printf("[TaskQueue] ===== 任务 ID=%d 执行完成 (图表模式: %v, 文本模式: %v) =====\n", taskID, hasChartMode, hasTextMode)
+	log.Printf("[TaskQueue] ===== 任务 ID=%d 执行完成 (发送报告数: %d) =====\n", taskID, len(reports))
 	return nil
 }
 
 // runSingleTaskPush 执行单个任务的推送（带锁版本，内部使用）
-func runSingleTaskPush(db *sql.DB, taskID int64) error {
+// trigger 和 scheduledAt 记录到执行历史中
+func runSingleTaskPush(ctx context.Context, db *sql.DB, taskID int64, trigger string, scheduledAt time.Time) error {
 	// 获取任务互斥锁，确保同一任务不会并行执行
 	taskMutex := getTaskMutex(taskID)
 
@@ -1042,29 +522,15 @@ func runSingleTaskPush(db *sql.DB, taskID int64) error {
 	}
 	defer taskMutex.Unlock()
 
-	return runSingleTaskPushWithoutLock(db, taskID)
-}
-
-// RunSingleTaskPush 执行单个任务的推送（公共导出版本）
-// 提供给server包和其他外部包调用，确保任务执行有互斥控制
-func RunSingleTaskPush(db *sql.DB, taskID int64) error {
-	// 检查任务是否可以执行
-	if !taskQueue.canExecuteTask(taskID) {
-		return fmt.Errorf("任务 %d 不满足执行条件", taskID)
-	}
-
-	// 更新任务状态为运行中
-	taskQueue.updateTaskStatus(taskID, true, nil)
-	defer func() {
-		taskQueue.updateTaskStatus(taskID, false, nil)
-	}()
-
-	return runSingleTaskPush(db, taskID)
+	rec := startTaskRun(db, taskID, trigger, scheduledAt)
+	err := runSingleTaskPushWithoutLock(ctx, db, taskID, rec)
+	rec.finish(err)
+	return err
 }
 
 // ForceRunSingleTaskPush 立即执行任务（跳过间隔检查）
-// 用于手动触发的任务执行，不受最小执行间隔限制
-func ForceRunSingleTaskPush(db *sql.DB, taskID int64) error {
+// 用于手动触发的任务执行，不受最小执行间隔限制，trigger 为 manual 或 api；ctx 取消时中止发送
+func ForceRunSingleTaskPush(ctx context.Context, db *sql.DB, taskID int64, trigger string) error {
 	log.Printf("[ForceRunSingleTaskPush] 手动执行任务 ID=%d，跳过间隔检查", taskID)
 	
 	// 获取任务互斥锁，确保同一任务不会并行执行
@@ -1085,5 +551,8 @@ func ForceRunSingleTaskPush(db *sql.DB, taskID int64) error {
 	}()
 
 	// 直接调用不带锁的版本，避免双重加锁
-	return runSingleTaskPushWithoutLock(db, taskID)
+	rec := startTaskRun(db, taskID, trigger, time.Time{})
+	err := runSingleTaskPushWithoutLock(ctx, db, taskID, rec)
+	rec.finish(err)
+	return err
 }
@@ -0,0 +1,233 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fsvchart-notify/internal/service"
+)
+
+// Scheduler 推送任务调度器，负责每分钟检查任务调度并通过任务队列执行
+// 由 main 创建并管理生命周期，整个进程只应存在一个实例
+type Scheduler struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	ctx       context.Context // Start 创建的运行 ctx，Stop 时取消，手动执行的任务也使用它
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	startedAt time.Time
+}
+
+// TaskStatusView 任务运行状态的展示结构
+type TaskStatusView struct {
+	LastRunAt    string `json:"last_run_at"`
+	IsRunning    bool   `json:"is_running"`
+	RunCount     int    `json:"run_count"`
+	LastRunError string `json:"last_run_error"`
+	LastSuccess  string `json:"last_success"`
+}
+
+// TaskInfo 调度器中已注册任务的信息
+type TaskInfo struct {
+	ID            int64           `json:"id"`
+	Name          string          `json:"name"`
+	CronExpr      string          `json:"cron_expr"`
+	Timezone      string          `json:"timezone"`
+	CatchUpPolicy string          `json:"catchup_policy"`
+	NextRunAt     string          `json:"next_run_at"`
+	ScheduleError string          `json:"schedule_error,omitempty"`
+	Status        *TaskStatusView `json:"status"`
+}
+
+// Status 调度器整体状态
+type Status struct {
+	Running     bool       `json:"running"`
+	StartedAt   string     `json:"started_at"`
+	LastTick    string     `json:"last_tick"`
+	QueueLength int        `json:"queue_length"`
+	Tasks       []TaskInfo `json:"tasks"`
+}
+
+// New 创建调度器
+func New(db *sql.DB) *Scheduler {
+	return &Scheduler{db: db}
+}
+
+// Start 启动任务队列和每分钟的调度检查，ctx 取消或调用 Stop 后停止
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		log.Println("[scheduler] Scheduler already started")
+		return
+	}
+	log.Println("[scheduler] Starting scheduler...")
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.ctx = ctx
+	s.cancel = cancel
+	s.startedAt = time.Now()
+
+	// 上次进程退出时仍在执行的记录不会再完成，标记为失败
+	if _, err := s.db.Exec(`
+		UPDATE task_run SET status = ?, message = '进程退出时任务未执行完成'
+		WHERE status = ?
+	`, RunStatusFailed, RunStatusRunning); err != nil {
+		log.Printf("[scheduler] 清理未完成的执行记录失败: %v", err)
+	}
+
+	// 启动任务执行器
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		taskQueue.run(ctx, s.db)
+	}()
+
+	// 每1分钟检查一次任务调度
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		// 启动时立即检查一次，补发停机期间错过的时间点
+		processPushTasks(s.db)
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("[scheduler] Ticker stopped")
+				return
+			case <-ticker.C:
+				log.Println("[scheduler] Ticker triggered, processing tasks...")
+				processPushTasks(s.db)
+			}
+		}
+	}()
+
+	log.Println("[scheduler] Scheduler started successfully")
+}
+
+// Stop 停止调度器，等待正在执行的任务完成后返回
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.ctx = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	log.Println("[scheduler] Stopping scheduler...")
+	cancel()
+	s.wg.Wait()
+	log.Println("[scheduler] Scheduler stopped")
+}
+
+// RunNow 立即执行任务，不受最小执行间隔限制，trigger 记录到执行历史中
+// 调度器停止时正在进行的发送会被取消
+func (s *Scheduler) RunNow(taskID int64, trigger string) error {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ForceRunSingleTaskPush(ctx, s.db, taskID, trigger)
+}
+
+// Status 返回调度器状态，包括所有已启用任务的下一次执行时间和运行状态
+func (s *Scheduler) Status() (*Status, error) {
+	s.mu.Lock()
+	status := &Status{
+		Running:     s.cancel != nil,
+		QueueLength: len(taskQueue.tasks),
+		Tasks:       []TaskInfo{},
+	}
+	if !s.startedAt.IsZero() {
+		status.StartedAt = s.startedAt.Format("2006-01-02 15:04:05")
+	}
+	s.mu.Unlock()
+
+	if lastTick := loadLastTick(s.db); !lastTick.IsZero() {
+		status.LastTick = lastTick.Local().Format("2006-01-02 15:04:05")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(cron_expr, '') as cron_expr,
+		       COALESCE(timezone, '') as timezone,
+		       COALESCE(catchup_policy, 'skip') as catchup_policy
+		FROM push_task
+		WHERE enabled = 1
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+	var tasks []TaskInfo
+	for rows.Next() {
+		var task TaskInfo
+		if err := rows.Scan(&task.ID, &task.Name, &task.CronExpr, &task.Timezone, &task.CatchUpPolicy); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("读取任务失败: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, task := range tasks {
+		if next, err := s.nextRunAt(task, now); err != nil {
+			task.ScheduleError = err.Error()
+		} else if !next.IsZero() {
+			task.NextRunAt = next.Format("2006-01-02 15:04:05")
+		}
+		task.Status = taskStatusView(task.ID)
+		status.Tasks = append(status.Tasks, task)
+	}
+	return status, nil
+}
+
+// nextRunAt 按任务时区计算下一次执行时间
+func (s *Scheduler) nextRunAt(task TaskInfo, now time.Time) (time.Time, error) {
+	loc, err := service.LoadTimezone(task.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	sendTimes, err := LoadTaskSendTimes(s.db, task.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return NextRunAt(task.CronExpr, sendTimes, now.In(loc))
+}
+
+// taskStatusView 读取任务队列中记录的运行状态，任务尚未执行过时返回 nil
+func taskStatusView(taskID int64) *TaskStatusView {
+	value, exists := taskQueue.status.Load(taskID)
+	if !exists {
+		return nil
+	}
+	ts := value.(*TaskStatus)
+	view := &TaskStatusView{
+		IsRunning: ts.IsRunning,
+		RunCount:  ts.RunCount,
+	}
+	if !ts.LastRunAt.IsZero() {
+		view.LastRunAt = ts.LastRunAt.Format("2006-01-02 15:04:05")
+	}
+	if !ts.LastSuccess.IsZero() {
+		view.LastSuccess = ts.LastSuccess.Format("2006-01-02 15:04:05")
+	}
+	if ts.LastRunError != nil {
+		view.LastRunError = ts.LastRunError.Error()
+	}
+	return view
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,7 +15,9 @@ import (
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
 
+	"fsvchart-notify/internal/channel"
 	"fsvchart-notify/internal/database"
+	"fsvchart-notify/internal/datasource"
 	"fsvchart-notify/internal/handler"
 	"fsvchart-notify/internal/middleware"
 	"fsvchart-notify/internal/models"
@@ -27,31 +30,158 @@ import (
 type MetricsSourceReq struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
+
+	// 数据源类型和查询选项，未传入时保持不变（新建时默认为 prometheus）
+	Type    *string                   `json:"type"`
+	Options *models.DatasourceOptions `json:"options"`
+
+	// 认证配置，未传入的字段保持不变；密钥字段和请求头的值传入掩码 "******" 时同样保持不变
+	AuthType           *string           `json:"auth_type"`
+	Username           *string           `json:"username"`
+	Password           *string           `json:"password"`
+	BearerToken        *string           `json:"bearer_token"`
+	Headers            map[string]string `json:"headers"`
+	CACert             *string           `json:"ca_cert"`
+	ClientCert         *string           `json:"client_cert"`
+	ClientKey          *string           `json:"client_key"`
+	InsecureSkipVerify *bool             `json:"insecure_skip_verify"`
+}
+
+// secretMask 返回给前端的密钥掩码
+const secretMask = "******"
+
+// maskHeaders 返回请求头的副本，值统一替换为掩码（请求头常用于携带 Authorization、API Key 等凭证）
+func maskHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if v != "" {
+			v = secretMask
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+// mergeHeaders 合并更新请求中的请求头，值为掩码的请求头保持原值
+func mergeHeaders(headers, old map[string]string) map[string]string {
+	for k, v := range headers {
+		if v == secretMask {
+			headers[k] = old[k]
+		}
+	}
+	return headers
+}
+
+// maskMetricsSource 隐藏数据源中的密码、Token、私钥和请求头的值，以及 SQL 连接串中的密码
+func maskMetricsSource(ms models.MetricsSource) models.MetricsSource {
+	for _, secret := range []*string{&ms.Password, &ms.BearerToken, &ms.ClientKey} {
+		if *secret != "" {
+			*secret = secretMask
+		}
+	}
+	ms.URL = maskedSourceURL(ms)
+	ms.Headers = maskHeaders(ms.Headers)
+	return ms
+}
+
+// maskedSourceURL 返回隐藏了密码的 SQL 连接串，其他类型的数据源返回原地址
+func maskedSourceURL(ms models.MetricsSource) string {
+	if ms.Type != datasource.TypeSQL {
+		return ms.URL
+	}
+	return datasource.RedactDSN(ms.Options.Driver, ms.URL, secretMask)
+}
+
+// applyMetricsSourceReq 将请求中的字段合并到数据源并校验认证配置
+func applyMetricsSourceReq(ms *models.MetricsSource, req MetricsSourceReq) error {
+	ms.Name = req.Name
+	// SQL 连接串原样传回隐藏密码后的值时保持不变
+	if ms.URL == "" || req.URL != maskedSourceURL(*ms) {
+		ms.URL = req.URL
+	}
+	if req.Type != nil {
+		ms.Type = *req.Type
+	}
+	if req.Options != nil {
+		ms.Options = *req.Options
+	}
+	typ, err := datasource.NormalizeType(ms.Type)
+	if err != nil {
+		return err
+	}
+	ms.Type = typ
+
+	setString := func(dst *string, src *string, secret bool) {
+		if src == nil || (secret && *src == secretMask) {
+			return
+		}
+		*dst = strings.TrimSpace(*src)
+	}
+	setString(&ms.Username, req.Username, false)
+	setString(&ms.Password, req.Password, true)
+	setString(&ms.BearerToken, req.BearerToken, true)
+	setString(&ms.CACert, req.CACert, false)
+	setString(&ms.ClientCert, req.ClientCert, false)
+	setString(&ms.ClientKey, req.ClientKey, true)
+	if req.Headers != nil {
+		ms.Headers = mergeHeaders(req.Headers, ms.Headers)
+	}
+	if req.InsecureSkipVerify != nil {
+		ms.InsecureSkipVerify = *req.InsecureSkipVerify
+	}
+	if req.AuthType != nil {
+		ms.AuthType = *req.AuthType
+	}
+
+	authType, err := datasource.NormalizeAuthType(ms.AuthType)
+	if err != nil {
+		return err
+	}
+	ms.AuthType = authType
+	switch authType {
+	case datasource.AuthBasic:
+		if ms.Username == "" {
+			return fmt.Errorf("basic 认证需要填写用户名")
+		}
+	case datasource.AuthBearer:
+		if ms.BearerToken == "" {
+			return fmt.Errorf("bearer 认证需要填写 Token")
+		}
+	}
+	return datasource.Validate(*ms)
+}
+
+// metricsSourceArgs 数据源认证配置对应的列值，顺序与 INSERT/UPDATE 语句一致
+func metricsSourceArgs(ms models.MetricsSource) []interface{} {
+	headers := "{}"
+	if len(ms.Headers) > 0 {
+		data, _ := json.Marshal(ms.Headers)
+		headers = string(data)
+	}
+	options, _ := json.Marshal(ms.Options)
+	insecure := 0
+	if ms.InsecureSkipVerify {
+		insecure = 1
+	}
+	return []interface{}{ms.Name, ms.URL, ms.Type, string(options), ms.AuthType, ms.Username, ms.Password, ms.BearerToken,
+		headers, ms.CACert, ms.ClientCert, ms.ClientKey, insecure}
 }
 
 // GET /api/metrics_source
 func getMetricsSources(c *gin.Context) {
-	db, err := database.SetupDB("./data/app.db")
+	sources, err := service.GetAllMetricsSources()
 	if err != nil {
 		// 统一返回 JSON
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rows, err := db.Query("SELECT id, name, url FROM metrics_source")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer rows.Close()
 
 	var list []models.MetricsSource
-	for rows.Next() {
-		var ms models.MetricsSource
-		if err := rows.Scan(&ms.ID, &ms.Name, &ms.URL); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		list = append(list, ms)
+	for _, ms := range sources {
+		list = append(list, maskMetricsSource(ms))
 	}
 	c.JSON(http.StatusOK, list)
 }
@@ -64,28 +194,37 @@ func createMetricsSource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	db, err := database.SetupDB("./data/app.db")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var ms models.MetricsSource
+	if err := applyMetricsSourceReq(&ms, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	stmt, err := db.Prepare("INSERT INTO metrics_source(name, url) VALUES (?, ?)")
+	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	res, err := stmt.Exec(req.Name, req.URL)
+	res, err := db.Exec(`
+		INSERT INTO metrics_source(name, url, type, options, auth_type, username, password, bearer_token,
+			headers, ca_cert, client_cert, client_key, insecure_skip_verify)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, metricsSourceArgs(ms)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	newID, _ := res.LastInsertId()
-	c.JSON(http.StatusOK, gin.H{"id": newID, "name": req.Name, "url": req.URL})
+	ms.ID, _ = res.LastInsertId()
+	c.JSON(http.StatusOK, maskMetricsSource(ms))
 }
 
 // PUT /api/metrics_source/:id
 func updateMetricsSource(c *gin.Context) {
 	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的数据源ID"})
+		return
+	}
 
 	var req MetricsSourceReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -97,24 +236,87 @@ func updateMetricsSource(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	stmt, err := db.Prepare("UPDATE metrics_source SET name=?, url=? WHERE id=?")
+
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM metrics_source WHERE id = ?", id).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
+		return
+	}
+	ms, err := service.GetMetricsSource(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer stmt.Close()
+	if err := applyMetricsSourceReq(&ms, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	res, err := stmt.Exec(req.Name, req.URL, idStr)
+	_, err = db.Exec(`
+		UPDATE metrics_source SET name=?, url=?, type=?, options=?, auth_type=?, username=?, password=?, bearer_token=?,
+			headers=?, ca_cert=?, client_cert=?, client_key=?, insecure_skip_verify=?
+		WHERE id=?
+	`, append(metricsSourceArgs(ms), id)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	rowsAff, _ := res.RowsAffected()
-	if rowsAff == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
+	c.JSON(http.StatusOK, gin.H{"message": "updated", "id": idStr})
+}
+
+// GET /api/metrics_source/:id/health
+func checkMetricsSourceHealth(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的数据源ID"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "updated", "id": idStr})
+	ms, err := service.GetMetricsSource(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ds, err := datasource.New(ms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start := time.Now()
+	if err := ds.Health(c.Request.Context()); err != nil {
+		c.JSON(http.StatusOK, gin.H{"healthy": false, "error": err.Error(), "duration_ms": time.Since(start).Milliseconds()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"healthy": true, "duration_ms": time.Since(start).Milliseconds()})
+}
+
+// GET /api/metrics_source/:id/label/:name/values
+func getMetricsSourceLabelValues(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的数据源ID"})
+		return
+	}
+	ms, err := service.GetMetricsSource(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ds, err := datasource.New(ms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	values, err := ds.LabelValues(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, values)
 }
 
 // DELETE /api/metrics_source/:id
@@ -125,6 +327,17 @@ func deleteMetricsSource(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// 检查是否有 PromQL 指定使用此数据源
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM promql WHERE source_id = ?", idStr).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无法删除：此数据源正在被 %d 个 PromQL 使用", count)})
+		return
+	}
+
 	stmt, e := db.Prepare("DELETE FROM metrics_source WHERE id=?")
 	if e != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": e.Error()})
@@ -146,36 +359,71 @@ func deleteMetricsSource(c *gin.Context) {
 // -------------- 2. feishu_webhook --------------
 
 type FeishuWebhookReq struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name    string                 `json:"name"`
+	URL     string                 `json:"url"`
+	Type    *string                `json:"type"`    // 通知渠道类型，未提供时保持不变（新建时为 feishu）
+	Secret  *string                `json:"secret"`  // 加签密钥或机器人 Token，未提供或为掩码时保持不变
+	Options *models.ChannelOptions `json:"options"` // 渠道选项，未提供时保持不变；app_secret 和 headers 中的值为掩码时保持不变
 }
 
-// GET /api/feishu_webhook
-func getFeishuWebhooks(c *gin.Context) {
-	db, err := database.SetupDB("./data/app.db")
+// applyFeishuWebhookReq 将请求中的字段合并到 WebHook 并校验渠道类型
+func applyFeishuWebhookReq(wb *models.FeishuWebhook, req FeishuWebhookReq) error {
+	wb.Name = req.Name
+	wb.URL = req.URL
+	if req.Type != nil {
+		wb.Type = *req.Type
+	}
+	if req.Secret != nil && *req.Secret != secretMask {
+		wb.Secret = strings.TrimSpace(*req.Secret)
+	}
+	if req.Options != nil {
+		old := wb.Options
+		wb.Options = *req.Options
+		if wb.Options.AppSecret == secretMask {
+			wb.Options.AppSecret = old.AppSecret
+		}
+		wb.Options.Headers = mergeHeaders(wb.Options.Headers, old.Headers)
+	}
+	typ, err := channel.NormalizeType(wb.Type)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
+	}
+	wb.Type = typ
+	return channel.Validate(*wb)
+}
+
+// maskFeishuWebhook 隐藏 WebHook 的加签密钥、飞书应用的 App Secret 和通用 WebHook 的请求头
+func maskFeishuWebhook(wb models.FeishuWebhook) models.FeishuWebhook {
+	if wb.Secret != "" {
+		wb.Secret = secretMask
+	}
+	if wb.Options.AppSecret != "" {
+		wb.Options.AppSecret = secretMask
 	}
-	rows, err := db.Query("SELECT id, name, url FROM feishu_webhook")
+	wb.Options.Headers = maskHeaders(wb.Options.Headers)
+	return wb
+}
+
+// GET /api/feishu_webhook
+func getFeishuWebhooks(c *gin.Context) {
+	webhooks, err := service.GetAllWebhooks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
 	var list []models.FeishuWebhook
-	for rows.Next() {
-		var wb models.FeishuWebhook
-		if err := rows.Scan(&wb.ID, &wb.Name, &wb.URL); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		list = append(list, wb)
+	for _, wb := range webhooks {
+		list = append(list, maskFeishuWebhook(wb))
 	}
 	c.JSON(http.StatusOK, list)
 }
 
+// GET /api/channel/types
+func getChannelTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, channel.Types())
+}
+
 // POST /api/feishu_webhook
 func createFeishuWebhook(c *gin.Context) {
 	var req FeishuWebhookReq
@@ -183,28 +431,35 @@ func createFeishuWebhook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	var wb models.FeishuWebhook
+	if err := applyFeishuWebhookReq(&wb, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	stmt, e := db.Prepare("INSERT INTO feishu_webhook(name, url) VALUES (?, ?)")
-	if e != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": e.Error()})
-		return
-	}
-	res, e2 := stmt.Exec(req.Name, req.URL)
-	if e2 != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": e2.Error()})
+	options, _ := json.Marshal(wb.Options)
+	res, err := db.Exec("INSERT INTO feishu_webhook(name, url, type, secret, options) VALUES (?, ?, ?, ?, ?)",
+		wb.Name, wb.URL, wb.Type, wb.Secret, string(options))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	newID, _ := res.LastInsertId()
-	c.JSON(http.StatusOK, gin.H{"id": newID, "name": req.Name, "url": req.URL})
+	c.JSON(http.StatusOK, gin.H{"id": newID, "name": wb.Name, "url": wb.URL, "type": wb.Type})
 }
 
 // PUT /api/feishu_webhook/:id
 func updateFeishuWebhook(c *gin.Context) {
 	idStr := c.Param("id") // 必须从 :id 获取
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 WebHook ID"})
+		return
+	}
 
 	var req FeishuWebhookReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -216,14 +471,20 @@ func updateFeishuWebhook(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	stmt, e := db.Prepare("UPDATE feishu_webhook SET name=?, url=? WHERE id=?")
-	if e != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": e.Error()})
+	wb, err := service.GetWebhook(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
 		return
 	}
-	res, e2 := stmt.Exec(req.Name, req.URL, idStr)
-	if e2 != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": e2.Error()})
+	if err := applyFeishuWebhookReq(&wb, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	options, _ := json.Marshal(wb.Options)
+	res, err := db.Exec("UPDATE feishu_webhook SET name=?, url=?, type=?, secret=?, options=? WHERE id=?",
+		wb.Name, wb.URL, wb.Type, wb.Secret, string(options), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	rowsAff, _ := res.RowsAffected()
@@ -234,6 +495,25 @@ func updateFeishuWebhook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "updated", "id": idStr})
 }
 
+// POST /api/feishu_webhook/:id/test
+func testFeishuWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 WebHook ID"})
+		return
+	}
+	wb, err := service.GetWebhook(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := channel.SendTest(c.Request.Context(), wb); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
 // DELETE /api/feishu_webhook/:id
 func deleteFeishuWebhook(c *gin.Context) {
 	idStr := c.Param("id")
@@ -254,14 +534,17 @@ func deleteFeishuWebhook(c *gin.Context) {
 
 // 新增：PromQL 配置结构体
 type PromQLConfig struct {
-	PromQLID          int64  `json:"promql_id"`
-	Unit              string `json:"unit"`
-	MetricLabel       string `json:"metric_label"`
-	CustomMetricLabel string `json:"custom_metric_label"`
-	ChartTemplateID   int64  `json:"chart_template_id"` // 每个PromQL可以有自己的图表模板
-	InitialUnit       string `json:"initial_unit"`      // 初始单位，用于自动单位转换
-	DisplayOrder      int    `json:"display_order"`     // 显示顺序，数字越小越靠前
-	DisplayMode       string `json:"display_mode"`      // 展示模式: chart(图表), text(文本), both(混合)
+	PromQLID          int64             `json:"promql_id"`
+	Unit              string            `json:"unit"`
+	MetricLabel       string            `json:"metric_label"`
+	CustomMetricLabel string            `json:"custom_metric_label"`
+	ChartTemplateID   int64             `json:"chart_template_id"` // 每个PromQL可以有自己的图表模板
+	InitialUnit       string            `json:"initial_unit"`      // 初始单位，用于自动单位转换
+	DisplayOrder      int               `json:"display_order"`     // 显示顺序，数字越小越靠前
+	DisplayMode       string            `json:"display_mode"`      // 展示模式: chart(图表), text(文本), both(混合)
+	Mentions          *[]models.Mention `json:"mentions"`          // 该 PromQL 的 @ 配置，未传时保持原值
+	Link              *string           `json:"link"`              // 该 PromQL 的详情链接，可使用模板变量，未传时保持原值
+	Stats             *[]string         `json:"stats"`             // 图表下方显示的序列统计项，未传时保持原值
 }
 
 type PushTaskReq struct {
@@ -281,11 +564,206 @@ type PushTaskReq struct {
 	MetricLabel       string                `json:"metric_label"`      // 保留向后兼容
 	CustomMetricLabel string                `json:"custom_metric_label"` // 保留向后兼容
 	Unit              string                `json:"unit"`              // 保留向后兼容
-	ButtonText        string                `json:"button_text"`
-	ButtonURL         string                `json:"button_url"`
+	ButtonText        string                `json:"button_text"` // 保留向后兼容，传 buttons 时使用第一个按钮
+	ButtonURL         string                `json:"button_url"`  // 保留向后兼容，传 buttons 时使用第一个按钮
+	Buttons           *[]models.Button      `json:"buttons"`     // 卡片底部的按钮列表，链接可使用模板变量，未传时保持原值
 	SendTimes         []models.TaskSendTime `json:"send_times"`
 	ShowDataLabel     bool                  `json:"show_data_label"`
 	PushMode          string                `json:"push_mode"` // 新增：推送模式 chart/text
+	CronExpr          *string               `json:"cron_expr"` // cron 表达式，未传时保持原值
+	Timezone          *string               `json:"timezone"`  // IANA 时区名称，未传时保持原值
+	CatchUpPolicy     *string               `json:"catchup_policy"` // 错过调度时间点的补发策略 skip/once/all，未传时保持原值
+	CatchUpGrace      *int                  `json:"catchup_grace"`  // 补发宽限期（秒），未传时保持原值
+	Mentions          *[]models.Mention     `json:"mentions"`       // 任务级的 @ 配置，未传时保持原值
+	CardLayoutID      *int64                `json:"card_layout_id"` // 自定义卡片布局 ID，0 表示使用默认卡片结构，未传时保持原值
+}
+
+// normalizeCronExpr 去除首尾空白并校验 cron 表达式，空字符串表示不使用 cron 调度
+func normalizeCronExpr(expr *string) (string, error) {
+	if expr == nil {
+		return "", nil
+	}
+	trimmed := strings.TrimSpace(*expr)
+	if trimmed == "" {
+		return "", nil
+	}
+	if _, err := scheduler.ParseCron(trimmed); err != nil {
+		return "", err
+	}
+	return trimmed, nil
+}
+
+// normalizeCatchUp 校验补发策略和宽限期，未传时返回默认值
+func normalizeCatchUp(policy *string, grace *int) (string, int, error) {
+	normalizedPolicy := scheduler.CatchUpSkip
+	if policy != nil {
+		p, err := scheduler.NormalizeCatchUpPolicy(*policy)
+		if err != nil {
+			return "", 0, err
+		}
+		normalizedPolicy = p
+	}
+	normalizedGrace := scheduler.DefaultCatchUpGrace
+	if grace != nil {
+		if *grace <= 0 {
+			return "", 0, fmt.Errorf("补发宽限期必须大于 0 秒")
+		}
+		normalizedGrace = *grace
+	}
+	return normalizedPolicy, normalizedGrace, nil
+}
+
+// encodeMentions 校验 @ 配置并编码为 JSON，未传时返回空数组
+func encodeMentions(mentions *[]models.Mention) (string, error) {
+	if mentions == nil {
+		return "[]", nil
+	}
+	if err := service.ValidateMentions(*mentions); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(*mentions)
+	if err != nil {
+		return "", err
+	}
+	if string(data) == "null" {
+		return "[]", nil
+	}
+	return string(data), nil
+}
+
+// encodeButtons 校验按钮列表并编码为 JSON，未传时返回空数组
+// 传了按钮列表时将 button_text/button_url 设置为第一个按钮，与旧字段保持一致
+func encodeButtons(req *PushTaskReq) (string, error) {
+	if req.Buttons == nil {
+		return "[]", nil
+	}
+	if err := service.ValidateButtons(*req.Buttons); err != nil {
+		return "", err
+	}
+	req.ButtonText, req.ButtonURL = service.FirstButton(*req.Buttons)
+	data, err := json.Marshal(*req.Buttons)
+	if err != nil {
+		return "", err
+	}
+	if string(data) == "null" {
+		return "[]", nil
+	}
+	return string(data), nil
+}
+
+// parseButtonsOrEmpty 解析数据库中的按钮列表用于接口返回，列表为空时使用旧的 button_text/button_url 字段
+func parseButtonsOrEmpty(data, buttonText, buttonURL string) []models.Button {
+	buttons, err := service.ParseButtons(data, buttonText, buttonURL)
+	if err != nil {
+		log.Printf("[parseButtonsOrEmpty] %v", err)
+	}
+	if buttons == nil {
+		return []models.Button{}
+	}
+	return buttons
+}
+
+// resolveCardLayout 检查任务引用的卡片布局是否存在，未传或为 0 时返回 nil
+func resolveCardLayout(db *sql.DB, id *int64) (*models.CardLayout, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	layout, err := scheduler.LoadCardLayout(db, *id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("卡片布局 %d 不存在", *id)
+	}
+	return layout, err
+}
+
+// parseMentionsOrEmpty 解析数据库中的 @ 配置用于接口返回，解析失败或为空时返回空数组
+func parseMentionsOrEmpty(data string) []models.Mention {
+	mentions, err := service.ParseMentions(data)
+	if err != nil {
+		log.Printf("[parseMentionsOrEmpty] %v", err)
+	}
+	if mentions == nil {
+		return []models.Mention{}
+	}
+	return mentions
+}
+
+// validatePromQLMentions 校验每个 PromQL 的 @ 配置
+func validatePromQLMentions(configs []PromQLConfig) error {
+	for _, config := range configs {
+		if config.Mentions == nil {
+			continue
+		}
+		if err := service.ValidateMentions(*config.Mentions); err != nil {
+			return fmt.Errorf("PromQL %d 的 @ 配置无效: %w", config.PromQLID, err)
+		}
+	}
+	return nil
+}
+
+// normalizePromQLLinks 去除每个 PromQL 链接的首尾空白并校验链接模板
+func normalizePromQLLinks(configs []PromQLConfig) error {
+	for _, config := range configs {
+		if config.Link == nil {
+			continue
+		}
+		*config.Link = strings.TrimSpace(*config.Link)
+		if *config.Link == "" {
+			continue
+		}
+		if err := service.ValidateLink(*config.Link); err != nil {
+			return fmt.Errorf("PromQL %d 的链接无效: %w", config.PromQLID, err)
+		}
+	}
+	return nil
+}
+
+// encodeStats 校验统计项并编码为 JSON，未传时返回空数组
+func encodeStats(stats *[]string) (string, error) {
+	if stats == nil || len(*stats) == 0 {
+		return "[]", nil
+	}
+	if err := service.ValidateStats(*stats); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(*stats)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// validatePromQLStats 校验每个 PromQL 的统计项
+func validatePromQLStats(configs []PromQLConfig) error {
+	for _, config := range configs {
+		if _, err := encodeStats(config.Stats); err != nil {
+			return fmt.Errorf("PromQL %d 的统计项无效: %w", config.PromQLID, err)
+		}
+	}
+	return nil
+}
+
+// parseStatsOrEmpty 解析数据库中的统计项用于接口返回，解析失败或为空时返回空数组
+func parseStatsOrEmpty(data string) []string {
+	stats, err := service.ParseStats(data)
+	if err != nil {
+		log.Printf("[parseStatsOrEmpty] %v", err)
+	}
+	if stats == nil {
+		return []string{}
+	}
+	return stats
+}
+
+// normalizeTimezone 去除首尾空白并校验时区名称，空字符串表示使用服务器本地时区
+func normalizeTimezone(name *string) (string, error) {
+	if name == nil {
+		return "", nil
+	}
+	trimmed := strings.TrimSpace(*name)
+	if _, err := service.LoadTimezone(trimmed); err != nil {
+		return "", err
+	}
+	return trimmed, nil
 }
 
 // 新增：查询项结构体
@@ -368,7 +846,14 @@ func getAllPushTasks(c *gin.Context) {
 			   COALESCE(pt.button_text, '') as button_text,
 			   COALESCE(pt.button_url, '') as button_url,
 			   COALESCE(pt.show_data_label, 0) as show_data_label,
-			   COALESCE(pt.push_mode, 'chart') as push_mode
+			   COALESCE(pt.push_mode, 'chart') as push_mode,
+			   COALESCE(pt.cron_expr, '') as cron_expr,
+			   COALESCE(pt.timezone, '') as timezone,
+			   COALESCE(pt.catchup_policy, 'skip') as catchup_policy,
+			   COALESCE(pt.catchup_grace, 3600) as catchup_grace,
+			   COALESCE(pt.mentions, '') as mentions,
+			   COALESCE(pt.card_layout_id, 0) as card_layout_id,
+			   COALESCE(pt.buttons, '') as buttons
 		FROM push_task pt
 	`, customMetricLabelPart)
 
@@ -401,6 +886,13 @@ func getAllPushTasks(c *gin.Context) {
 			ButtonURL         string
 			ShowDataLabel     int
 			PushMode          string
+			CronExpr          string
+			Timezone          string
+			CatchUpPolicy     string
+			CatchUpGrace      int
+			Mentions          string
+			CardLayoutID      int64
+			Buttons           string
 		}
 
 		err := rows.Scan(
@@ -408,7 +900,8 @@ func getAllPushTasks(c *gin.Context) {
 			&task.SchedInterval, &task.LastRunAt, &task.Enabled, &task.CardTitle,
 			&task.CardTemplate, &task.MetricLabel, &task.Unit, &task.ChartTemplateID,
 			&task.CustomMetricLabel, &task.ButtonText, &task.ButtonURL, &task.ShowDataLabel,
-			&task.PushMode,
+			&task.PushMode, &task.CronExpr, &task.Timezone, &task.CatchUpPolicy, &task.CatchUpGrace,
+			&task.Mentions, &task.CardLayoutID, &task.Buttons,
 		)
 		if err != nil {
 			log.Printf("扫描任务数据失败: %v", err)
@@ -435,6 +928,13 @@ func getAllPushTasks(c *gin.Context) {
 			"button_url":          task.ButtonURL,
 			"show_data_label":     task.ShowDataLabel == 1,
 			"push_mode":           task.PushMode,
+			"cron_expr":           task.CronExpr,
+			"timezone":            task.Timezone,
+			"catchup_policy":      task.CatchUpPolicy,
+			"catchup_grace":       task.CatchUpGrace,
+			"mentions":            parseMentionsOrEmpty(task.Mentions),
+			"card_layout_id":      task.CardLayoutID,
+			"buttons":             parseButtonsOrEmpty(task.Buttons, task.ButtonText, task.ButtonURL),
 		}
 
 		// 获取任务的发送时间
@@ -447,6 +947,7 @@ func getAllPushTasks(c *gin.Context) {
 			log.Printf("查询任务发送时间失败: %v", err)
 		} else {
 			var sendTimes []map[string]interface{}
+			var taskSendTimes []models.TaskSendTime
 			for sendTimeRows.Next() {
 				var st struct {
 					ID       int64
@@ -462,9 +963,30 @@ func getAllPushTasks(c *gin.Context) {
 					"weekday":   st.Weekday,
 					"send_time": st.SendTime,
 				})
+				taskSendTimes = append(taskSendTimes, models.TaskSendTime{
+					ID:       st.ID,
+					TaskID:   task.ID,
+					Weekday:  st.Weekday,
+					SendTime: st.SendTime,
+				})
 			}
 			sendTimeRows.Close()
 			taskMap["send_times"] = sendTimes
+
+			// 根据 cron 表达式和发送时间计算下一次执行时间
+			taskMap["next_run_at"] = ""
+			if task.Enabled == 1 {
+				loc, err := service.LoadTimezone(task.Timezone)
+				if err != nil || loc == nil {
+					loc = time.Local
+				}
+				nextRunAt, err := scheduler.NextRunAt(task.CronExpr, taskSendTimes, time.Now().In(loc))
+				if err != nil {
+					log.Printf("计算任务 %d 下一次执行时间失败: %v", task.ID, err)
+				} else if !nextRunAt.IsZero() {
+					taskMap["next_run_at"] = nextRunAt.Format("2006-01-02 15:04:05")
+				}
+			}
 		}
 
 	// 获取关联的PromQL IDs和每个PromQL的独立配置
@@ -472,6 +994,9 @@ func getAllPushTasks(c *gin.Context) {
 		SELECT ptp.promql_id, ptp.chart_template_id, 
 		       ptp.unit, ptp.metric_label, ptp.custom_metric_label, ptp.initial_unit, ptp.display_order,
 		       COALESCE(ptp.display_mode, 'chart') as display_mode,
+		       COALESCE(ptp.mentions, '') as mentions,
+		       COALESCE(ptp.link, '') as link,
+		       COALESCE(ptp.stats, '') as stats,
 		       p.name as promql_name
 		FROM push_task_promql ptp
 		LEFT JOIN promql p ON ptp.promql_id = p.id
@@ -489,9 +1014,9 @@ func getAllPushTasks(c *gin.Context) {
 		var promqlID int64
 		var chartTemplateID sql.NullInt64
 		var displayOrder int
-		var unit, metricLabel, customMetricLabel, initialUnit, displayMode, promqlName string
+		var unit, metricLabel, customMetricLabel, initialUnit, displayMode, mentions, link, stats, promqlName string
 		if err := promqlRows.Scan(&promqlID, &chartTemplateID, 
-			&unit, &metricLabel, &customMetricLabel, &initialUnit, &displayOrder, &displayMode, &promqlName); err != nil {
+			&unit, &metricLabel, &customMetricLabel, &initialUnit, &displayOrder, &displayMode, &mentions, &link, &stats, &promqlName); err != nil {
 			log.Printf("扫描PromQL数据失败: %v", err)
 			continue
 		}
@@ -507,6 +1032,9 @@ func getAllPushTasks(c *gin.Context) {
 			"initial_unit":        initialUnit,
 			"display_order":       displayOrder,
 			"display_mode":        displayMode,
+			"mentions":            parseMentionsOrEmpty(mentions),
+			"link":                link,
+			"stats":               parseStatsOrEmpty(stats),
 		}
 				if chartTemplateID.Valid {
 					promqlConfig["chart_template_id"] = chartTemplateID.Int64
@@ -529,7 +1057,7 @@ func getAllPushTasks(c *gin.Context) {
 
 		// 获取绑定的webhook
 		webhookRows, err := db.Query(`
-			SELECT w.id, w.name, w.url
+			SELECT w.id, w.name, w.url, COALESCE(w.type, 'feishu')
 			FROM feishu_webhook w
 			INNER JOIN push_task_webhook ptw ON w.id = ptw.webhook_id
 			WHERE ptw.task_id = ?
@@ -544,8 +1072,9 @@ func getAllPushTasks(c *gin.Context) {
 					ID   int64
 					Name string
 					URL  string
+					Type string
 				}
-				if err := webhookRows.Scan(&webhook.ID, &webhook.Name, &webhook.URL); err != nil {
+				if err := webhookRows.Scan(&webhook.ID, &webhook.Name, &webhook.URL, &webhook.Type); err != nil {
 					log.Printf("扫描webhook数据失败: %v", err)
 					continue
 				}
@@ -553,6 +1082,7 @@ func getAllPushTasks(c *gin.Context) {
 					"id":   webhook.ID,
 					"name": webhook.Name,
 					"url":  webhook.URL,
+					"type": webhook.Type,
 				})
 				webhookIDs = append(webhookIDs, webhook.ID)
 			}
@@ -589,6 +1119,11 @@ func getAllPushTasks(c *gin.Context) {
 				"button_text":         "",
 				"button_url":          "",
 				"send_times":          []map[string]interface{}{},
+				"cron_expr":           "",
+				"timezone":            "",
+				"catchup_policy":      scheduler.CatchUpSkip,
+				"catchup_grace":       scheduler.DefaultCatchUpGrace,
+				"next_run_at":         "",
 			},
 		}
 	}
@@ -635,9 +1170,50 @@ func createPushTask(c *gin.Context) {
 		req.PushMode = "chart" // 默认推送模式为图表
 	}
 
-	// 验证必填字段
-	if req.SourceID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "source_id is required"})
+	cronExpr, err := normalizeCronExpr(req.CronExpr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的 cron 表达式: %v", err)})
+		return
+	}
+
+	timezone, err := normalizeTimezone(req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	catchUpPolicy, catchUpGrace, err := normalizeCatchUp(req.CatchUpPolicy, req.CatchUpGrace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mentions, err := encodeMentions(req.Mentions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromQLMentions(req.PromQLConfigs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	buttons, err := encodeButtons(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizePromQLLinks(req.PromQLConfigs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromQLStats(req.PromQLConfigs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 验证必填字段
+	if req.SourceID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source_id is required"})
 		return
 	}
 
@@ -665,6 +1241,15 @@ func createPushTask(c *gin.Context) {
 		return
 	}
 
+	if _, err := resolveCardLayout(db, req.CardLayoutID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var cardLayoutID int64
+	if req.CardLayoutID != nil {
+		cardLayoutID = *req.CardLayoutID
+	}
+
 	// 解析时间范围
 	duration := parseTimeRange(req.TimeRange)
 	log.Printf("[createPushTask] Parsed time_range '%s' to duration: %v", req.TimeRange, duration)
@@ -686,11 +1271,13 @@ func createPushTask(c *gin.Context) {
 		INSERT INTO push_task (
 			name, source_id, time_range, step, schedule_interval, 
 			card_title, card_template, metric_label, unit, enabled,
-			custom_metric_label, button_text, button_url, push_mode
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			custom_metric_label, button_text, button_url, push_mode, cron_expr, timezone,
+			catchup_policy, catchup_grace, mentions, card_layout_id, buttons
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, req.Name, req.SourceID, req.TimeRange, stepSeconds, req.SchedInterval,
 		req.CardTitle, req.CardTemplate, req.MetricLabel, req.Unit, true,
-		req.CustomMetricLabel, req.ButtonText, req.ButtonURL, req.PushMode)
+		req.CustomMetricLabel, req.ButtonText, req.ButtonURL, req.PushMode, cronExpr, timezone,
+		catchUpPolicy, catchUpGrace, mentions, cardLayoutID, buttons)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -769,14 +1356,20 @@ func createPushTask(c *gin.Context) {
 			if displayMode == "" {
 				displayMode = "chart"
 			}
+			promqlMentions, _ := encodeMentions(config.Mentions)
+			var link string
+			if config.Link != nil {
+				link = *config.Link
+			}
+			stats, _ := encodeStats(config.Stats)
 
 	_, err = tx.Exec(`
 		INSERT INTO push_task_promql (
 			task_id, promql_id, chart_template_id, 
-			unit, metric_label, custom_metric_label, initial_unit, display_order, display_mode
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			unit, metric_label, custom_metric_label, initial_unit, display_order, display_mode, mentions, link, stats
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, taskID, config.PromQLID, chartTemplateID, 
-		unit, metricLabel, customMetricLabel, config.InitialUnit, config.DisplayOrder, displayMode)
+		unit, metricLabel, customMetricLabel, config.InitialUnit, config.DisplayOrder, displayMode, promqlMentions, link, stats)
 			if err != nil {
 				log.Printf("[createPushTask] Failed to insert push_task_promql with config: %v", err)
 				// 继续处理其他 PromQL，不中断
@@ -902,6 +1495,55 @@ func updatePushTask(c *gin.Context) {
 		return
 	}
 
+	cronExpr, err := normalizeCronExpr(req.CronExpr)
+	if err != nil {
+		log.Printf("[updatePushTask] cron 表达式无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的 cron 表达式: %v", err)})
+		return
+	}
+
+	timezone, err := normalizeTimezone(req.Timezone)
+	if err != nil {
+		log.Printf("[updatePushTask] 时区无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	catchUpPolicy, catchUpGrace, err := normalizeCatchUp(req.CatchUpPolicy, req.CatchUpGrace)
+	if err != nil {
+		log.Printf("[updatePushTask] 补发配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mentions, err := encodeMentions(req.Mentions)
+	if err != nil {
+		log.Printf("[updatePushTask] @ 配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromQLMentions(req.PromQLConfigs); err != nil {
+		log.Printf("[updatePushTask] PromQL @ 配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	buttons, err := encodeButtons(&req)
+	if err != nil {
+		log.Printf("[updatePushTask] 按钮配置无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizePromQLLinks(req.PromQLConfigs); err != nil {
+		log.Printf("[updatePushTask] PromQL 链接无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromQLStats(req.PromQLConfigs); err != nil {
+		log.Printf("[updatePushTask] PromQL 统计项无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 检查查询是否存在
 	hasQueries := len(req.Queries) > 0
 	if !hasQueries && req.Query == "" {
@@ -920,6 +1562,12 @@ func updatePushTask(c *gin.Context) {
 		}
 	}
 
+	if _, err := resolveCardLayout(db, req.CardLayoutID); err != nil {
+		log.Printf("[updatePushTask] 卡片布局无效: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 解析时间范围
 	duration := parseTimeRange(req.TimeRange)
 	log.Printf("[updatePushTask] 解析时间范围 '%s' 为: %v", req.TimeRange, duration)
@@ -987,6 +1635,65 @@ func updatePushTask(c *gin.Context) {
 	rowsAffected, _ := result.RowsAffected()
 	log.Printf("[updatePushTask] 更新任务主记录成功，影响行数: %d", rowsAffected)
 
+	// 仅在请求中携带 cron_expr 时更新，避免旧版前端保存任务时清空 cron 配置
+	if req.CronExpr != nil {
+		if _, err := tx.Exec("UPDATE push_task SET cron_expr = ? WHERE id = ?", cronExpr, id); err != nil {
+			log.Printf("[updatePushTask] 更新 cron 表达式失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[updatePushTask] 更新 cron 表达式: %q", cronExpr)
+	}
+
+	// 时区同样仅在请求中携带时更新
+	if req.Timezone != nil {
+		if _, err := tx.Exec("UPDATE push_task SET timezone = ? WHERE id = ?", timezone, id); err != nil {
+			log.Printf("[updatePushTask] 更新时区失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[updatePushTask] 更新时区: %q", timezone)
+	}
+
+	// 补发策略和宽限期同样仅在请求中携带时更新
+	if req.CatchUpPolicy != nil {
+		if _, err := tx.Exec("UPDATE push_task SET catchup_policy = ? WHERE id = ?", catchUpPolicy, id); err != nil {
+			log.Printf("[updatePushTask] 更新补发策略失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.CatchUpGrace != nil {
+		if _, err := tx.Exec("UPDATE push_task SET catchup_grace = ? WHERE id = ?", catchUpGrace, id); err != nil {
+			log.Printf("[updatePushTask] 更新补发宽限期失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// @ 配置同样仅在请求中携带时更新
+	if req.Mentions != nil {
+		if _, err := tx.Exec("UPDATE push_task SET mentions = ? WHERE id = ?", mentions, id); err != nil {
+			log.Printf("[updatePushTask] 更新 @ 配置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.CardLayoutID != nil {
+		if _, err := tx.Exec("UPDATE push_task SET card_layout_id = ? WHERE id = ?", *req.CardLayoutID, id); err != nil {
+			log.Printf("[updatePushTask] 更新卡片布局失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Buttons != nil {
+		if _, err := tx.Exec("UPDATE push_task SET buttons = ? WHERE id = ?", buttons, id); err != nil {
+			log.Printf("[updatePushTask] 更新按钮失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 更新发送时间
 	// 1. 删除旧的发送时间
 	result, err = tx.Exec("DELETE FROM push_task_send_time WHERE task_id = ?", id)
@@ -1027,6 +1734,26 @@ func updatePushTask(c *gin.Context) {
 	// 更新关联的 PromQL
 	// 优先使用 PromQLConfigs（新格式），如果没有则使用 PromQLIDs（向后兼容）
 	if len(req.PromQLConfigs) > 0 || len(req.PromQLIDs) > 0 {
+		// 记录旧关联的 @ 配置、链接和统计项，请求中未携带时保持原值
+		oldMentions := make(map[int64]string)
+		oldLinks := make(map[int64]string)
+		oldStats := make(map[int64]string)
+		mentionRows, err := tx.Query("SELECT promql_id, COALESCE(mentions, '[]'), COALESCE(link, ''), COALESCE(stats, '[]') FROM push_task_promql WHERE task_id = ?", id)
+		if err != nil {
+			log.Printf("[updatePushTask] 查询旧的PromQL @ 配置失败: %v", err)
+		} else {
+			for mentionRows.Next() {
+				var promqlID int64
+				var data, link, stats string
+				if err := mentionRows.Scan(&promqlID, &data, &link, &stats); err == nil {
+					oldMentions[promqlID] = data
+					oldLinks[promqlID] = link
+					oldStats[promqlID] = stats
+				}
+			}
+			mentionRows.Close()
+		}
+
 		// 先删除旧的关联
 		result, err = tx.Exec("DELETE FROM push_task_promql WHERE task_id = ?", id)
 		if err != nil {
@@ -1061,13 +1788,26 @@ func updatePushTask(c *gin.Context) {
 					displayMode = "chart"
 				}
 
+				promqlMentions, ok := oldMentions[config.PromQLID]
+				if config.Mentions != nil || !ok {
+					promqlMentions, _ = encodeMentions(config.Mentions)
+				}
+				link := oldLinks[config.PromQLID]
+				if config.Link != nil {
+					link = *config.Link
+				}
+				stats, ok := oldStats[config.PromQLID]
+				if config.Stats != nil || !ok {
+					stats, _ = encodeStats(config.Stats)
+				}
+
 		_, err = tx.Exec(`
 			INSERT INTO push_task_promql (
 				task_id, promql_id, chart_template_id, 
-				unit, metric_label, custom_metric_label, initial_unit, display_order, display_mode
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				unit, metric_label, custom_metric_label, initial_unit, display_order, display_mode, mentions, link, stats
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`, id, config.PromQLID, chartTemplateID,
-			unit, metricLabel, customMetricLabel, config.InitialUnit, config.DisplayOrder, displayMode)
+			unit, metricLabel, customMetricLabel, config.InitialUnit, config.DisplayOrder, displayMode, promqlMentions, link, stats)
 				if err != nil {
 					log.Printf("[updatePushTask] 插入新的PromQL关联失败: promql_id=%d, error=%v", config.PromQLID, err)
 				}
@@ -1080,13 +1820,21 @@ func updatePushTask(c *gin.Context) {
 				if metricLabel == "" {
 					metricLabel = "pod"
 				}
+				promqlMentions, ok := oldMentions[promqlID]
+				if !ok {
+					promqlMentions = "[]"
+				}
+				promqlStats, ok := oldStats[promqlID]
+				if !ok {
+					promqlStats = "[]"
+				}
 		_, err = tx.Exec(`
 			INSERT INTO push_task_promql (
 				task_id, promql_id, chart_template_id, 
-				unit, metric_label, custom_metric_label, initial_unit, display_order
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				unit, metric_label, custom_metric_label, initial_unit, display_order, mentions, link, stats
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`, id, promqlID, req.ChartTemplateID,
-			req.Unit, metricLabel, req.CustomMetricLabel, "", 0)
+			req.Unit, metricLabel, req.CustomMetricLabel, "", 0, promqlMentions, oldLinks[promqlID], promqlStats)
 				if err != nil {
 					log.Printf("[updatePushTask] 插入新的PromQL关联失败: promql_id=%d, error=%v", promqlID, err)
 				}
@@ -1238,8 +1986,14 @@ func deletePushTask(c *gin.Context) {
 	if err != nil {
 		log.Printf("[deletePushTask] 删除 push_task_query 失败: %v", err)
 	}
+
+	// 5. 删除 task_run 执行记录
+	_, err = db.Exec("DELETE FROM task_run WHERE task_id=?", id)
+	if err != nil {
+		log.Printf("[deletePushTask] 删除 task_run 失败: %v", err)
+	}
 	
-	// 5. 最后删除 push_task 主记录
+	// 6. 最后删除 push_task 主记录
 	res, delErr := db.Exec("DELETE FROM push_task WHERE id=?", id)
 	if delErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": delErr.Error()})
@@ -1346,420 +2100,248 @@ func deletePushTaskWebhook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "unbound successfully"})
 }
 
-// ========== 可选: runSingleTaskPush + insertPushStatus (若需立即推送) ==========
-
-func runSingleTaskPush(db *sql.DB, taskID int64) {
-	// 获取任务详情
-	var sourceID int64
-	var name, timeRange, cardTitle, cardTemplate, metricLabel, unit, buttonText, buttonURL, customMetricLabel string
-	var step float64
-	var enabled int
-	var showDataLabel sql.NullInt64 // 使用 sql.NullInt64 来处理 NULL 值
+// -------------- chart_template --------------
 
-	log.Printf("[scheduler] Starting task execution for task ID=%d", taskID)
+type ChartTemplateReq struct {
+	Name      string `json:"name"`
+	ChartType string `json:"chart_type"`
+}
 
-	err := db.QueryRow(`
-		SELECT source_id, name, time_range, step, 
-		       card_title, card_template, metric_label, unit,
-		       button_text, button_url, enabled, COALESCE(show_data_label, 0) as show_data_label,
-		       COALESCE(custom_metric_label, '') as custom_metric_label
-		FROM push_task 
-		WHERE id = ?
-	`, taskID).Scan(&sourceID, &name, &timeRange, &step,
-		&cardTitle, &cardTemplate, &metricLabel, &unit,
-		&buttonText, &buttonURL, &enabled, &showDataLabel, &customMetricLabel)
+// GET /api/chart_template
+func getChartTemplates(c *gin.Context) {
+	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
-		log.Printf("[scheduler] Error querying task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 检查任务是否启用
-	if enabled != 1 {
-		log.Printf("[scheduler] Task ID=%d (%s) is disabled, skipping execution", taskID, name)
+	rows, err := db.Query("SELECT id, name, chart_type FROM chart_template")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer rows.Close()
 
-	// 更新最后运行时间
-	_, err = db.Exec("UPDATE push_task SET last_run_at = ? WHERE id = ?",
-		time.Now().Format("2006-01-02 15:04:05"), taskID)
-	if err != nil {
-		log.Printf("[scheduler] Error updating last_run_at: %v", err)
-		// 继续执行，不因更新时间失败而中断
+	var templates []models.ChartTemplate
+	for rows.Next() {
+		var tmpl models.ChartTemplate
+		if err := rows.Scan(&tmpl.ID, &tmpl.Name, &tmpl.ChartType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		templates = append(templates, tmpl)
 	}
+	c.JSON(http.StatusOK, templates)
+}
 
-	log.Printf("[runSingleTaskPush] Processing task ID=%d, name=%s, time_range=%s, step=%v, custom_metric_label=%s",
-		taskID, name, timeRange, step, customMetricLabel)
-
-	// 获取数据源URL
-	var sourceURL string
-	err = db.QueryRow("SELECT url FROM metrics_source WHERE id = ?", sourceID).Scan(&sourceURL)
-	if err != nil {
-		log.Printf("[runSingleTaskPush] Error querying metrics source: %v", err)
+// POST /api/chart_template
+func createChartTemplate(c *gin.Context) {
+	var req ChartTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 查询任务的所有查询
-	rows, err := db.Query(`
-		SELECT ptp.promql_id, p.query, ptp.chart_template_id, p.name,
-		       COALESCE(ptp.unit, '') as unit,
-		       COALESCE(ptp.initial_unit, '') as initial_unit
-		FROM push_task_promql ptp
-		JOIN promql p ON ptp.promql_id = p.id
-		WHERE ptp.task_id = ?
-	`, taskID)
+	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
-		log.Printf("[runSingleTaskPush] Error querying task promqls: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var queries []struct {
-		Query           string
-		ChartTemplateID int64
-		PromQLName      string
-		Unit            string
-		InitialUnit     string
+	stmt, err := db.Prepare("INSERT INTO chart_template(name, chart_type) VALUES (?, ?)")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	for rows.Next() {
-		var promqlID int64
-		var q struct {
-			Query           string
-			ChartTemplateID int64
-			PromQLName      string
-			Unit            string
-			InitialUnit     string
-		}
-		if err := rows.Scan(&promqlID, &q.Query, &q.ChartTemplateID, &q.PromQLName, &q.Unit, &q.InitialUnit); err != nil {
-			log.Printf("[runSingleTaskPush] Error scanning promql row: %v", err)
-			continue
-		}
-		queries = append(queries, q)
+	res, err := stmt.Exec(req.Name, req.ChartType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 如果没有找到查询，尝试使用旧的单查询格式
-	if len(queries) == 0 {
-		// 尝试从push_task_query表中获取查询
-		queryRows, err := db.Query(`
-			SELECT query, chart_template_id
-			FROM push_task_query
-			WHERE task_id = ?
-		`, taskID)
-		if err == nil {
-			defer queryRows.Close()
-			for queryRows.Next() {
-				var q struct {
-					Query           string
-					ChartTemplateID int64
-					PromQLName      string
-					Unit            string
-					InitialUnit     string
-				}
-				if err := queryRows.Scan(&q.Query, &q.ChartTemplateID); err != nil {
-					log.Printf("[runSingleTaskPush] Error scanning query row: %v", err)
-					continue
-				}
-				q.PromQLName = "" // 设置为空字符串
-				q.Unit = unit // 使用任务级别单位
-				q.InitialUnit = "" // 旧格式没有 initial_unit
-				queries = append(queries, q)
-			}
-		} else {
-			log.Printf("[runSingleTaskPush] Error querying push_task_query: %v", err)
-		}
-
-		// 如果仍然没有查询，尝试从push_task表中获取
-		if len(queries) == 0 {
-			var query string
-			var chartTemplateID int64
-			err := db.QueryRow(`
-				SELECT query, chart_template_id
-				FROM push_task
-				WHERE id = ?
-			`, taskID).Scan(&query, &chartTemplateID)
-
-			if err == nil && query != "" {
-				queries = append(queries, struct {
-					Query           string
-					ChartTemplateID int64
-					PromQLName      string
-					Unit            string
-					InitialUnit     string
-				}{
-					Query:           query,
-					ChartTemplateID: chartTemplateID,
-					PromQLName:      "",
-					Unit:            unit, // 使用任务级别单位
-					InitialUnit:     "", // 旧格式没有 initial_unit
-				})
-			}
-		}
-	}
+	newID, _ := res.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{
+		"id":         newID,
+		"name":       req.Name,
+		"chart_type": req.ChartType,
+	})
+}
 
-	// 如果仍然没有查询，记录错误并返回
-	if len(queries) == 0 {
-		log.Printf("[runSingleTaskPush] No queries found for task ID=%d", taskID)
+// PUT /api/chart_template/:id
+func updateChartTemplate(c *gin.Context) {
+	idStr := c.Param("id")
+	var req ChartTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 解析time_range为持续时间
-	duration := parseTimeRange(timeRange)
-	log.Printf("[runSingleTaskPush] Parsed time_range '%s' to duration: %v", timeRange, duration)
-
-	// 计算时间范围
-	end := time.Now()
-	start := end.Add(-duration)
-	log.Printf("[runSingleTaskPush] Time range: start=%v, end=%v", start, end)
-
-	// 确保我们获得最近的数据，特别是对于较长的时间范围
-	if duration > 72*time.Hour { // 对于超过3天的查询
-		log.Printf("[runSingleTaskPush] Long time range detected (%v), ensuring recent data is prioritized", duration)
-		// 记录原始时间范围，以便于调试
-		log.Printf("[runSingleTaskPush] Original time range: %s to %s",
-			start.Format("2006-01-02 15:04:05"),
-			end.Format("2006-01-02 15:04:05"))
-
-		// 强制使用当前时间作为结束时间
-		end = time.Now()
-		start = end.Add(-duration)
-		log.Printf("[runSingleTaskPush] Adjusted time range to ensure recent data: %s to %s",
-			start.Format("2006-01-02 15:04:05"),
-			end.Format("2006-01-02 15:04:05"))
+	db, err := database.SetupDB("./data/app.db")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 原始的step（小时）转换为时间间隔
-	origStepDuration := time.Duration(step*float64(time.Hour.Seconds())) * time.Second
-	log.Printf("[runSingleTaskPush] Original step from database: %v seconds (%v)",
-		origStepDuration.Seconds(), origStepDuration)
-
-	// 根据时间范围计算合适的步长
-	calculatedStep := service.GetDurationStep(duration)
-	log.Printf("[runSingleTaskPush] Calculated step based on duration %v: %v",
-		duration, calculatedStep)
-
-	// 确保步长合理（至少产生5个数据点）
-	expectedPoints := duration.Seconds() / calculatedStep.Seconds()
-	log.Printf("[runSingleTaskPush] Expected data points with calculated step: %.1f", expectedPoints)
-
-	if expectedPoints < 5 {
-		// 如果数据点太少，调整步长确保至少有10个数据点
-		adjustedStep := time.Duration(duration.Seconds()/10) * time.Second
-		log.Printf("[runSingleTaskPush] Adjusting step to ensure at least 10 data points: %v -> %v",
-			calculatedStep, adjustedStep)
-		calculatedStep = adjustedStep
-		expectedPoints = float64(duration) / float64(adjustedStep)
-		log.Printf("[runSingleTaskPush] Final expected points: %.2f", expectedPoints)
-	} else if duration > 120*time.Hour && expectedPoints > 20 { // 对于超过5天的时间范围
-		// 增加日志以便于调试
-		log.Printf("[runSingleTaskPush] Long time range with many data points, might need prioritization")
+	stmt, err := db.Prepare("UPDATE chart_template SET name=?, chart_type=? WHERE id=?")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 为每个查询获取数据点
-	var allDataPoints []models.QueryDataPoints
-	for i, query := range queries {
-		// 获取图表类型
-		var chartType string
-		err = db.QueryRow("SELECT chart_type FROM chart_template WHERE id = ?", query.ChartTemplateID).Scan(&chartType)
-		if err != nil {
-			log.Printf("[runSingleTaskPush] Error querying chart template (ID=%d): %v (using default 'area')",
-				query.ChartTemplateID, err)
-			chartType = "area" // 默认为area类型
-		}
-
-		// 确保使用飞书支持的图表类型
-		chartType = service.GetSupportedChartType(chartType)
-		log.Printf("[runSingleTaskPush] Using chart type: %s for query %d", chartType, i+1)
-
-		// 获取数据点
-		log.Printf("[runSingleTaskPush] Fetching metrics for query %d: %s", i+1, query.Query)
-		log.Printf("[runSingleTaskPush] Using custom_metric_label: '%s', fallback metric_label: '%s'",
-			customMetricLabel, metricLabel)
-
-	dataPoints, err := service.FetchMetrics(sourceURL, query.Query, start, end, calculatedStep, metricLabel, customMetricLabel, query.InitialUnit, query.Unit)
+	res, err := stmt.Exec(req.Name, req.ChartType, idStr)
 	if err != nil {
-		log.Printf("[runSingleTaskPush] Error fetching metrics for query %d: %v", i+1, err)
-		continue
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-		log.Printf("[runSingleTaskPush] Fetched %d data points for query %d", len(dataPoints), i+1)
-
-	// 添加到结果集
-	chartTitle := query.PromQLName
-	if chartTitle == "" {
-		// 如果PromQL名称为空，使用默认的"查询 N"
-		chartTitle = fmt.Sprintf("查询 %d", i+1)
+	rowsAff, _ := res.RowsAffected()
+	if rowsAff == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "template not found"})
+		return
 	}
 
-	allDataPoints = append(allDataPoints, models.QueryDataPoints{
-		DataPoints: dataPoints,
-		ChartType:  chartType,
-		ChartTitle: chartTitle,
-		Unit:       query.Unit, // 使用每个查询的独立单位
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "updated", "id": idStr})
 }
 
-	// 如果没有获取到任何数据点，记录错误并返回
-	if len(allDataPoints) == 0 {
-		log.Printf("[runSingleTaskPush] No data points fetched for any query, task ID=%d", taskID)
+// DELETE /api/chart_template/:id
+func deleteChartTemplate(c *gin.Context) {
+	idStr := c.Param("id")
+	db, err := database.SetupDB("./data/app.db")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 获取所有绑定的webhook
-	log.Printf("[runSingleTaskPush] Querying webhooks for task ID=%d", taskID)
-	webhookRows, err := db.Query(`
-        SELECT w.id, w.url 
-        FROM feishu_webhook w
-        JOIN push_task_webhook ptw ON w.id = ptw.webhook_id
-        WHERE ptw.task_id = ?
-    `, taskID)
+	stmt, err := db.Prepare("DELETE FROM chart_template WHERE id=?")
 	if err != nil {
-		log.Printf("[runSingleTaskPush] Error querying webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer webhookRows.Close()
-
-	var webhooks []struct {
-		ID  int64
-		URL string
-	}
 
-	for webhookRows.Next() {
-		var wh struct {
-			ID  int64
-			URL string
-		}
-		if err := webhookRows.Scan(&wh.ID, &wh.URL); err != nil {
-			log.Printf("[runSingleTaskPush] Error scanning webhook row: %v", err)
-			continue
-		}
-		webhooks = append(webhooks, wh)
+	res, err := stmt.Exec(idStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 检查是否找到了webhook
-	if len(webhooks) == 0 {
-		log.Printf("[runSingleTaskPush] No webhooks found for task ID=%d", taskID)
+	rowsAff, _ := res.RowsAffected()
+	if rowsAff == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "template not found"})
 		return
 	}
 
-	log.Printf("[runSingleTaskPush] Found %d webhooks for task ID=%d", len(webhooks), taskID)
-
-	// 发送到每个webhook
-	for _, wh := range webhooks {
-		log.Printf("[runSingleTaskPush] Sending to webhook ID=%d, URL=%s", wh.ID, wh.URL)
-		err := service.SendFeishuStandardChart(wh.URL, allDataPoints, cardTitle, cardTemplate, unit, buttonText, buttonURL, showDataLabel.Int64 == 1)
-		if err != nil {
-			log.Printf("[runSingleTaskPush] Error sending to webhook: %v", err)
-			insertPushStatus(db, sourceID, wh.ID, err)
-		} else {
-			log.Printf("[runSingleTaskPush] Successfully sent to webhook ID=%d", wh.ID)
-			insertPushStatus(db, sourceID, wh.ID, nil)
-		}
-	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted", "id": idStr})
 }
 
-func insertPushStatus(db *sql.DB, sourceID, webhookID int64, err error) {
-	status := "success"
-	logTxt := "ok"
-	if err != nil {
-		status = "failed"
-		logTxt = err.Error()
-	}
-	db.Exec(`
-        INSERT INTO push_status (source_id, webhook_id, last_run_time, status, log)
-        VALUES (?, ?, datetime('now'), ?, ?)
-    `, sourceID, webhookID, status, logTxt)
-}
+// -------------- card_layout --------------
 
-// -------------- chart_template --------------
+// CardLayoutReq 是创建或更新卡片布局的请求结构
+type CardLayoutReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`    // template/blocks，默认 blocks
+	Content     string `json:"content"` // template 为 text/template 模板，blocks 为块列表的 JSON
+}
 
-type ChartTemplateReq struct {
-	Name      string `json:"name"`
-	ChartType string `json:"chart_type"`
+// validate 校验请求并使用示例数据试渲染布局
+func (req *CardLayoutReq) validate() error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if req.Kind == "" {
+		req.Kind = models.LayoutKindBlocks
+	}
+	layout := models.CardLayout{Name: req.Name, Kind: req.Kind, Content: req.Content}
+	return channel.ValidateLayout(&layout)
 }
 
-// GET /api/chart_template
-func getChartTemplates(c *gin.Context) {
+// GET /api/card_layout
+func getCardLayouts(c *gin.Context) {
 	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rows, err := db.Query("SELECT id, name, chart_type FROM chart_template")
+	rows, err := db.Query(`
+		SELECT id, name, COALESCE(description, ''), kind, content, created_at, updated_at
+		FROM card_layout
+		ORDER BY id DESC
+	`)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	var templates []models.ChartTemplate
+	layouts := []models.CardLayout{}
 	for rows.Next() {
-		var tmpl models.ChartTemplate
-		if err := rows.Scan(&tmpl.ID, &tmpl.Name, &tmpl.ChartType); err != nil {
+		var layout models.CardLayout
+		if err := rows.Scan(&layout.ID, &layout.Name, &layout.Description, &layout.Kind, &layout.Content, &layout.CreatedAt, &layout.UpdatedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		templates = append(templates, tmpl)
+		layouts = append(layouts, layout)
 	}
-	c.JSON(http.StatusOK, templates)
+	c.JSON(http.StatusOK, layouts)
 }
 
-// POST /api/chart_template
-func createChartTemplate(c *gin.Context) {
-	var req ChartTemplateReq
+// POST /api/card_layout
+func createCardLayout(c *gin.Context) {
+	var req CardLayoutReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	db, err := database.SetupDB("./data/app.db")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	stmt, err := db.Prepare("INSERT INTO chart_template(name, chart_type) VALUES (?, ?)")
+	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	res, err := stmt.Exec(req.Name, req.ChartType)
+	res, err := db.Exec(`
+		INSERT INTO card_layout (name, description, kind, content, created_at, updated_at)
+		VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
+	`, req.Name, req.Description, req.Kind, req.Content)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	newID, _ := res.LastInsertId()
-	c.JSON(http.StatusOK, gin.H{
-		"id":         newID,
-		"name":       req.Name,
-		"chart_type": req.ChartType,
-	})
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{"id": id})
 }
 
-// PUT /api/chart_template/:id
-func updateChartTemplate(c *gin.Context) {
+// PUT /api/card_layout/:id
+func updateCardLayout(c *gin.Context) {
 	idStr := c.Param("id")
-	var req ChartTemplateReq
+	var req CardLayoutReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	db, err := database.SetupDB("./data/app.db")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	stmt, err := db.Prepare("UPDATE chart_template SET name=?, chart_type=? WHERE id=?")
+	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	res, err := stmt.Exec(req.Name, req.ChartType, idStr)
+	res, err := db.Exec(`
+		UPDATE card_layout
+		SET name = ?, description = ?, kind = ?, content = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, req.Name, req.Description, req.Kind, req.Content, idStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1767,15 +2349,15 @@ func updateChartTemplate(c *gin.Context) {
 
 	rowsAff, _ := res.RowsAffected()
 	if rowsAff == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "template not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "卡片布局不存在"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "updated", "id": idStr})
+	c.JSON(http.StatusOK, gin.H{"id": idStr})
 }
 
-// DELETE /api/chart_template/:id
-func deleteChartTemplate(c *gin.Context) {
+// DELETE /api/card_layout/:id
+func deleteCardLayout(c *gin.Context) {
 	idStr := c.Param("id")
 	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
@@ -1783,13 +2365,18 @@ func deleteChartTemplate(c *gin.Context) {
 		return
 	}
 
-	stmt, err := db.Prepare("DELETE FROM chart_template WHERE id=?")
-	if err != nil {
+	// 检查是否有任务使用此布局
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM push_task WHERE card_layout_id = ?", idStr).Scan(&count); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无法删除：此卡片布局正在被 %d 个任务使用", count)})
+		return
+	}
 
-	res, err := stmt.Exec(idStr)
+	res, err := db.Exec("DELETE FROM card_layout WHERE id = ?", idStr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1797,11 +2384,11 @@ func deleteChartTemplate(c *gin.Context) {
 
 	rowsAff, _ := res.RowsAffected()
 	if rowsAff == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "template not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "卡片布局不存在"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "deleted", "id": idStr})
+	c.JSON(http.StatusOK, gin.H{"id": idStr})
 }
 
 // PromQLReq 是创建或更新 PromQL 的请求结构
@@ -1810,6 +2397,22 @@ type PromQLReq struct {
 	Description string `json:"description"`
 	Query       string `json:"query"`
 	Category    string `json:"category"`
+	SourceID    *int64 `json:"source_id"` // 为 0 时使用任务的数据源，更新时未传入则保持不变
+}
+
+// validatePromQLSource 校验 PromQL 指定的数据源是否存在
+func validatePromQLSource(db *sql.DB, sourceID *int64) error {
+	if sourceID == nil || *sourceID <= 0 {
+		return nil
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM metrics_source WHERE id = ?", *sourceID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("数据源 %d 不存在", *sourceID)
+	}
+	return nil
 }
 
 // 获取所有 PromQL 查询
@@ -1821,7 +2424,7 @@ func getPromQLs(c *gin.Context) {
 	}
 
 	rows, err := db.Query(`
-		SELECT id, name, description, query, category, created_at, updated_at
+		SELECT id, name, description, query, category, COALESCE(source_id, 0), created_at, updated_at
 		FROM promql
 		ORDER BY id DESC
 	`)
@@ -1834,7 +2437,7 @@ func getPromQLs(c *gin.Context) {
 	var promqls []models.PromQL
 	for rows.Next() {
 		var promql models.PromQL
-		if err := rows.Scan(&promql.ID, &promql.Name, &promql.Description, &promql.Query, &promql.Category, &promql.CreatedAt, &promql.UpdatedAt); err != nil {
+		if err := rows.Scan(&promql.ID, &promql.Name, &promql.Description, &promql.Query, &promql.Category, &promql.SourceID, &promql.CreatedAt, &promql.UpdatedAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -1864,10 +2467,15 @@ func createPromQL(c *gin.Context) {
 		return
 	}
 
+	if err := validatePromQLSource(db, req.SourceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	result, err := db.Exec(`
-		INSERT INTO promql (name, description, query, category, created_at, updated_at)
-		VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
-	`, req.Name, req.Description, req.Query, req.Category)
+		INSERT INTO promql (name, description, query, category, source_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, COALESCE(?, 0), datetime('now'), datetime('now'))
+	`, req.Name, req.Description, req.Query, req.Category, req.SourceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1903,11 +2511,16 @@ func updatePromQL(c *gin.Context) {
 		return
 	}
 
+	if err := validatePromQLSource(db, req.SourceID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	_, err = db.Exec(`
 		UPDATE promql
-		SET name = ?, description = ?, query = ?, category = ?, updated_at = datetime('now')
+		SET name = ?, description = ?, query = ?, category = ?, source_id = COALESCE(?, source_id), updated_at = datetime('now')
 		WHERE id = ?
-	`, req.Name, req.Description, req.Query, req.Category, id)
+	`, req.Name, req.Description, req.Query, req.Category, req.SourceID, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1952,76 +2565,289 @@ func deletePromQL(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"id": id})
 }
 
-// runPushTaskHandler 手动执行任务的处理函数
-func runPushTaskHandler(c *gin.Context) {
-	idStr := c.Param("id")
-	taskID, err := strconv.ParseInt(idStr, 10, 64)
+// GET /api/push_task/:id/runs?page=1&page_size=20
+// getPushTaskRuns 分页返回任务的执行记录
+func getPushTaskRuns(c *gin.Context) {
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
 		return
 	}
 
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
 	db, err := database.SetupDB("./data/app.db")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 检查任务是否存在且启用
-	var enabled int
-	err = db.QueryRow("SELECT enabled FROM push_task WHERE id = ?", taskID).Scan(&enabled)
+	runs, total, err := scheduler.ListTaskRuns(db, taskID, page, pageSize)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务状态失败"})
+		log.Printf("[getPushTaskRuns] 查询执行记录失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询执行记录失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     runs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// runPushTaskHandler 手动执行任务的处理函数
+func runPushTaskHandler(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		taskID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+			return
+		}
+
+		db, err := database.SetupDB("./data/app.db")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+
+		// 检查任务是否存在且启用
+		var enabled int
+		err = db.QueryRow("SELECT enabled FROM push_task WHERE id = ?", taskID).Scan(&enabled)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务状态失败"})
+			}
+			return
+		}
+
+		if enabled != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "任务未启用"})
+			return
+		}
+
+		// 脚本等外部调用可通过 ?trigger=api 区分于页面上的手动执行
+		trigger := scheduler.TriggerManual
+		if c.Query("trigger") == scheduler.TriggerAPI {
+			trigger = scheduler.TriggerAPI
+		}
+
+		// 通过调度器立即执行任务（跳过间隔检查）
+		go func() {
+			if err := sched.RunNow(taskID, trigger); err != nil {
+				log.Printf("[runPushTaskHandler] 任务 %d 执行失败: %v", taskID, err)
+			} else {
+				log.Printf("[runPushTaskHandler] 任务 %d 手动执行成功", taskID)
+			}
+		}()
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "任务立即执行已开始",
+			"task_id": taskID,
+		})
+	}
+}
+
+// PushTaskPreviewReq 预览请求：task_id 不为 0 时预览已保存的任务，否则按请求中未保存的任务配置预览
+type PushTaskPreviewReq struct {
+	PushTaskReq
+	TaskID  int64  `json:"task_id"`
+	Channel string `json:"channel"` // 按哪种渠道渲染消息体，默认 feishu
+}
+
+// POST /api/push_task/preview => 执行查询并返回卡片内容，不发送
+func previewPushTask(c *gin.Context) {
+	var req PushTaskPreviewReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if enabled != 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "任务未启用"})
+	db, err := database.SetupDB("./data/app.db")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 使用scheduler包中的ForceRunSingleTaskPush函数执行任务（跳过间隔检查）
-	go func() {
-		if err := scheduler.ForceRunSingleTaskPush(db, taskID); err != nil {
-			log.Printf("[runPushTaskHandler] 任务 %d 执行失败: %v", taskID, err)
-		} else {
-			log.Printf("[runPushTaskHandler] 任务 %d 手动执行成功", taskID)
+	var def *scheduler.TaskDefinition
+	if req.TaskID > 0 {
+		def, err = scheduler.LoadTaskDefinition(db, req.TaskID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-	}()
+	} else {
+		def, err = taskDefinitionFromReq(db, &req.PushTaskReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "任务立即执行已开始",
-		"task_id": taskID,
-	})
+	preview, err := scheduler.PreviewTask(db, def, req.Channel)
+	if err != nil {
+		log.Printf("[previewPushTask] 预览失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
 }
 
-// initScheduler 初始化调度器
-func initScheduler(db *sql.DB) {
-	// 创建一个定时器，每分钟触发一次
-	ticker := time.NewTicker(1 * time.Minute)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				processPushTasks(db)
+// taskDefinitionFromReq 将未保存的任务配置转换为 TaskDefinition，默认值与创建任务时相同
+func taskDefinitionFromReq(db *sql.DB, req *PushTaskReq) (*scheduler.TaskDefinition, error) {
+	if req.SourceID == 0 {
+		return nil, fmt.Errorf("source_id is required")
+	}
+	timezone, err := normalizeTimezone(req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := encodeMentions(req.Mentions); err != nil {
+		return nil, err
+	}
+	if err := validatePromQLMentions(req.PromQLConfigs); err != nil {
+		return nil, err
+	}
+	if _, err := encodeButtons(req); err != nil {
+		return nil, err
+	}
+	if err := normalizePromQLLinks(req.PromQLConfigs); err != nil {
+		return nil, err
+	}
+	if err := validatePromQLStats(req.PromQLConfigs); err != nil {
+		return nil, err
+	}
+
+	def := &scheduler.TaskDefinition{
+		Name:              req.Name,
+		Enabled:           true,
+		SourceID:          req.SourceID,
+		TimeRange:         req.TimeRange,
+		Step:              float64(hoursToSeconds(req.Step)),
+		CardTitle:         req.CardTitle,
+		CardTemplate:      req.CardTemplate,
+		MetricLabel:       req.MetricLabel,
+		CustomMetricLabel: req.CustomMetricLabel,
+		Unit:              req.Unit,
+		Buttons:           parseButtonsOrEmpty("", req.ButtonText, req.ButtonURL),
+		ShowDataLabel:     req.ShowDataLabel,
+		Timezone:          timezone,
+	}
+	if req.Buttons != nil {
+		def.Buttons = *req.Buttons
+	}
+	if req.Mentions != nil {
+		def.Mentions = *req.Mentions
+	}
+	if def.Layout, err = resolveCardLayout(db, req.CardLayoutID); err != nil {
+		return nil, err
+	}
+	metricLabel := req.MetricLabel
+	if metricLabel == "" {
+		metricLabel = "pod"
+	}
+
+	switch {
+	case len(req.PromQLConfigs) > 0:
+		// 与保存后的任务一致，按 display_order 排序
+		configs := append([]PromQLConfig(nil), req.PromQLConfigs...)
+		sort.SliceStable(configs, func(i, j int) bool { return configs[i].DisplayOrder < configs[j].DisplayOrder })
+		for _, config := range configs {
+			q, err := promqlTaskQuery(db, config.PromQLID)
+			if err != nil {
+				return nil, err
+			}
+			q.Unit = config.Unit
+			q.MetricLabel = config.MetricLabel
+			if q.MetricLabel == "" {
+				q.MetricLabel = metricLabel
+			}
+			q.CustomMetricLabel = config.CustomMetricLabel
+			q.ChartTemplateID = config.ChartTemplateID
+			if q.ChartTemplateID == 0 {
+				q.ChartTemplateID = req.ChartTemplateID
+			}
+			q.InitialUnit = config.InitialUnit
+			q.DisplayOrder = config.DisplayOrder
+			q.DisplayMode = config.DisplayMode
+			if q.DisplayMode == "" {
+				q.DisplayMode = "chart"
+			}
+			if config.Mentions != nil {
+				q.Mentions = *config.Mentions
+			}
+			if config.Link != nil {
+				q.Link = *config.Link
 			}
+			if config.Stats != nil {
+				q.Stats = *config.Stats
+			}
+			def.AddQuery(q)
+		}
+	case len(req.PromQLIDs) > 0:
+		for _, promqlID := range req.PromQLIDs {
+			q, err := promqlTaskQuery(db, promqlID)
+			if err != nil {
+				return nil, err
+			}
+			q.ChartTemplateID = req.ChartTemplateID
+			q.Unit = req.Unit
+			q.MetricLabel = metricLabel
+			q.CustomMetricLabel = req.CustomMetricLabel
+			q.DisplayMode = "chart"
+			def.AddQuery(q)
+		}
+	default:
+		queries := req.Queries
+		if len(queries) == 0 && req.Query != "" {
+			queries = []QueryItem{{Query: req.Query, ChartTemplateID: req.ChartTemplateID}}
+		}
+		for _, item := range queries {
+			def.AddQuery(scheduler.TaskQuery{
+				Query:             item.Query,
+				ChartTemplateID:   item.ChartTemplateID,
+				Unit:              req.Unit,
+				MetricLabel:       req.MetricLabel,
+				CustomMetricLabel: req.CustomMetricLabel,
+				DisplayMode:       "chart",
+			})
 		}
-	}()
-	log.Printf("[scheduler] Scheduler initialized and running every minute")
+	}
+	if len(def.Queries) == 0 {
+		return nil, fmt.Errorf("query is required")
+	}
+	return def, nil
 }
 
-// RegisterRoutes: 主路由注册
-func RegisterRoutes(r *gin.Engine) {
-	// 初始化数据库连接
-	db := database.GetDB()
-
-	// 启动调度器
-	initScheduler(db)
+// promqlTaskQuery 读取 PromQL 的查询语句、名称和指定的数据源
+func promqlTaskQuery(db *sql.DB, promqlID int64) (scheduler.TaskQuery, error) {
+	var q scheduler.TaskQuery
+	err := db.QueryRow("SELECT query, name, COALESCE(source_id, 0) FROM promql WHERE id = ?", promqlID).
+		Scan(&q.Query, &q.PromQLName, &q.SourceID)
+	if err == sql.ErrNoRows {
+		return q, fmt.Errorf("PromQL %d 不存在", promqlID)
+	}
+	return q, err
+}
 
+// RegisterRoutes: 主路由注册
+// sched 为 main 中创建的调度器，路由只使用其状态查询和立即执行能力，不负责启动
+func RegisterRoutes(r *gin.Engine, sched *scheduler.Scheduler) {
 	// 静态文件服务 - 仅处理 /assets 路径
 	r.Static("/assets", "./web/assets")
 
@@ -2043,11 +2869,18 @@ func RegisterRoutes(r *gin.Engine) {
 
 		// 只读路由
 		authGroup.GET("/metrics_source", getMetricsSources)
+		authGroup.GET("/metrics_source/:id/health", checkMetricsSourceHealth)
+		authGroup.GET("/metrics_source/:id/label/:name/values", getMetricsSourceLabelValues)
 		authGroup.GET("/feishu_webhook", getFeishuWebhooks)
+		authGroup.GET("/channel", getFeishuWebhooks) // feishu_webhook 的通用别名，包含所有渠道类型
+		authGroup.GET("/channel/types", getChannelTypes)
 		authGroup.GET("/push_task", getAllPushTasks)
+		authGroup.GET("/push_task/:id/runs", getPushTaskRuns)
 		authGroup.GET("/chart_template", getChartTemplates)
+		authGroup.GET("/card_layout", getCardLayouts)
 		authGroup.GET("/promqls", getPromQLs)
 		authGroup.GET("/send_records", handler.HandleGetSendRecords)
+		authGroup.GET("/scheduler/status", schedulerStatusHandler(sched))
 	}
 
 	// 需要管理员权限的路由组 - 仅 admin 可访问（写操作）
@@ -2063,13 +2896,19 @@ func RegisterRoutes(r *gin.Engine) {
 		adminGroup.POST("/feishu_webhook", createFeishuWebhook)
 		adminGroup.PUT("/feishu_webhook/:id", updateFeishuWebhook)
 		adminGroup.DELETE("/feishu_webhook/:id", deleteFeishuWebhook)
+		adminGroup.POST("/channel", createFeishuWebhook)
+		adminGroup.PUT("/channel/:id", updateFeishuWebhook)
+		adminGroup.DELETE("/channel/:id", deleteFeishuWebhook)
+		adminGroup.POST("/feishu_webhook/:id/test", testFeishuWebhook)
+		adminGroup.POST("/channel/:id/test", testFeishuWebhook)
 
 		// push_task 写操作
 		adminGroup.POST("/push_task", createPushTask)
 		adminGroup.PUT("/push_task/:id", updatePushTask)
 		adminGroup.PUT("/push_task/:id/toggle", togglePushTask)
 		adminGroup.DELETE("/push_task/:id", deletePushTask)
-		adminGroup.POST("/push_task/:id/run", runPushTaskHandler)
+		adminGroup.POST("/push_task/:id/run", runPushTaskHandler(sched))
+		adminGroup.POST("/push_task/preview", previewPushTask)
 
 		// push_task_webhook 写操作
 		adminGroup.POST("/push_task_webhook", createPushTaskWebhook)
@@ -2080,6 +2919,11 @@ func RegisterRoutes(r *gin.Engine) {
 		adminGroup.PUT("/chart_template/:id", updateChartTemplate)
 		adminGroup.DELETE("/chart_template/:id", deleteChartTemplate)
 
+		// 卡片布局
+		adminGroup.POST("/card_layout", createCardLayout)
+		adminGroup.PUT("/card_layout/:id", updateCardLayout)
+		adminGroup.DELETE("/card_layout/:id", deleteCardLayout)
+
 		// PromQL 写操作
 		adminGroup.POST("/promql", createPromQL)
 		adminGroup.PUT("/promql/:id", updatePromQL)
@@ -2176,87 +3020,15 @@ func parseTimeRange(timeRange string) time.Duration {
 	return duration
 }
 
-// processPushTasks 处理所有已启用的任务
-func processPushTasks(db *sql.DB) {
-	now := time.Now()
-	log.Printf("[scheduler] %s processPushTasks start...", now.Format("2006/01/02 15:04:05"))
-
-	// 获取所有已启用的任务，使用 COALESCE 处理 NULL 值
-	rows, err := db.Query(`
-        SELECT pt.id, pt.name, pt.enabled, 
-               COALESCE(pt.last_run_at, '') as last_run_at,
-               GROUP_CONCAT(COALESCE(pts.weekday || ' ' || pts.send_time, '')) as send_times
-        FROM push_task pt
-        LEFT JOIN push_task_send_time pts ON pt.id = pts.task_id
-        WHERE pt.enabled = 1
-        GROUP BY pt.id
-    `)
-	if err != nil {
-		log.Printf("[scheduler] Error querying enabled tasks: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	// 当前时间信息
-	currentWeekday := int(now.Weekday())
-	currentTime := now.Format("15:04")
-
-	log.Printf("[scheduler] Current time: %s, weekday: %d", currentTime, currentWeekday)
-
-	// 遍历所有启用的任务
-	for rows.Next() {
-		var task struct {
-			ID        int64
-			Name      string
-			Enabled   int
-			LastRunAt string
-			SendTimes string
-		}
-		if err := rows.Scan(&task.ID, &task.Name, &task.Enabled, &task.LastRunAt, &task.SendTimes); err != nil {
-			log.Printf("[scheduler] Error scanning task row: %v", err)
-			continue
-		}
-
-		log.Printf("[scheduler] Processing task ID=%d (%s)", task.ID, task.Name)
-		log.Printf("[scheduler]   - Last Run: %s", task.LastRunAt)
-		log.Printf("[scheduler]   - Send Times: %s", task.SendTimes)
-
-		// 检查是否应该在当前时间执行
-		shouldExecute := false
-		if task.SendTimes != "" {
-			for _, timeStr := range strings.Split(task.SendTimes, ",") {
-				if timeStr == "" {
-					continue
-				}
-				parts := strings.Split(strings.TrimSpace(timeStr), " ")
-				if len(parts) != 2 {
-					continue
-				}
-
-				weekday, err := strconv.Atoi(parts[0])
-				if err != nil {
-					continue
-				}
-
-				sendTime := parts[1]
-				// 如果是当前星期几且时间匹配，则执行
-				if weekday == currentWeekday && sendTime == currentTime {
-					shouldExecute = true
-					log.Printf("[scheduler] Task ID=%d scheduled for current time (weekday=%d, time=%s)",
-						task.ID, weekday, sendTime)
-					break
-				}
-			}
-		}
-
-		if !shouldExecute {
-			continue
+// schedulerStatusHandler 返回调度器状态：已注册任务、下一次执行时间及运行状态
+func schedulerStatusHandler(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := sched.Status()
+		if err != nil {
+			log.Printf("[schedulerStatusHandler] 获取调度器状态失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-
-		log.Printf("[scheduler] Executing task ID=%d (%s)", task.ID, task.Name)
-		// 使用scheduler包中的函数，确保任务执行有互斥控制
-		go scheduler.RunSingleTaskPush(db, task.ID)
+		c.JSON(http.StatusOK, status)
 	}
-
-	log.Printf("[scheduler] processPushTasks completed")
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	statik "github.com/rakyll/statik/fs"
 
+	"fsvchart-notify/internal/scheduler"
 	_ "fsvchart-notify/statik" // Import statik package
 )
 
@@ -66,7 +67,8 @@ func detectContentType(path string) string {
 }
 
 // NewServer 初始化并返回 *http.Server
-func NewServer(addr string, port int) *http.Server {
+// sched 由调用方创建并负责启动和停止
+func NewServer(addr string, port int, sched *scheduler.Scheduler) *http.Server {
 	// 默认 gin.ReleaseMode
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
@@ -78,7 +80,7 @@ func NewServer(addr string, port int) *http.Server {
 	})
 
 	// 注册 API 路由
-	RegisterRoutes(r)
+	RegisterRoutes(r, sched)
 
 	// 嵌入的静态资源
 	statikFS, err := statik.New()
@@ -2,7 +2,11 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"fsvchart-notify/internal/models"
 	"io/ioutil"
@@ -11,7 +15,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -153,8 +156,57 @@ var httpClient = &http.Client{
 // 最大重试次数
 const maxRetries = 3
 
+// permanentError 不需要重试的错误，见 Permanent
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记 err 不需要重试，RetryWithBackoff 收到后立即返回原始错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// RetryWithBackoff 执行 fn，失败时最多重试 maxRetries 次，第 n 次重试前等待 n*2 秒
+// name 用于日志前缀，attempt 从 0 开始；fn 返回 Permanent 包装的错误时不再重试
+// 等待期间 ctx 取消时立即返回，错误中包含最后一次失败的原因
+func RetryWithBackoff(ctx context.Context, name string, fn func(attempt int) error) error {
+	var lastErr error
+	for retry := 0; retry < maxRetries; retry++ {
+		if retry > 0 {
+			log.Printf("[%s] Retry attempt %d/%d after error: %v", name, retry, maxRetries, lastErr)
+			// 重试前等待一段时间，避免立即重试
+			select {
+			case <-ctx.Done():
+				log.Printf("[%s] Retry canceled: %v", name, ctx.Err())
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(time.Duration(retry) * 2 * time.Second):
+			}
+		}
+		if lastErr = fn(retry); lastErr == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(lastErr, &permanent) {
+			log.Printf("[%s] Non-retryable error: %v", name, permanent.err)
+			return permanent.err
+		}
+	}
+
+	// 所有重试都失败了，返回最后一个错误
+	log.Printf("[%s] All %d retry attempts failed, last error: %v", name, maxRetries, lastErr)
+	return lastErr
+}
+
 // SendFeishuCardMessage 用于发送任意自定义的 FeishuCard
-func SendFeishuCardMessage(webhookURL string, card *FeishuCard) error {
+// secret 为机器人的签名密钥，为空时不签名；ctx 取消时中止请求和重试等待
+func SendFeishuCardMessage(ctx context.Context, webhookURL, secret string, card *FeishuCard) error {
 	payload, err := json.Marshal(card)
 	if err != nil {
 		log.Printf("[SendFeishuCardMessage] JSON marshal error: %v", err)
@@ -165,20 +217,18 @@ func SendFeishuCardMessage(webhookURL string, card *FeishuCard) error {
 	log.Printf("[SendFeishuCardMessage] Sending to webhook URL: %s, payload size: %d bytes", webhookURL, len(payload))
 
 	// 添加重试逻辑
-	var lastErr error
-	for retry := 0; retry < maxRetries; retry++ {
-		if retry > 0 {
-			log.Printf("[SendFeishuCardMessage] Retry attempt %d/%d after error: %v", retry, maxRetries, lastErr)
-			// 重试前等待一段时间，避免立即重试
-			time.Sleep(time.Duration(retry) * 2 * time.Second)
+	return RetryWithBackoff(ctx, "SendFeishuCardMessage", func(retry int) error {
+		// 每次发送都重新签名，使用当前的时间戳
+		body, err := signFeishuPayload(payload, secret)
+		if err != nil {
+			return err
 		}
 
 		// 构造 POST 请求
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(body))
 		if err != nil {
 			log.Printf("[SendFeishuCardMessage] Create request error: %v", err)
-			lastErr = fmt.Errorf("create request error: %w", err)
-			continue
+			return fmt.Errorf("create request error: %w", err)
 		}
 		req.Header.Set("Content-Type", "application/json")
 
@@ -187,8 +237,7 @@ func SendFeishuCardMessage(webhookURL string, card *FeishuCard) error {
 		resp, err := httpClient.Do(req)
 		if err != nil {
 			log.Printf("[SendFeishuCardMessage] HTTP post error: %v", err)
-			lastErr = fmt.Errorf("http post error: %w", err)
-			continue
+			return fmt.Errorf("http post error: %w", err)
 		}
 
 		// 确保响应体被关闭
@@ -205,55 +254,39 @@ func SendFeishuCardMessage(webhookURL string, card *FeishuCard) error {
 		if err := json.Unmarshal(bodyBytes, &feishuResp); err == nil {
 			if feishuResp.Code != 0 {
 				log.Printf("[SendFeishuCardMessage] Feishu API returned error code: %d, message: %s", feishuResp.Code, feishuResp.Msg)
-				lastErr = fmt.Errorf("feishu API error: code=%d, msg=%s", feishuResp.Code, feishuResp.Msg)
+				if err := feishuSignError(feishuResp.Code, feishuResp.Msg); err != nil {
+					return err
+				}
 				// 如果是飞书API错误，继续重试
-				continue
+				return fmt.Errorf("feishu API error: code=%d, msg=%s", feishuResp.Code, feishuResp.Msg)
 			}
 			log.Printf("[SendFeishuCardMessage] Feishu API success: code=%d", feishuResp.Code)
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("feishu webhook returned status=%d, body=%s", resp.StatusCode, string(bodyBytes))
-			continue
+			return fmt.Errorf("feishu webhook returned status=%d, body=%s", resp.StatusCode, string(bodyBytes))
 		}
 
 		// 成功发送，返回nil
 		log.Printf("[SendFeishuCardMessage] Successfully sent message to webhook")
 		return nil
-	}
+	})
+}
 
-	// 所有重试都失败了，返回最后一个错误
-	log.Printf("[SendFeishuCardMessage] All %d retry attempts failed, last error: %v", maxRetries, lastErr)
-	return lastErr
+// PayloadHash 计算消息体的 SHA-256 摘要，用于在运行记录中识别实际发送的内容
+func PayloadHash(payload interface{}) string {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // =====================
 // 3. 构建并发送示例卡片
 // =====================
 
-var sendRecords []models.SendRecord
-var recordMutex sync.Mutex
-
-// AddSendRecord 添加发送记录
-func AddSendRecord(record models.SendRecord) {
-	recordMutex.Lock()
-	defer recordMutex.Unlock()
-
-	// 保持最近1000条记录
-	if len(sendRecords) >= 1000 {
-		sendRecords = sendRecords[1:]
-	}
-	sendRecords = append(sendRecords, record)
-}
-
-// GetSendRecords 获取发送记录
-func GetSendRecords() []models.SendRecord {
-	recordMutex.Lock()
-	defer recordMutex.Unlock()
-
-	return sendRecords
-}
-
 // GetSupportedChartType 获取飞书支持的图表类型
 func GetSupportedChartType(chartType string) string {
 	// 飞书支持的图表类型
@@ -290,15 +323,28 @@ func GetSupportedChartType(chartType string) string {
 	return "line"
 }
 
-// SendFeishuStandardChart 严格按照飞书官方文档构建图表消息
-func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDataPoints, cardTitle, cardTemplate, unit, buttonText, buttonURL string, showDataLabel bool) error {
-	// 添加发送前的日志
-	log.Printf("[SendFeishuStandardChart] 准备发送消息到 webhook: %s", webhookURL)
-	log.Printf("[SendFeishuStandardChart] 标题: %s, 系列数量: %d", cardTitle, len(queryDataPoints))
+// BuildFeishuStandardChart 构建图表卡片消息体，不发送
+// loc 为图表横轴及日期分组使用的时区，为 nil 时使用服务器本地时区
+// mentions 为任务级 @ 配置，显示在卡片底部；查询级 @ 配置来自 QueryDataPoints.Mentions，显示在对应图表标题之后
+func BuildFeishuStandardChart(queryDataPoints []models.QueryDataPoints, cardTitle, cardTemplate, unit string, buttons []models.Button, showDataLabel bool, loc *time.Location, mentions []models.Mention) (map[string]interface{}, error) {
+	loc = locationOr(loc, time.Local)
+
+	log.Printf("[BuildFeishuStandardChart] 标题: %s, 系列数量: %d", cardTitle, len(queryDataPoints))
 
 	// 检查参数
 	if len(queryDataPoints) == 0 {
-		return fmt.Errorf("no data points provided")
+		return nil, fmt.Errorf("no data points provided")
+	}
+
+	// 在补全缺失时间点之前记录各查询每个序列的最新值，用于 @ 的阈值判断
+	latestValues := make(map[string][]float64)
+	var allValues []float64
+	for _, qdp := range queryDataPoints {
+		values := latestSeriesValues(qdp.DataPoints)
+		if _, ok := latestValues[qdp.ChartTitle]; !ok {
+			latestValues[qdp.ChartTitle] = values
+			allValues = append(allValues, values...)
+		}
 	}
 
 	// 对每个查询的数据点进行预处理
@@ -350,7 +396,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			}
 		}
 
-		log.Printf("[SendFeishuStandardChart] 时间跨度: %.1f 小时, 是否多天: %v, 时间间隔: %d 秒",
+		log.Printf("[BuildFeishuStandardChart] 时间跨度: %.1f 小时, 是否多天: %v, 时间间隔: %d 秒",
 			timeSpanHours, isMultiDay, interval)
 
 		// 处理每个类型的数据点
@@ -358,7 +404,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		for seriesType, points := range typeGroups {
 			if isMultiDay {
 				// 多天查询：不补充缺失的时间点，只使用实际数据点
-				log.Printf("[SendFeishuStandardChart] 多天查询：跳过数据点补全，直接使用 %d 个实际数据点", len(points))
+				log.Printf("[BuildFeishuStandardChart] 多天查询：跳过数据点补全，直接使用 %d 个实际数据点", len(points))
 				processedPoints = append(processedPoints, points...)
 			} else {
 				// 单天查询：补充缺失的时间点以保证图表连续性
@@ -376,7 +422,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 					} else {
 						// 补充缺失的点，值设为0
 						processedPoints = append(processedPoints, models.DataPoint{
-							Time:     time.Unix(t, 0).Format("15:04"),
+							Time:     time.Unix(t, 0).In(loc).Format("15:04"),
 							UnixTime: t,
 							Value:    0,
 							Type:     seriesType,
@@ -384,7 +430,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 						pointsAdded++
 					}
 				}
-				log.Printf("[SendFeishuStandardChart] 单天查询：补充了 %d 个缺失的时间点", pointsAdded)
+				log.Printf("[BuildFeishuStandardChart] 单天查询：补充了 %d 个缺失的时间点", pointsAdded)
 			}
 		}
 
@@ -398,7 +444,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 		// 更新处理后的数据点
 		queryDataPoints[i].DataPoints = processedPoints
-		log.Printf("[SendFeishuStandardChart] 查询 %d 最终数据点数量: %d", i+1, len(processedPoints))
+		log.Printf("[BuildFeishuStandardChart] 查询 %d 最终数据点数量: %d", i+1, len(processedPoints))
 	}
 
 	// 对数据进行去重
@@ -411,11 +457,11 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			seenSeries[qdp.ChartTitle] = true
 			uniqueDataPoints = append(uniqueDataPoints, qdp)
 		} else {
-			log.Printf("[SendFeishuStandardChart] 跳过重复的系列: %s", qdp.ChartTitle)
+			log.Printf("[BuildFeishuStandardChart] 跳过重复的系列: %s", qdp.ChartTitle)
 		}
 	}
 
-	log.Printf("[SendFeishuStandardChart] 去重后的系列数量: %d", len(uniqueDataPoints))
+	log.Printf("[BuildFeishuStandardChart] 去重后的系列数量: %d", len(uniqueDataPoints))
 
 	// 使用去重后的数据点继续处理
 	queryDataPoints = uniqueDataPoints
@@ -426,13 +472,6 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 	if cardTemplate == "" {
 		cardTemplate = "blue"
 	}
-	// 设置默认按钮文本和URL
-	if buttonText == "" {
-		buttonText = "节点池资源总览"
-	}
-	if buttonURL == "" {
-		buttonURL = "https://grafana.deeproute.cn/d/aede74qtud2iod/e88a82-e782b9-e6b1a0-e8b584-e6ba90-e7bb9f-e8aea1?orgId=1"
-	}
 
 	// 改进多天数据检测逻辑
 	isMultiDayData := true // 默认添加日期前缀
@@ -450,7 +489,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		for time, count := range timeCount {
 			if count > 1 {
 				hasDuplicateTimes = true
-				log.Printf("[SendFeishuStandardChart] 检测到时间点 '%s' 重复出现 %d 次，确认为多天数据",
+				log.Printf("[BuildFeishuStandardChart] 检测到时间点 '%s' 重复出现 %d 次，确认为多天数据",
 					time, count)
 				break
 			}
@@ -473,7 +512,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			uniqueTimes := len(timeCount)
 			if uniqueTimes <= 3 && len(queryDataPoints[0].DataPoints) > uniqueTimes {
 				possibleMultiDay = true
-				log.Printf("[SendFeishuStandardChart] 检测到固定时间格式且点数(%d)>唯一时间数(%d)，判断为多天数据",
+				log.Printf("[BuildFeishuStandardChart] 检测到固定时间格式且点数(%d)>唯一时间数(%d)，判断为多天数据",
 					len(queryDataPoints[0].DataPoints), uniqueTimes)
 			}
 		}
@@ -481,7 +520,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		// 如果检测到了重复时间点或符合多天特征，则强制设置为多天数据
 		if hasDuplicateTimes || possibleMultiDay {
 			isMultiDayData = true
-			log.Printf("[SendFeishuStandardChart] 基于时间点重复或数据特征，强制设置为多天数据")
+			log.Printf("[BuildFeishuStandardChart] 基于时间点重复或数据特征，强制设置为多天数据")
 		} else {
 			// 只有在没有其他明显多天特征的情况下，才参考时间跨度
 			// 计算时间范围长度作为额外参考
@@ -498,14 +537,14 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			}
 
 			hoursDiff := (maxTime - minTime) / 3600
-			log.Printf("[SendFeishuStandardChart] 数据时间跨度: %d小时", hoursDiff)
+			log.Printf("[BuildFeishuStandardChart] 数据时间跨度: %d小时", hoursDiff)
 
 			// 只有在时间跨度小于20小时（而不是之前的24小时）且无重复时间点时，才不添加日期
 			if hoursDiff < 20 && !hasDuplicateTimes && !possibleMultiDay {
 				isMultiDayData = false
-				log.Printf("[SendFeishuStandardChart] 时间跨度小于20小时且无多天特征，不添加日期前缀")
+				log.Printf("[BuildFeishuStandardChart] 时间跨度小于20小时且无多天特征，不添加日期前缀")
 			} else {
-				log.Printf("[SendFeishuStandardChart] 时间跨度>=20小时，添加日期前缀")
+				log.Printf("[BuildFeishuStandardChart] 时间跨度>=20小时，添加日期前缀")
 			}
 		}
 	}
@@ -549,12 +588,12 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 	// 如果所有查询都没有数据，添加一个全局无数据提示
 	if allEmpty && len(queryDataPoints) > 0 {
-		log.Printf("[SendFeishuStandardChart] 所有查询均无数据，添加全局无数据提示")
+		log.Printf("[BuildFeishuStandardChart] 所有查询均无数据，添加全局无数据提示")
 
 		// 记录更详细的诊断信息
-		log.Printf("[SendFeishuStandardChart] 诊断信息:")
+		log.Printf("[BuildFeishuStandardChart] 诊断信息:")
 		for i, queryData := range queryDataPoints {
-			log.Printf("[SendFeishuStandardChart]   - 查询 %d: 标题='%s', 类型='%s', 数据点数=0",
+			log.Printf("[BuildFeishuStandardChart]   - 查询 %d: 标题='%s', 类型='%s', 数据点数=0",
 				i+1, queryData.ChartTitle, queryData.ChartType)
 		}
 
@@ -564,7 +603,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		})
 
 		// 添加提示的时间和查询信息
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		timestamp := time.Now().In(loc).Format("2006-01-02 15:04:05")
 		elements = append(elements, map[string]interface{}{
 			"tag":     "markdown",
 			"content": fmt.Sprintf("*查询时间: %s*", timestamp),
@@ -580,13 +619,19 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 	for i, queryData := range queryDataPoints {
 		if len(queryData.DataPoints) == 0 {
 			// 记录该查询无数据的详细信息
-			log.Printf("[SendFeishuStandardChart] 查询 '%s' 无数据，添加无数据提示", queryData.ChartTitle)
+			log.Printf("[BuildFeishuStandardChart] 查询 '%s' 无数据，添加无数据提示", queryData.ChartTitle)
 
 			// 添加查询标题和无数据提示
 			elements = append(elements, map[string]interface{}{
 				"tag":     "markdown",
-				"content": fmt.Sprintf("**%s**\n", queryData.ChartTitle),
+				"content": fmt.Sprintf("**%s**%s\n", queryData.ChartTitle, linkSuffix(queryData.Link)),
 			})
+			if at := mentionMarkdown(queryData.Mentions, nil); at != "" {
+				elements = append(elements, map[string]interface{}{
+					"tag":     "markdown",
+					"content": at,
+				})
+			}
 
 			// 添加无数据提示信息
 			elements = append(elements, map[string]interface{}{
@@ -595,7 +640,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			})
 
 			// 显示查询时间
-			timestamp := time.Now().Format("2006-01-02 15:04:05")
+			timestamp := time.Now().In(loc).Format("2006-01-02 15:04:05")
 			elements = append(elements, map[string]interface{}{
 				"tag":     "markdown",
 				"content": fmt.Sprintf("*查询时间: %s*", timestamp),
@@ -615,8 +660,14 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		// 添加查询标题 - 使用飞书支持的格式
 		elements = append(elements, map[string]interface{}{
 			"tag":     "markdown",
-			"content": fmt.Sprintf("**%s**\n", queryData.ChartTitle),
+			"content": fmt.Sprintf("**%s**%s\n", queryData.ChartTitle, linkSuffix(queryData.Link)),
 		})
+		if at := mentionMarkdown(queryData.Mentions, latestValues[queryData.ChartTitle]); at != "" {
+			elements = append(elements, map[string]interface{}{
+				"tag":     "markdown",
+				"content": at,
+			})
+		}
 
 		// 组织数据点
 		seriesData := make(map[string][]models.DataPoint)
@@ -626,7 +677,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		for range queryData.DataPoints {
 			rawDataPointCount++
 		}
-		log.Printf("[SendFeishuStandardChart] 系列共有 %d 个原始数据点", rawDataPointCount)
+		log.Printf("[BuildFeishuStandardChart] 系列共有 %d 个原始数据点", rawDataPointCount)
 
 		// 收集所有数据点的日期，检查是否真的有多天数据
 		datesFound := make(map[string]bool)
@@ -636,7 +687,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		unixTimeDateMap := make(map[int64]string)
 
 		for _, dp := range queryData.DataPoints {
-			dateStr := time.Unix(dp.UnixTime, 0).Format("2006-01-02")
+			dateStr := time.Unix(dp.UnixTime, 0).In(loc).Format("2006-01-02")
 			datesFound[dateStr] = true
 			unixTimeDateMap[dp.UnixTime] = dateStr
 
@@ -648,42 +699,42 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 
 		uniqueDatesCount := len(datesFound)
-		log.Printf("[SendFeishuStandardChart] 检测到 %d 个不同的日期: %v",
+		log.Printf("[BuildFeishuStandardChart] 检测到 %d 个不同的日期: %v",
 			uniqueDatesCount, datesFound)
 
 		// 如果日期数量异常少（例如6天查询只有2天数据），检查是否应该有更多日期
 		if durationDays := len(queryData.DataPoints) / 10; durationDays > uniqueDatesCount {
-			log.Printf("[SendFeishuStandardChart] 警告: 数据点数量(%d)表明应有~%d天数据，但只找到%d天",
+			log.Printf("[BuildFeishuStandardChart] 警告: 数据点数量(%d)表明应有~%d天数据，但只找到%d天",
 				len(queryData.DataPoints), durationDays, uniqueDatesCount)
 
 			// 输出所有数据点的日期分布
 			dateCounts := make(map[string]int)
 			for _, dp := range queryData.DataPoints {
-				date := time.Unix(dp.UnixTime, 0).Format("2006-01-02")
+				date := time.Unix(dp.UnixTime, 0).In(loc).Format("2006-01-02")
 				dateCounts[date]++
 			}
 
-			log.Printf("[SendFeishuStandardChart] 数据点日期分布详情:")
+			log.Printf("[BuildFeishuStandardChart] 数据点日期分布详情:")
 			for date, count := range dateCounts {
 				log.Printf(" - 日期 %s: %d 个数据点", date, count)
 			}
 
 			// 检查是否只有较早的日期
-			now := time.Now()
+			now := time.Now().In(loc)
 			today := now.Format("2006-01-02")
 			yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
 
 			if datesFound[today] || datesFound[yesterday] {
-				log.Printf("[SendFeishuStandardChart] 数据包含最近日期(今天或昨天)")
+				log.Printf("[BuildFeishuStandardChart] 数据包含最近日期(今天或昨天)")
 			} else {
-				log.Printf("[SendFeishuStandardChart] 警告: 数据不包含最近日期(今天:%s 昨天:%s)",
+				log.Printf("[BuildFeishuStandardChart] 警告: 数据不包含最近日期(今天:%s 昨天:%s)",
 					today, yesterday)
 
 				// 记录最近的日期，以便诊断
 				var latestDate string
 				latestTime := time.Time{}
 				for dateStr := range datesFound {
-					d, _ := time.Parse("2006-01-02", dateStr)
+					d, _ := time.ParseInLocation("2006-01-02", dateStr, loc)
 					if d.After(latestTime) {
 						latestTime = d
 						latestDate = dateStr
@@ -692,7 +743,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 				if latestDate != "" {
 					daysSinceLatest := int(now.Sub(latestTime).Hours() / 24)
-					log.Printf("[SendFeishuStandardChart] 最近的日期是 %s (距今 %d 天)",
+					log.Printf("[BuildFeishuStandardChart] 最近的日期是 %s (距今 %d 天)",
 						latestDate, daysSinceLatest)
 				}
 			}
@@ -700,17 +751,17 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 		// 如果日期数量异常少（5天查询应该至少有3-5天数据），输出详细日志
 		if len(queryData.DataPoints) > uniqueDatesCount*3 {
-			log.Printf("[SendFeishuStandardChart] 警告: 数据点数量(%d)远大于日期数量(%d)的3倍，可能存在日期检测问题",
+			log.Printf("[BuildFeishuStandardChart] 警告: 数据点数量(%d)远大于日期数量(%d)的3倍，可能存在日期检测问题",
 				len(queryData.DataPoints), uniqueDatesCount)
 
 			// 输出所有数据点的日期分布
 			dateCounts := make(map[string]int)
 			for _, dp := range queryData.DataPoints {
-				date := time.Unix(dp.UnixTime, 0).Format("2006-01-02")
+				date := time.Unix(dp.UnixTime, 0).In(loc).Format("2006-01-02")
 				dateCounts[date]++
 			}
 
-			log.Printf("[SendFeishuStandardChart] 数据点日期分布详情:")
+			log.Printf("[BuildFeishuStandardChart] 数据点日期分布详情:")
 			for date, count := range dateCounts {
 				log.Printf(" - 日期 %s: %d 个数据点", date, count)
 			}
@@ -719,18 +770,18 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		// 判断是否保持多天设置
 		// 注意：以下逻辑已调整为优先考虑特征检测而不仅是日期数量
 		if uniqueDatesCount <= 1 && !isMultiDayData {
-			log.Printf("[SendFeishuStandardChart] 实际只有一天的日期且无多天特征，不添加日期前缀")
+			log.Printf("[BuildFeishuStandardChart] 实际只有一天的日期且无多天特征，不添加日期前缀")
 			isMultiDayData = false
 		} else if isMultiDayData {
-			log.Printf("[SendFeishuStandardChart] 检测到多天数据特征，强制启用日期前缀")
+			log.Printf("[BuildFeishuStandardChart] 检测到多天数据特征，强制启用日期前缀")
 		} else {
-			log.Printf("[SendFeishuStandardChart] 检测到 %d 天的数据，添加日期前缀", uniqueDatesCount)
+			log.Printf("[BuildFeishuStandardChart] 检测到 %d 天的数据，添加日期前缀", uniqueDatesCount)
 			isMultiDayData = true
 		}
 
 		// 记录处理前的总数据点数量，用于后续验证
 		totalOriginalPoints := len(queryData.DataPoints)
-		log.Printf("[SendFeishuStandardChart] 处理前总数据点数量: %d", totalOriginalPoints)
+		log.Printf("[BuildFeishuStandardChart] 处理前总数据点数量: %d", totalOriginalPoints)
 
 		// 记录每种类型的时间点，用于检查可能的重复
 		timePointsByType := make(map[string]map[string]bool)
@@ -747,7 +798,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 				dpCopy := dp
 
 				// 提取实际日期（从时间戳中获取）
-				realDate := time.Unix(dp.UnixTime, 0).Format("01/02")
+				realDate := time.Unix(dp.UnixTime, 0).In(loc).Format("01/02")
 
 				// 检查是否所有时间点都有相同的时间戳日期（这是一种异常情况）
 				// 主检测条件：如果只有一个唯一日期但检测到了多天数据特征
@@ -769,13 +820,13 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 				// 检查是否有时间点重复但时间戳日期相同的情况
 				sameTimestampDates := uniqueDatesCount <= 1 && (hasTimeRepetition || len(queryData.DataPoints) > len(timeCounts))
 
-				// log.Printf("[SendFeishuStandardChart] 处理数据点: Type=%s, Time=%s, UnixTime=%d, 实际日期=%s",
+				// log.Printf("[BuildFeishuStandardChart] 处理数据点: Type=%s, Time=%s, UnixTime=%d, 实际日期=%s",
 				// 	dp.Type, dp.Time, dp.UnixTime, realDate)
 
 				// 生成不同的虚拟日期以区分数据点
 				// 这种情况是数据有同一天的时间戳但逻辑上是多天的数据
 				if sameTimestampDates {
-					log.Printf("[SendFeishuStandardChart] 检测到时间戳日期相同但时间点重复的情况，使用改进的虚拟日期生成")
+					log.Printf("[BuildFeishuStandardChart] 检测到时间戳日期相同但时间点重复的情况，使用改进的虚拟日期生成")
 
 					// 收集所有有效的时间戳，按时间顺序排序
 					var allTimestamps []int64
@@ -795,7 +846,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 					// 记录排序后的时间戳顺序
 					if len(allTimestamps) > 0 {
-						log.Printf("[SendFeishuStandardChart] 时间戳排序: 共 %d 个时间戳", len(allTimestamps))
+						log.Printf("[BuildFeishuStandardChart] 时间戳排序: 共 %d 个时间戳", len(allTimestamps))
 						showCount := 5
 						if len(allTimestamps) < showCount {
 							showCount = len(allTimestamps)
@@ -803,7 +854,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 						for i := 0; i < showCount; i++ {
 							ts := allTimestamps[i]
 							log.Printf(" - 第%d个时间戳: %d (%s)",
-								i+1, ts, time.Unix(ts, 0).Format("2006-01-02 15:04:05"))
+								i+1, ts, time.Unix(ts, 0).In(loc).Format("2006-01-02 15:04:05"))
 						}
 					}
 
@@ -838,34 +889,34 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 						if len(allTimestamps) > pointIndex {
 							realTS := allTimestamps[pointIndex]
 							// 使用实际时间戳对应的日期，而不是加偏移
-							realDate := time.Unix(realTS, 0).Format("01/02")
+							realDate := time.Unix(realTS, 0).In(loc).Format("01/02")
 
-							log.Printf("[SendFeishuStandardChart] 使用实际时间戳日期: 位置=%d, 时间戳=%d, 实际日期=%s",
+							log.Printf("[BuildFeishuStandardChart] 使用实际时间戳日期: 位置=%d, 时间戳=%d, 实际日期=%s",
 								pointIndex, realTS, realDate)
 
 							// 如果原始格式是时间，添加日期前缀
 							if len(dp.Time) == 5 && dp.Time[2] == ':' {
 								dpCopy.Time = realDate + " " + dp.Time
-								log.Printf("[SendFeishuStandardChart] 添加真实日期前缀: %s -> %s", dp.Time, dpCopy.Time)
+								log.Printf("[BuildFeishuStandardChart] 添加真实日期前缀: %s -> %s", dp.Time, dpCopy.Time)
 							} else if !strings.Contains(dp.Time, realDate) {
 								// 对于其他格式，也添加日期前缀
 								dpCopy.Time = realDate + " " + dp.Time
 							}
 						} else {
 							// 2. 如果没有足够多的时间戳，才使用原来的偏移方法（不应该走到这个逻辑）
-							log.Printf("[SendFeishuStandardChart] 警告: 使用回退的虚拟日期生成方法")
+							log.Printf("[BuildFeishuStandardChart] 警告: 使用回退的虚拟日期生成方法")
 
-							baseTime := time.Unix(dp.UnixTime, 0)
+							baseTime := time.Unix(dp.UnixTime, 0).In(loc)
 							virtualDate := baseTime.AddDate(0, 0, pointIndex)
 							date := virtualDate.Format("01/02")
 
-							log.Printf("[SendFeishuStandardChart] 为重复时间点生成虚拟日期: 位置=%d, 原始日期=%s, 虚拟日期=%s",
+							log.Printf("[BuildFeishuStandardChart] 为重复时间点生成虚拟日期: 位置=%d, 原始日期=%s, 虚拟日期=%s",
 								pointIndex, realDate, date)
 
 							// 如果原始格式是时间，添加日期前缀
 							if len(dp.Time) == 5 && dp.Time[2] == ':' {
 								dpCopy.Time = date + " " + dp.Time
-								log.Printf("[SendFeishuStandardChart] 添加虚拟日期前缀: %s -> %s", dp.Time, dpCopy.Time)
+								log.Printf("[BuildFeishuStandardChart] 添加虚拟日期前缀: %s -> %s", dp.Time, dpCopy.Time)
 							} else if !strings.Contains(dp.Time, date) {
 								// 对于其他格式，也添加日期前缀
 								dpCopy.Time = date + " " + dp.Time
@@ -875,7 +926,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 						// 无法确定点的位置，使用默认日期前缀
 						if len(dp.Time) == 5 && dp.Time[2] == ':' {
 							dpCopy.Time = realDate + " " + dp.Time
-							log.Printf("[SendFeishuStandardChart] 添加默认日期前缀: %s -> %s", dp.Time, dpCopy.Time)
+							log.Printf("[BuildFeishuStandardChart] 添加默认日期前缀: %s -> %s", dp.Time, dpCopy.Time)
 						}
 					}
 				} else {
@@ -883,12 +934,12 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 					// 如果原始格式是时间，添加日期前缀
 					if len(dp.Time) == 5 && dp.Time[2] == ':' {
 						dpCopy.Time = realDate + " " + dp.Time
-						log.Printf("[SendFeishuStandardChart] 添加日期前缀: %s -> %s", dp.Time, dpCopy.Time)
+						log.Printf("[BuildFeishuStandardChart] 添加日期前缀: %s -> %s", dp.Time, dpCopy.Time)
 					} else {
 						// 如果时间已经有格式，检查是否已包含日期
 						if !strings.Contains(dp.Time, realDate) {
 							// 没有包含正确的日期，尝试添加
-							log.Printf("[SendFeishuStandardChart] 时间格式已有, 但不包含正确日期: %s, 添加前缀: %s", dp.Time, realDate)
+							log.Printf("[BuildFeishuStandardChart] 时间格式已有, 但不包含正确日期: %s, 添加前缀: %s", dp.Time, realDate)
 							dpCopy.Time = realDate + " " + dp.Time
 						}
 					}
@@ -896,7 +947,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 				// 检查该类型是否已经有相同的时间点
 				if timePointsByType[dp.Type][dpCopy.Time] {
-					log.Printf("[SendFeishuStandardChart] 警告: 系列 '%s' 中发现重复的时间点 '%s' (Unix: %d)，生成唯一标识",
+					log.Printf("[BuildFeishuStandardChart] 警告: 系列 '%s' 中发现重复的时间点 '%s' (Unix: %d)，生成唯一标识",
 						dp.Type, dpCopy.Time, dp.UnixTime)
 					// 为避免重复，添加Unix时间戳后缀
 					dpCopy.Time = fmt.Sprintf("%s.%d", dpCopy.Time, dp.UnixTime)
@@ -929,22 +980,22 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 
 		// 对比原始和最终数据点数量，详细记录差异
-		log.Printf("[SendFeishuStandardChart] 系列 '%s' 原始数据点: %d, 处理后数据点: %d, 差异: %d",
+		log.Printf("[BuildFeishuStandardChart] 系列 '%s' 原始数据点: %d, 处理后数据点: %d, 差异: %d",
 			queryData.ChartTitle, rawDataPointCount, finalPointCount, rawDataPointCount-finalPointCount)
 
 		if finalPointCount < rawDataPointCount {
-			log.Printf("[SendFeishuStandardChart] 警告: 处理后数据点少于原始数据点，可能有合并或丢失")
+			log.Printf("[BuildFeishuStandardChart] 警告: 处理后数据点少于原始数据点，可能有合并或丢失")
 
 			// 检查是否有某些日期的数据被全部丢失
 			processedDates := make(map[string]bool)
 			for _, points := range seriesData {
 				for _, p := range points {
-					date := time.Unix(p.UnixTime, 0).Format("2006-01-02")
+					date := time.Unix(p.UnixTime, 0).In(loc).Format("2006-01-02")
 					processedDates[date] = true
 				}
 			}
 
-			log.Printf("[SendFeishuStandardChart] 处理后保留了 %d/%d 个日期",
+			log.Printf("[BuildFeishuStandardChart] 处理后保留了 %d/%d 个日期",
 				len(processedDates), uniqueDatesCount)
 
 			// 找出丢失的日期
@@ -956,7 +1007,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			}
 
 			if len(missingDates) > 0 {
-				log.Printf("[SendFeishuStandardChart] 以下日期的数据点被完全丢失: %v", missingDates)
+				log.Printf("[BuildFeishuStandardChart] 以下日期的数据点被完全丢失: %v", missingDates)
 			}
 		}
 
@@ -977,7 +1028,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			seriesPointCounts[seriesType] = len(seriesData[seriesType])
 		}
 
-		log.Printf("[SendFeishuStandardChart] 各系列数据点统计:")
+		log.Printf("[BuildFeishuStandardChart] 各系列数据点统计:")
 		for seriesType, count := range seriesPointCounts {
 			log.Printf(" - 系列 '%s': %d 个数据点", seriesType, count)
 		}
@@ -992,12 +1043,12 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		if len(seriesPointCounts) > 0 {
 			avgPointCount = float64(totalPoints) / float64(len(seriesPointCounts))
 
-			log.Printf("[SendFeishuStandardChart] 平均每个系列有 %.1f 个数据点", avgPointCount)
+			log.Printf("[BuildFeishuStandardChart] 平均每个系列有 %.1f 个数据点", avgPointCount)
 
 			// 检查不平衡的系列
 			for seriesType, count := range seriesPointCounts {
 				if float64(count) < avgPointCount*0.7 {
-					log.Printf("[SendFeishuStandardChart] 警告: 系列 '%s' 的数据点数量(%d)明显少于平均值(%.1f)",
+					log.Printf("[BuildFeishuStandardChart] 警告: 系列 '%s' 的数据点数量(%d)明显少于平均值(%.1f)",
 						seriesType, count, avgPointCount)
 				}
 			}
@@ -1029,10 +1080,10 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 		// 输出各系列的时间范围
 		if len(seriesTimeRanges) > 0 {
-			log.Printf("[SendFeishuStandardChart] 各系列的时间范围:")
+			log.Printf("[BuildFeishuStandardChart] 各系列的时间范围:")
 			for seriesType, timeRange := range seriesTimeRanges {
-				minTimeStr := time.Unix(timeRange[0], 0).Format("2006-01-02 15:04:05")
-				maxTimeStr := time.Unix(timeRange[1], 0).Format("2006-01-02 15:04:05")
+				minTimeStr := time.Unix(timeRange[0], 0).In(loc).Format("2006-01-02 15:04:05")
+				maxTimeStr := time.Unix(timeRange[1], 0).In(loc).Format("2006-01-02 15:04:05")
 				log.Printf(" - 系列 '%s': %s ~ %s", seriesType, minTimeStr, maxTimeStr)
 			}
 		}
@@ -1046,10 +1097,10 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 			// 记录排序后的日期顺序，以便验证数据完整性
 			if len(points) > 0 {
-				// log.Printf("[SendFeishuStandardChart] 系列 '%s' 的时间点日期顺序:", seriesType)
+				// log.Printf("[BuildFeishuStandardChart] 系列 '%s' 的时间点日期顺序:", seriesType)
 				dateOrder := []string{}
 				for _, p := range points {
-					dateStr := time.Unix(p.UnixTime, 0).Format("2006-01-02")
+					dateStr := time.Unix(p.UnixTime, 0).In(loc).Format("2006-01-02")
 					dateOrder = append(dateOrder, dateStr)
 				}
 				// 只显示不重复的日期顺序
@@ -1068,9 +1119,9 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			var chartPoints []map[string]interface{}
 
 			// 输出每个数据点的详细信息用于调试
-			// log.Printf("[SendFeishuStandardChart] 处理系列 '%s' 的数据点:", seriesType)
+			// log.Printf("[BuildFeishuStandardChart] 处理系列 '%s' 的数据点:", seriesType)
 			for j, p := range points {
-				// log.Printf("[SendFeishuStandardChart]   - 点 %d: Time='%s', UnixTime=%d, Value=%f",
+				// log.Printf("[BuildFeishuStandardChart]   - 点 %d: Time='%s', UnixTime=%d, Value=%f",
 				// 	j+1, p.Time, p.UnixTime, p.Value)
 
 				// 确保每个数据点被正确添加到chartPoints
@@ -1085,7 +1136,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 				})
 			}
 
-			// log.Printf("[SendFeishuStandardChart] 系列 '%s' 最终生成了 %d 个图表数据点",
+			// log.Printf("[BuildFeishuStandardChart] 系列 '%s' 最终生成了 %d 个图表数据点",
 			// 	seriesType, len(chartPoints))
 
 			chartData = append(chartData, map[string]interface{}{
@@ -1097,7 +1148,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		if currentUnit == "" {
 			currentUnit = unit
 		}
-		log.Printf("[SendFeishuStandardChart] 系列 '%s' 使用单位: '%s' (queryData.Unit='%s', task.unit='%s')",
+		log.Printf("[BuildFeishuStandardChart] 系列 '%s' 使用单位: '%s' (queryData.Unit='%s', task.unit='%s')",
 			queryData.ChartTitle, currentUnit, queryData.Unit, unit)
 
 		// 添加系列配置 - 只使用飞书支持的类型
@@ -1184,7 +1235,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 							}
 
 							uniquePointCount := len(uniqueTimePoints)
-							log.Printf("[SendFeishuStandardChart] 图表共有 %d 个唯一时间点, 总计 %d 个数据点",
+							log.Printf("[BuildFeishuStandardChart] 图表共有 %d 个唯一时间点, 总计 %d 个数据点",
 								uniquePointCount, totalPoints)
 
 							if isMultiDayData {
@@ -1274,6 +1325,15 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 		elements = append(elements, chartElement)
 
+		// 添加序列统计表格（如果配置），与图表一致优先使用该查询的独立单位
+		statsUnit := queryData.Unit
+		if statsUnit == "" {
+			statsUnit = unit
+		}
+		if table := FeishuStatsTable(queryData.DataPoints, queryData.Stats, statsUnit); table != nil {
+			elements = append(elements, table)
+		}
+
 		// 如果不是最后一个查询，添加分隔线
 		if i < len(queryDataPoints)-1 {
 			elements = append(elements, map[string]interface{}{
@@ -1282,27 +1342,24 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 	}
 
+	// 添加任务级 @（如果有生效的配置）
+	if at := mentionMarkdown(mentions, allValues); at != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag":     "markdown",
+			"content": at,
+		})
+	}
+
 	// 添加底部元素
 	// 先添加分割线
 	elements = append(elements, map[string]interface{}{
 		"tag": "hr",
 	})
 
-	// 添加按钮
-	elements = append(elements, map[string]interface{}{
-		"tag": "action",
-		"actions": []map[string]interface{}{
-			{
-				"tag": "button",
-				"text": map[string]interface{}{
-					"content": buttonText,
-					"tag":     "plain_text",
-				},
-				"type": "primary",
-				"url":  buttonURL,
-			},
-		},
-	})
+	// 添加按钮（如果提供）
+	if action := FeishuActionElement(buttons, "primary"); action != nil {
+		elements = append(elements, action)
+	}
 
 	// 添加原来的底部元素
 	elements = append(elements, map[string]interface{}{
@@ -1310,7 +1367,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		"elements": []map[string]interface{}{
 			{
 				"tag":     "lark_md",
-				"content": "DeepRoute.ai " + time.Now().Format("2006-01-02 15:04:05"),
+				"content": "DeepRoute.ai " + time.Now().In(loc).Format("2006-01-02 15:04:05"),
 			},
 		},
 	})
@@ -1320,7 +1377,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 	// // 打印最终的卡片数据用于调试
 	// debugData, _ := json.MarshalIndent(cardData, "", "  ")
-	// log.Printf("[SendFeishuStandardChart] 完整卡片数据:\n%s", string(debugData))
+	// log.Printf("[BuildFeishuStandardChart] 完整卡片数据:\n%s", string(debugData))
 
 	// 添加图表数据统计和诊断信息，增强问题排查能力
 	if chartConfig, ok := cardData["card"].(map[string]interface{})["elements"].([]map[string]interface{}); ok {
@@ -1344,7 +1401,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 
 		// 输出图表数据统计
 		if len(chartDataPoints) > 0 || len(chartSeriesItems) > 0 {
-			log.Printf("[SendFeishuStandardChart] 图表数据统计: %d个系列, %d个数据点",
+			log.Printf("[BuildFeishuStandardChart] 图表数据统计: %d个系列, %d个数据点",
 				len(chartSeriesItems), len(chartDataPoints))
 
 			// 检查数据点和日期格式
@@ -1356,7 +1413,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 			}
 
 			if len(timeFormats) > 0 {
-				log.Printf("[SendFeishuStandardChart] 图表中的日期前缀统计:")
+				log.Printf("[BuildFeishuStandardChart] 图表中的日期前缀统计:")
 				for prefix, count := range timeFormats {
 					log.Printf(" - 日期前缀 '%s': %d个数据点", prefix, count)
 				}
@@ -1370,7 +1427,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 					sampleCount = maxSamplePoints
 				}
 
-				log.Printf("[SendFeishuStandardChart] 前%d个数据点示例:", sampleCount)
+				log.Printf("[BuildFeishuStandardChart] 前%d个数据点示例:", sampleCount)
 				for i := 0; i < sampleCount; i++ {
 					dataJSON, _ := json.Marshal(chartDataPoints[i])
 					log.Printf(" - 数据点%d: %s", i+1, string(dataJSON))
@@ -1379,12 +1436,6 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 	}
 
-	// 直接使用 HTTP 请求发送到飞书
-	jsonData, err := json.Marshal(cardData)
-	if err != nil {
-		return fmt.Errorf("JSON编码错误: %w", err)
-	}
-
 	// 在发送前对系列数据进行去重
 	if elements, ok := cardData["card"].(map[string]interface{})["elements"].([]interface{}); ok {
 		for _, element := range elements {
@@ -1404,7 +1455,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 											seenSeries[name] = true
 											uniqueData = append(uniqueData, series)
 										} else {
-											log.Printf("[SendFeishuStandardChart] 跳过重复的系列: %s", name)
+											log.Printf("[BuildFeishuStandardChart] 跳过重复的系列: %s", name)
 										}
 									}
 								}
@@ -1417,7 +1468,19 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 	}
 
-	log.Printf("[SendFeishuStandardChart] 发送图表卡片 (长度: %d 字节) 到 webhook: %s", len(jsonData), webhookURL)
+	return cardData, nil
+}
+
+// PostFeishuChartPayload 发送已构建的图表卡片，遇到频率限制时按指数退避重试，不写入发送记录
+// secret 为机器人的签名密钥，为空时不签名；ctx 取消时中止请求和重试等待
+func PostFeishuChartPayload(ctx context.Context, webhookURL, secret string, cardData map[string]interface{}) error {
+	// 直接使用 HTTP 请求发送到飞书
+	jsonData, err := json.Marshal(cardData)
+	if err != nil {
+		return fmt.Errorf("JSON编码错误: %w", err)
+	}
+
+	log.Printf("[PostFeishuChartPayload] 发送图表卡片 (长度: %d 字节) 到 webhook: %s", len(jsonData), webhookURL)
 
 	// 重试逻辑
 	var lastErr error
@@ -1429,12 +1492,22 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		if retryCount > 0 {
 			// 使用指数退避策略，每次重试等待时间翻倍
 			waitTime := baseWaitTime * time.Duration(1<<uint(retryCount-1))
-			log.Printf("[SendFeishuStandardChart] 第%d次重试，等待 %v 后继续...", retryCount, waitTime)
-			time.Sleep(waitTime)
+			log.Printf("[PostFeishuChartPayload] 第%d次重试，等待 %v 后继续...", retryCount, waitTime)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (上次错误: %v)", ctx.Err(), lastErr)
+			case <-time.After(waitTime):
+			}
+		}
+
+		// 每次发送都重新签名，使用当前的时间戳
+		signedData, err := signFeishuPayload(jsonData, secret)
+		if err != nil {
+			return err
 		}
 
 		// 创建请求
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(signedData))
 		if err != nil {
 			lastErr = fmt.Errorf("创建请求错误: %w", err)
 			retryCount++
@@ -1454,7 +1527,7 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		// 读取响应
 		body, _ := ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
-		log.Printf("[SendFeishuStandardChart] 响应状态: %d, 内容: %s", resp.StatusCode, string(body))
+		log.Printf("[PostFeishuChartPayload] 响应状态: %d, 内容: %s", resp.StatusCode, string(body))
 
 		// 解析响应JSON
 		var result struct {
@@ -1476,6 +1549,11 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 				retryCount++
 				continue
 			}
+			if err := feishuSignError(result.Code, result.Msg); err != nil {
+				lastErr = err
+				retryCount++
+				continue
+			}
 
 			lastErr = fmt.Errorf("飞书API错误: code=%d, msg=%s", result.Code, result.Msg)
 			retryCount++
@@ -1483,59 +1561,35 @@ func SendFeishuStandardChart(webhookURL string, queryDataPoints []models.QueryDa
 		}
 
 		// 成功发送
-		log.Printf("[SendFeishuStandardChart] 发送成功！")
-
-		// 记录发送记录，添加按钮文本和按钮链接信息
-		AddSendRecord(models.SendRecord{
-			Timestamp:  time.Now(),
-			Status:     "success",
-			Message:    fmt.Sprintf("成功发送图表消息: %s (按钮: %s)", cardTitle, buttonText),
-			Webhook:    webhookURL,
-			TaskName:   cardTitle,
-			ButtonText: buttonText,
-			ButtonURL:  buttonURL,
-		})
-
+		log.Printf("[PostFeishuChartPayload] 发送成功！")
 		return nil
 	}
 
-	log.Printf("[SendFeishuStandardChart] 所有重试都失败，最后错误: %v", lastErr)
-
-	// 记录失败的发送记录
-	AddSendRecord(models.SendRecord{
-		Timestamp:  time.Now(),
-		Status:     "error",
-		Message:    fmt.Sprintf("发送失败: %v", lastErr),
-		Webhook:    webhookURL,
-		TaskName:   cardTitle,
-		ButtonText: buttonText,
-		ButtonURL:  buttonURL,
-	})
-
+	log.Printf("[PostFeishuChartPayload] 所有重试都失败，最后错误: %v", lastErr)
 	return lastErr
 }
 
-// SendFeishuTextCard 发送文本卡片消息到飞书
+// BuildFeishuTextCard 构建文本卡片
 // 此函数用于以纯文本格式展示多个 PromQL 查询的最新值
 //
 // 参数说明:
-//   - webhookURL: 飞书 webhook URL
 //   - promqlMetrics: 每个 PromQL 的最新指标值列表，格式为 map[promqlName][]LatestMetric
 //   - promqlConfigs: 每个 PromQL 的配置（单位、标签等）
 //   - cardTitle: 卡片标题
 //   - cardTemplate: 卡片颜色主题（"blue", "red", "green" 等）
-//   - buttonText: 按钮文本（可选）
-//   - buttonURL: 按钮链接（可选）
-func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetric, promqlConfigs map[string]struct {
+//   - buttons: 卡片底部的按钮（可选），链接已渲染模板
+//   - loc: 数据时间使用的时区，为 nil 时使用 ChinaTimezone
+//   - mentions: 任务级的 @ 配置，阈值与所有查询的最新值比较；PromQL 级的 @ 配置在 promqlConfigs 中
+func BuildFeishuTextCard(promqlMetrics map[string][]LatestMetric, promqlConfigs map[string]struct {
 	Name              string
 	Unit              string
 	MetricLabel       string
 	CustomMetricLabel string
 	InitialUnit       string
-}, promqlOrder []string, cardTitle, cardTemplate, buttonText, buttonURL string) error {
-	log.Printf("[SendFeishuTextCard] ====== START ======")
-	log.Printf("[SendFeishuTextCard] Webhook: %s, CardTitle: %s", webhookURL, cardTitle)
-	log.Printf("[SendFeishuTextCard] PromQL 显示顺序: %v", promqlOrder)
+	Mentions          []models.Mention
+	Link              string
+}, promqlOrder []string, cardTitle, cardTemplate string, buttons []models.Button, loc *time.Location, mentions []models.Mention) *FeishuCard {
+	log.Printf("[BuildFeishuTextCard] PromQL 显示顺序: %v", promqlOrder)
 
 	// 构建卡片
 	card := &FeishuCard{
@@ -1556,16 +1610,19 @@ func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetr
 		},
 	}
 
+	// 所有查询的最新值，用于任务级 @ 的阈值判断
+	var allValues []float64
+
 	// 按照指定的顺序为每个 PromQL 添加一个部分
 	for _, promqlName := range promqlOrder {
 		metrics, exists := promqlMetrics[promqlName]
 		if !exists {
-			log.Printf("[SendFeishuTextCard] Warning: PromQL '%s' not found in metrics", promqlName)
+			log.Printf("[BuildFeishuTextCard] Warning: PromQL '%s' not found in metrics", promqlName)
 			continue
 		}
 		config, hasConfig := promqlConfigs[promqlName]
 		if !hasConfig {
-			log.Printf("[SendFeishuTextCard] Warning: No config found for PromQL '%s'", promqlName)
+			log.Printf("[BuildFeishuTextCard] Warning: No config found for PromQL '%s'", promqlName)
 			continue
 		}
 
@@ -1577,7 +1634,7 @@ func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetr
 
 		card.Card.Elements = append(card.Card.Elements, FeishuCardElement{
 			Tag:     "markdown",
-			Content: titleText,
+			Content: titleText + linkSuffix(config.Link),
 		})
 
 		// 如果没有指标数据，显示无数据
@@ -1602,7 +1659,7 @@ func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetr
 				}
 
 				// 格式化值
-				valueStr := formatValue(metric.Value, config.Unit)
+				valueStr := FormatValue(metric.Value, config.Unit)
 
 				// 构建显示文本
 				displayText := fmt.Sprintf("%s %s: %s", prefix, metric.Label, valueStr)
@@ -1613,6 +1670,27 @@ func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetr
 				})
 			}
 		}
+
+		// 添加该 PromQL 的 @
+		values := make([]float64, 0, len(metrics))
+		for _, metric := range metrics {
+			values = append(values, metric.Value)
+		}
+		allValues = append(allValues, values...)
+		if at := mentionMarkdown(config.Mentions, values); at != "" {
+			card.Card.Elements = append(card.Card.Elements, FeishuCardElement{
+				Tag:     "markdown",
+				Content: at,
+			})
+		}
+	}
+
+	// 添加任务级的 @
+	if at := mentionMarkdown(mentions, allValues); at != "" {
+		card.Card.Elements = append(card.Card.Elements, FeishuCardElement{
+			Tag:     "markdown",
+			Content: at,
+		})
 	}
 
 	// 添加分割线
@@ -1621,44 +1699,26 @@ func SendFeishuTextCard(webhookURL string, promqlMetrics map[string][]LatestMetr
 	})
 
 	// 添加按钮（如果提供）
-	if buttonText != "" && buttonURL != "" {
+	if actions := feishuButtons(buttons, "default"); len(actions) > 0 {
 		card.Card.Elements = append(card.Card.Elements, FeishuCardElement{
-			Tag: "action",
-			Actions: []FeishuAction{
-				{
-					Tag: "button",
-					Text: &FeishuActionText{
-						Content: buttonText,
-						Tag:     "plain_text",
-					},
-					Type: "default",
-					URL:  buttonURL,
-				},
-			},
+			Tag:     "action",
+			Actions: actions,
 		})
 	}
 
 	// 添加数据采集时间
-	now := time.Now().In(ChinaTimezone)
+	now := time.Now().In(locationOr(loc, ChinaTimezone))
 	timeText := fmt.Sprintf("⏰ 数据时间: %s", now.Format("2006-01-02 15:04"))
 	card.Card.Elements = append(card.Card.Elements, FeishuCardElement{
 		Tag:     "markdown",
 		Content: timeText,
 	})
 
-	// 发送消息
-	err := SendFeishuCardMessage(webhookURL, card)
-	if err != nil {
-		log.Printf("[SendFeishuTextCard] Failed to send message: %v", err)
-		return err
-	}
-
-	log.Printf("[SendFeishuTextCard] ====== END ======")
-	return nil
+	return card
 }
 
-// formatValue 格式化值，添加单位
-func formatValue(value float64, unit string) string {
+// FormatValue 格式化值（保留两位小数并去掉多余的零），添加单位
+func FormatValue(value float64, unit string) string {
 	// 格式化为两位小数
 	valueStr := fmt.Sprintf("%.2f", value)
 
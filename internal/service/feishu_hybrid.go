@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"fsvchart-notify/internal/models"
@@ -17,6 +18,8 @@ type HybridElement struct {
 	DisplayOrder int
 	DisplayMode  string // "chart" 或 "text"
 	PromQLName   string
+	Query        string // 查询语句，供通用 WebHook 模板使用
+	Link         string // 查询的详情链接（已渲染模板），显示在查询标题后
 
 	// 图表相关
 	ChartData       *models.QueryDataPoints
@@ -27,22 +30,25 @@ type HybridElement struct {
 	TextMetrics []LatestMetric
 	Unit        string
 	MetricLabel string
+
+	// 该查询的 @ 配置，阈值与查询的最新值比较
+	Mentions []models.Mention
 }
 
-// SendFeishuHybridCard 发送混合卡片消息到飞书
+// BuildFeishuHybridCard 构建混合卡片消息体，不发送
 // 此函数用于在同一个卡片内混合展示图表和文本内容
 //
 // 参数说明:
-//   - webhookURL: 飞书 webhook URL
 //   - hybridElements: 混合元素列表（包含图表和文本），已按 display_order 排序
 //   - cardTitle: 卡片标题
 //   - cardTemplate: 卡片颜色主题（"blue", "red", "green" 等）
-//   - buttonText: 按钮文本（可选）
-//   - buttonURL: 按钮链接（可选）
-func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, cardTitle, cardTemplate, unit, buttonText, buttonURL string, showDataLabel bool) error {
-	log.Printf("[SendFeishuHybridCard] ====== START ======")
-	log.Printf("[SendFeishuHybridCard] Webhook: %s, CardTitle: %s", webhookURL, cardTitle)
-	log.Printf("[SendFeishuHybridCard] 混合元素数量: %d", len(hybridElements))
+//   - buttons: 卡片底部的按钮（可选），链接已渲染模板
+//   - loc: 图表横轴和卡片时间使用的时区，为 nil 时使用 ChinaTimezone
+//   - mentions: 任务级的 @ 配置，阈值与所有元素的最新值比较；查询级的 @ 配置在 HybridElement.Mentions 中
+func BuildFeishuHybridCard(hybridElements []HybridElement, cardTitle, cardTemplate, unit string, buttons []models.Button, showDataLabel bool, loc *time.Location, mentions []models.Mention) map[string]interface{} {
+	loc = locationOr(loc, ChinaTimezone)
+
+	log.Printf("[BuildFeishuHybridCard] 混合元素数量: %d", len(hybridElements))
 
 	// 优化排序：文本模式在上，图表模式在下，各自按 display_order 排序
 	sort.Slice(hybridElements, func(i, j int) bool {
@@ -66,7 +72,7 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 		return hybridElements[i].PromQLName < hybridElements[j].PromQLName
 	})
 
-	log.Printf("[SendFeishuHybridCard] 排序后顺序:")
+	log.Printf("[BuildFeishuHybridCard] 排序后顺序:")
 	for idx, elem := range hybridElements {
 		log.Printf("  %d. [%s] %s (order=%d)", idx+1, elem.DisplayMode, elem.PromQLName, elem.DisplayOrder)
 	}
@@ -94,33 +100,17 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 	elements := cardData["card"].(map[string]interface{})["elements"].([]interface{})
 
 	// 是否为多天数据（用于图表时间格式）
-	isMultiDayData := false
-	if len(hybridElements) > 0 {
-		for _, elem := range hybridElements {
-			if elem.DisplayMode == "chart" && elem.ChartData != nil && len(elem.ChartData.DataPoints) > 0 {
-				// 检查是否有多个不同日期
-				dates := make(map[string]bool)
-				for _, dp := range elem.ChartData.DataPoints {
-					date := time.Unix(dp.UnixTime, 0).In(ChinaTimezone).Format("01-02")
-					dates[date] = true
-					if len(dates) > 1 {
-						isMultiDayData = true
-						break
-					}
-				}
-				if isMultiDayData {
-					break
-				}
-			}
-		}
-	}
+	isMultiDayData := IsMultiDayData(hybridElements, loc)
 
-	log.Printf("[SendFeishuHybridCard] 是否多天数据: %v", isMultiDayData)
+	log.Printf("[BuildFeishuHybridCard] 是否多天数据: %v", isMultiDayData)
+
+	// 所有元素的最新值，用于任务级 @ 的阈值判断
+	var allValues []float64
 
 	// 按顺序添加元素，并在文本和图表之间添加额外分隔
 	var lastMode string
 	for idx, elem := range hybridElements {
-		log.Printf("[SendFeishuHybridCard] 处理元素 %d: %s (mode=%s, order=%d)", idx+1, elem.PromQLName, elem.DisplayMode, elem.DisplayOrder)
+		log.Printf("[BuildFeishuHybridCard] 处理元素 %d: %s (mode=%s, order=%d)", idx+1, elem.PromQLName, elem.DisplayMode, elem.DisplayOrder)
 
 		// 如果从文本模式切换到图表模式，添加分组标题
 		if lastMode == "text" && elem.DisplayMode == "chart" {
@@ -138,7 +128,17 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 			elements = appendTextElements(elements, elem)
 		} else if elem.DisplayMode == "chart" {
 			// 添加图表元素
-			elements = appendChartElements(elements, elem, isMultiDayData)
+			elements = appendChartElements(elements, elem, isMultiDayData, loc)
+		}
+
+		// 添加该查询的 @
+		values := elementValues(elem)
+		allValues = append(allValues, values...)
+		if at := mentionMarkdown(elem.Mentions, values); at != "" {
+			elements = append(elements, map[string]interface{}{
+				"tag":     "markdown",
+				"content": at,
+			})
 		}
 
 		// 在同类型元素之间添加小分隔线
@@ -155,27 +155,22 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 		lastMode = elem.DisplayMode
 	}
 
+	// 添加任务级的 @
+	if at := mentionMarkdown(mentions, allValues); at != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag":     "markdown",
+			"content": at,
+		})
+	}
+
 	// 添加底部分隔线
 	elements = append(elements, map[string]interface{}{
 		"tag": "hr",
 	})
 
 	// 添加按钮（如果提供）
-	if buttonText != "" && buttonURL != "" {
-		elements = append(elements, map[string]interface{}{
-			"tag": "action",
-			"actions": []map[string]interface{}{
-				{
-					"tag": "button",
-					"text": map[string]interface{}{
-						"content": buttonText,
-						"tag":     "plain_text",
-					},
-					"type": "default",
-					"url":  buttonURL,
-				},
-			},
-		})
+	if action := FeishuActionElement(buttons, "default"); action != nil {
+		elements = append(elements, action)
 	}
 
 	// 添加时间戳
@@ -184,7 +179,7 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 		"elements": []map[string]interface{}{
 			{
 				"tag":     "lark_md",
-				"content": "DeepRoute.ai " + time.Now().Format("2006-01-02 15:04:05"),
+				"content": "DeepRoute.ai " + time.Now().In(loc).Format("2006-01-02 15:04:05"),
 			},
 		},
 	})
@@ -192,38 +187,42 @@ func SendFeishuHybridCard(webhookURL string, hybridElements []HybridElement, car
 	// 更新卡片中的元素
 	cardData["card"].(map[string]interface{})["elements"] = elements
 
-	// 发送消息
-	err := SendFeishuCardMessageFromMap(webhookURL, cardData)
-	if err != nil {
-		log.Printf("[SendFeishuHybridCard] Failed to send message: %v", err)
-		
-		// 记录失败的发送记录
-		AddSendRecord(models.SendRecord{
-			Timestamp:  time.Now(),
-			Status:     "error",
-			Message:    fmt.Sprintf("发送失败: %v", err),
-			Webhook:    webhookURL,
-			TaskName:   cardTitle,
-			ButtonText: buttonText,
-			ButtonURL:  buttonURL,
-		})
-		
-		return err
-	}
+	return cardData
+}
 
-	// 记录成功的发送记录
-	AddSendRecord(models.SendRecord{
-		Timestamp:  time.Now(),
-		Status:     "success",
-		Message:    fmt.Sprintf("成功发送混合卡片消息: %s", cardTitle),
-		Webhook:    webhookURL,
-		TaskName:   cardTitle,
-		ButtonText: buttonText,
-		ButtonURL:  buttonURL,
-	})
+// IsMultiDayData 判断图表元素的数据是否跨越多天，跨天时图表横轴显示日期
+func IsMultiDayData(hybridElements []HybridElement, loc *time.Location) bool {
+	loc = locationOr(loc, ChinaTimezone)
+	for _, elem := range hybridElements {
+		if elem.DisplayMode != "chart" || elem.ChartData == nil {
+			continue
+		}
+		dates := make(map[string]bool)
+		for _, dp := range elem.ChartData.DataPoints {
+			dates[time.Unix(dp.UnixTime, 0).In(loc).Format("01-02")] = true
+			if len(dates) > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	log.Printf("[SendFeishuHybridCard] ====== END ======")
-	return nil
+// BuildFeishuElements 按混合卡片的样式构建单个元素的卡片元素（标题、文本列表或图表，以及查询级的 @），供自定义卡片布局使用
+func BuildFeishuElements(elem HybridElement, isMultiDayData bool, loc *time.Location) []interface{} {
+	var elements []interface{}
+	if elem.DisplayMode == "text" {
+		elements = appendTextElements(elements, elem)
+	} else {
+		elements = appendChartElements(elements, elem, isMultiDayData, locationOr(loc, ChinaTimezone))
+	}
+	if at := mentionMarkdown(elem.Mentions, elementValues(elem)); at != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag":     "markdown",
+			"content": at,
+		})
+	}
+	return elements
 }
 
 // appendTextElements 添加文本元素到卡片
@@ -236,7 +235,7 @@ func appendTextElements(elements []interface{}, elem HybridElement) []interface{
 
 	elements = append(elements, map[string]interface{}{
 		"tag":     "markdown",
-		"content": titleText,
+		"content": titleText + linkSuffix(elem.Link),
 	})
 
 	// 如果没有指标数据，显示无数据
@@ -263,7 +262,7 @@ func appendTextElements(elements []interface{}, elem HybridElement) []interface{
 		}
 
 		// 格式化值
-		valueStr := formatValue(metric.Value, elem.Unit)
+		valueStr := FormatValue(metric.Value, elem.Unit)
 
 		// 构建显示文本
 		displayText := fmt.Sprintf("%s %s: %s", prefix, metric.Label, valueStr)
@@ -278,12 +277,12 @@ func appendTextElements(elements []interface{}, elem HybridElement) []interface{
 }
 
 // appendChartElements 添加图表元素到卡片
-func appendChartElements(elements []interface{}, elem HybridElement, isMultiDayData bool) []interface{} {
+func appendChartElements(elements []interface{}, elem HybridElement, isMultiDayData bool, loc *time.Location) []interface{} {
 	if elem.ChartData == nil || len(elem.ChartData.DataPoints) == 0 {
 		// 添加无数据提示
 		elements = append(elements, map[string]interface{}{
 			"tag":     "markdown",
-			"content": fmt.Sprintf("**%s**", elem.PromQLName),
+			"content": fmt.Sprintf("**%s**", elem.PromQLName) + linkSuffix(elem.Link),
 		})
 		elements = append(elements, map[string]interface{}{
 			"tag":     "markdown",
@@ -299,7 +298,7 @@ func appendChartElements(elements []interface{}, elem HybridElement, isMultiDayD
 	}
 	elements = append(elements, map[string]interface{}{
 		"tag":     "markdown",
-		"content": titleText,
+		"content": titleText + linkSuffix(elem.Link),
 	})
 
 	// 处理数据点 - 按飞书标准图表格式
@@ -309,7 +308,7 @@ func appendChartElements(elements []interface{}, elem HybridElement, isMultiDayD
 
 	for _, dp := range elem.ChartData.DataPoints {
 		// 格式化时间
-		timeStr := formatTimeForChart(dp.UnixTime, isMultiDayData)
+		timeStr := formatTimeForChart(dp.UnixTime, isMultiDayData, loc)
 		allTimes[timeStr] = true
 		allSeries[dp.Type] = true
 
@@ -455,12 +454,17 @@ func appendChartElements(elements []interface{}, elem HybridElement, isMultiDayD
 
 	elements = append(elements, chartElement)
 
+	// 添加序列统计表格（如果配置）
+	if table := FeishuStatsTable(elem.ChartData.DataPoints, elem.ChartData.Stats, elem.ChartData.Unit); table != nil {
+		elements = append(elements, table)
+	}
+
 	return elements
 }
 
-// formatTimeForChart 格式化时间用于图表显示
-func formatTimeForChart(unixTime int64, isMultiDay bool) string {
-	t := time.Unix(unixTime, 0).In(ChinaTimezone)
+// formatTimeForChart 格式化时间用于图表显示，loc 为 nil 时使用 ChinaTimezone
+func formatTimeForChart(unixTime int64, isMultiDay bool, loc *time.Location) string {
+	t := time.Unix(unixTime, 0).In(locationOr(loc, ChinaTimezone))
 	if isMultiDay {
 		return t.Format("01-02 15:04")
 	}
@@ -468,7 +472,8 @@ func formatTimeForChart(unixTime int64, isMultiDay bool) string {
 }
 
 // SendFeishuCardMessageFromMap 从 map 发送飞书消息
-func SendFeishuCardMessageFromMap(webhookURL string, cardData map[string]interface{}) error {
+// secret 为机器人的签名密钥，为空时不签名；ctx 取消时中止请求和重试等待
+func SendFeishuCardMessageFromMap(ctx context.Context, webhookURL, secret string, cardData map[string]interface{}) error {
 	payload, err := json.Marshal(cardData)
 	if err != nil {
 		log.Printf("[SendFeishuCardMessageFromMap] JSON marshal error: %v", err)
@@ -482,11 +487,21 @@ func SendFeishuCardMessageFromMap(webhookURL string, cardData map[string]interfa
 	for retry := 0; retry < maxRetries; retry++ {
 		if retry > 0 {
 			log.Printf("[SendFeishuCardMessageFromMap] Retry attempt %d/%d", retry, maxRetries)
-			time.Sleep(time.Duration(retry) * 2 * time.Second)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(time.Duration(retry) * 2 * time.Second):
+			}
+		}
+
+		// 每次发送都重新签名，使用当前的时间戳
+		body, err := signFeishuPayload(payload, secret)
+		if err != nil {
+			return err
 		}
 
 		// 构造 POST 请求
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(body))
 		if err != nil {
 			lastErr = fmt.Errorf("create request error: %w", err)
 			continue
@@ -516,7 +531,10 @@ func SendFeishuCardMessageFromMap(webhookURL string, cardData map[string]interfa
 				log.Printf("[SendFeishuCardMessageFromMap] Message sent successfully")
 				return nil
 			}
-			lastErr = fmt.Errorf("feishu api error: code=%d, msg=%s", feishuResp.Code, feishuResp.Msg)
+			lastErr = feishuSignError(feishuResp.Code, feishuResp.Msg)
+			if lastErr == nil {
+				lastErr = fmt.Errorf("feishu api error: code=%d, msg=%s", feishuResp.Code, feishuResp.Msg)
+			}
 		} else {
 			lastErr = fmt.Errorf("failed to parse feishu response: %w", err)
 		}
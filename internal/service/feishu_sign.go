@@ -0,0 +1,49 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// feishuSignFailedCode 飞书机器人签名校验失败（签名错误或时间戳与服务器相差超过 1 小时）
+const feishuSignFailedCode = 19021
+
+// ErrFeishuSignature 飞书签名校验失败，发送记录中以 sign_error 状态单独标识
+var ErrFeishuSignature = errors.New("飞书签名校验失败")
+
+// FeishuSign 计算飞书自定义机器人的签名
+// sign = Base64(HmacSHA256(key: timestamp + "\n" + secret, data: 空))
+func FeishuSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(strconv.FormatInt(timestamp, 10)+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signFeishuPayload 为开启了签名校验的机器人在消息体中加入 timestamp 和 sign 字段
+// 每次发送（包括重试）都应重新调用，以使用当前的时间戳；secret 为空时原样返回
+func signFeishuPayload(payload []byte, secret string) ([]byte, error) {
+	if secret == "" {
+		return payload, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("签名时解析消息体失败: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	fields["timestamp"], _ = json.Marshal(strconv.FormatInt(timestamp, 10))
+	fields["sign"], _ = json.Marshal(FeishuSign(secret, timestamp))
+	return json.Marshal(fields)
+}
+
+// feishuSignError 飞书返回签名校验失败时返回包装了 ErrFeishuSignature 的错误，否则返回 nil
+func feishuSignError(code int, msg string) error {
+	if code != feishuSignFailedCode {
+		return nil
+	}
+	return fmt.Errorf("%w: code=%d, msg=%s", ErrFeishuSignature, code, msg)
+}
@@ -0,0 +1,35 @@
+package service
+
+// 飞书表格组件每页最多显示的行数
+const feishuTablePageSize = 10
+
+// FeishuTableColumn 返回飞书表格组件的文本列，align 为空时使用飞书默认的对齐方式
+func FeishuTableColumn(name, displayName, align string) map[string]interface{} {
+	column := map[string]interface{}{"name": name, "display_name": displayName, "data_type": "text"}
+	if align != "" {
+		column["horizontal_align"] = align
+	}
+	return column
+}
+
+// FeishuTable 返回飞书表格组件，行数超过 feishuTablePageSize 时分页显示
+func FeishuTable(columns, rows []interface{}) map[string]interface{} {
+	pageSize := len(rows)
+	if pageSize > feishuTablePageSize {
+		pageSize = feishuTablePageSize
+	}
+	if pageSize == 0 {
+		pageSize = 1
+	}
+	return map[string]interface{}{
+		"tag":        "table",
+		"page_size":  pageSize,
+		"row_height": "low",
+		"header_style": map[string]interface{}{
+			"bold":             true,
+			"background_style": "grey",
+		},
+		"columns": columns,
+		"rows":    rows,
+	}
+}
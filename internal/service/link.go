@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"fsvchart-notify/internal/models"
+)
+
+// LinkData 按钮和查询链接模板的数据
+type LinkData struct {
+	Start int64  // 查询时间范围的开始时间（Unix 毫秒），可直接用于 Grafana 的 from 参数
+	End   int64  // 查询时间范围的结束时间（Unix 毫秒），可直接用于 Grafana 的 to 参数
+	Range string // 时间范围的秒数，如 3600s，可用于 Prometheus 的 g0.range_input 参数
+	Query string // URL 编码后的查询语句，任务级按钮为空
+}
+
+// NewLinkData 构建链接模板的数据，query 为原始查询语句
+func NewLinkData(start, end time.Time, query string) LinkData {
+	return LinkData{
+		Start: start.UnixMilli(),
+		End:   end.UnixMilli(),
+		Range: fmt.Sprintf("%ds", int64(end.Sub(start).Seconds())),
+		Query: url.QueryEscape(query),
+	}
+}
+
+// RenderLink 渲染链接模板，不包含模板语法时原样返回
+func RenderLink(link string, data LinkData) (string, error) {
+	if !strings.Contains(link, "{{") {
+		return link, nil
+	}
+	tmpl, err := template.New("link").Option("missingkey=error").Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("链接模板格式无效: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染链接模板失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// ValidateLink 使用示例数据渲染链接模板，检查模板语法和链接格式
+func ValidateLink(link string) error {
+	end := time.Now()
+	rendered, err := RenderLink(link, NewLinkData(end.Add(-time.Hour), end, "up"))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(rendered, "http://") && !strings.HasPrefix(rendered, "https://") {
+		return fmt.Errorf("链接 %q 必须以 http:// 或 https:// 开头", link)
+	}
+	return nil
+}
+
+// ValidateButtons 校验按钮的文本、链接模板和类型
+func ValidateButtons(buttons []models.Button) error {
+	for i, b := range buttons {
+		if strings.TrimSpace(b.Text) == "" {
+			return fmt.Errorf("第 %d 个按钮的 text 不能为空", i+1)
+		}
+		if err := ValidateLink(b.URL); err != nil {
+			return fmt.Errorf("第 %d 个按钮: %w", i+1, err)
+		}
+		switch b.Type {
+		case "", "default", "primary", "danger":
+		default:
+			return fmt.Errorf("第 %d 个按钮的类型 %q 无效，可选值: default、primary、danger", i+1, b.Type)
+		}
+	}
+	return nil
+}
+
+// ParseButtons 解析任务的按钮列表，列表为空时使用旧的 button_text/button_url 字段作为唯一的按钮
+func ParseButtons(raw, legacyText, legacyURL string) ([]models.Button, error) {
+	var buttons []models.Button
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &buttons); err != nil {
+			return nil, fmt.Errorf("按钮配置格式无效: %w", err)
+		}
+	}
+	if len(buttons) == 0 && legacyText != "" && legacyURL != "" {
+		buttons = []models.Button{{Text: legacyText, URL: legacyURL}}
+	}
+	return buttons, nil
+}
+
+// RenderButtons 渲染按钮链接中的模板，渲染失败的按钮会被跳过
+func RenderButtons(buttons []models.Button, data LinkData) []models.Button {
+	rendered := make([]models.Button, 0, len(buttons))
+	for _, b := range buttons {
+		link, err := RenderLink(b.URL, data)
+		if err != nil {
+			log.Printf("[RenderButtons] 按钮 %s 的链接无效，已跳过: %v", b.Text, err)
+			continue
+		}
+		b.URL = link
+		rendered = append(rendered, b)
+	}
+	return rendered
+}
+
+// FirstButton 返回第一个按钮的文本和链接，用于发送记录
+func FirstButton(buttons []models.Button) (string, string) {
+	if len(buttons) == 0 {
+		return "", ""
+	}
+	return buttons[0].Text, buttons[0].URL
+}
+
+// feishuButtons 将按钮转换为飞书 action 组件中的按钮，未设置类型的按钮使用 defaultType
+func feishuButtons(buttons []models.Button, defaultType string) []FeishuAction {
+	var actions []FeishuAction
+	for _, b := range buttons {
+		if b.Text == "" || b.URL == "" {
+			continue
+		}
+		typ := b.Type
+		if typ == "" {
+			typ = defaultType
+		}
+		actions = append(actions, FeishuAction{
+			Tag:  "button",
+			Text: &FeishuActionText{Content: b.Text, Tag: "plain_text"},
+			Type: typ,
+			URL:  b.URL,
+		})
+	}
+	return actions
+}
+
+// FeishuActionElement 返回包含所有按钮的飞书 action 组件，没有有效按钮时返回 nil
+func FeishuActionElement(buttons []models.Button, defaultType string) map[string]interface{} {
+	actions := feishuButtons(buttons, defaultType)
+	if len(actions) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"tag":     "action",
+		"actions": actions,
+	}
+}
+
+// linkSuffix 返回查询标题后的详情链接 Markdown，没有链接时返回空字符串
+func linkSuffix(link string) string {
+	if link == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [详情](%s)", link)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fsvchart-notify/internal/models"
+)
+
+// mentionOps 支持的阈值比较运算符
+var mentionOps = []string{">", ">=", "<", "<=", "==", "!="}
+
+// ValidateMentions 校验 @ 配置，User 不能为空，Op 必须是支持的运算符
+func ValidateMentions(mentions []models.Mention) error {
+	for i, m := range mentions {
+		if strings.TrimSpace(m.User) == "" {
+			return fmt.Errorf("第 %d 个 @ 配置缺少 user", i+1)
+		}
+		if m.Op == "" {
+			continue
+		}
+		valid := false
+		for _, op := range mentionOps {
+			if m.Op == op {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("无效的比较运算符 %q，可选值: %s", m.Op, strings.Join(mentionOps, " "))
+		}
+	}
+	return nil
+}
+
+// ParseMentions 解析数据库中以 JSON 保存的 @ 配置，空字符串视为没有配置
+func ParseMentions(data string) ([]models.Mention, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var mentions []models.Mention
+	if err := json.Unmarshal([]byte(data), &mentions); err != nil {
+		return nil, fmt.Errorf("解析 @ 配置失败: %w", err)
+	}
+	return mentions, nil
+}
+
+// mentionTriggered 判断 @ 是否生效：未设置 Op 时始终生效，否则任一数值满足条件时生效
+func mentionTriggered(m models.Mention, values []float64) bool {
+	if m.Op == "" {
+		return true
+	}
+	for _, v := range values {
+		switch m.Op {
+		case ">":
+			if v > m.Threshold {
+				return true
+			}
+		case ">=":
+			if v >= m.Threshold {
+				return true
+			}
+		case "<":
+			if v < m.Threshold {
+				return true
+			}
+		case "<=":
+			if v <= m.Threshold {
+				return true
+			}
+		case "==":
+			if v == m.Threshold {
+				return true
+			}
+		case "!=":
+			if v != m.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mentionMarkdown 返回生效的 @ 对应的飞书 <at> 标签，没有生效的 @ 时返回空字符串
+// 包含 @ 的视为邮箱，其余按 open_id/user_id 处理，all 表示所有人
+func mentionMarkdown(mentions []models.Mention, values []float64) string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, m := range mentions {
+		user := strings.TrimSpace(m.User)
+		if user == "" || seen[user] || !mentionTriggered(m, values) {
+			continue
+		}
+		seen[user] = true
+		if strings.Contains(user, "@") {
+			tags = append(tags, fmt.Sprintf("<at email=%s></at>", user))
+		} else {
+			tags = append(tags, fmt.Sprintf("<at id=%s></at>", user))
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// MentionMarkdown 返回任务级 @ 的 Markdown 内容，阈值与所有元素的最新值比较；没有需要 @ 的对象时返回空字符串
+func MentionMarkdown(mentions []models.Mention, hybridElements []HybridElement) string {
+	var values []float64
+	for _, elem := range hybridElements {
+		values = append(values, elementValues(elem)...)
+	}
+	return mentionMarkdown(mentions, values)
+}
+
+// elementValues 返回用于阈值判断的数值：文本元素为各指标的最新值，图表元素为各序列的最新值
+func elementValues(elem HybridElement) []float64 {
+	if elem.DisplayMode != "chart" {
+		values := make([]float64, 0, len(elem.TextMetrics))
+		for _, m := range elem.TextMetrics {
+			values = append(values, m.Value)
+		}
+		return values
+	}
+	if elem.ChartData == nil {
+		return nil
+	}
+	return latestSeriesValues(elem.ChartData.DataPoints)
+}
+
+// latestSeriesValues 返回每个序列（DataPoint.Type）时间最晚的数据点的值
+func latestSeriesValues(points []models.DataPoint) []float64 {
+	latest := make(map[string]models.DataPoint)
+	for _, dp := range points {
+		if cur, ok := latest[dp.Type]; !ok || dp.UnixTime >= cur.UnixTime {
+			latest[dp.Type] = dp
+		}
+	}
+	values := make([]float64, 0, len(latest))
+	for _, dp := range latest {
+		values = append(values, dp.Value)
+	}
+	return values
+}
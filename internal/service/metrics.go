@@ -1,35 +1,22 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
-	"net/url"
-	"path"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"fsvchart-notify/internal/datasource"
 	"fsvchart-notify/internal/models"
 )
 
 // 使用中国时区 (GMT+8)
 var ChinaTimezone = time.FixedZone("GMT+8", 8*60*60)
 
-type VMQueryResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ResultType string `json:"resultType"`
-		Result     []struct {
-			Metric map[string]string `json:"metric"`
-			Values [][]interface{}   `json:"values"`
-		} `json:"result"`
-	} `json:"data"`
-}
 
 // DataPointSorter 用于排序数据点
 type DataPointSorter struct {
@@ -204,7 +191,7 @@ func roundToReasonableStep(stepHours float64) float64 {
 // FetchMetrics 从VictoriaMetrics获取指标数据并处理成前端可用的数据点格式
 //
 // 参数说明:
-//   - baseURL: 指标源的基础URL
+//   - source: 指标数据源，包含地址和认证配置
 //   - query: PromQL查询语句
 //   - start/end: 查询的时间范围
 //   - step: 数据点的时间间隔
@@ -213,7 +200,10 @@ func roundToReasonableStep(stepHours float64) float64 {
 //     例如，如果customLabel="resource"，则会从指标数据中提取"resource"标签对应的值（如"cpu"、"memory"、"gpu"等）
 //     作为DataPoint.Type。如果指标中不存在该标签的值，则会跳过该结果。
 //     此参数还会过滤数据点，确保只有customLabel对应的值会显示在图表上，而不显示其他标签的值（如team="mlp"）。
-func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duration, seriesType string, customLabel string, initialUnit string, targetUnit string) ([]models.DataPoint, error) {
+//   - loc: 横轴标签和多天查询按天对齐使用的时区，为 nil 时使用服务器本地时区
+func FetchMetrics(source models.MetricsSource, query string, start, end time.Time, step time.Duration, seriesType string, customLabel string, initialUnit string, targetUnit string, loc *time.Location) ([]models.DataPoint, error) {
+	loc = locationOr(loc, time.Local)
+
 	logMsg := fmt.Sprintf("[FetchMetrics] ====== START ======")
 	log.Print(logMsg)
 	GetLogManager().AddLog(logMsg)
@@ -268,13 +258,13 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 		log.Printf("[FetchMetrics] Query spans %d days, using calculated step: %v", durationDays, step)
 
 		// 使用当前时间作为结束时间，不进行截断，确保获取最新数据
-		now := time.Now()
+		now := time.Now().In(loc)
 		alignedEnd = now
 		log.Printf("[FetchMetrics] Using current time as end: %s", alignedEnd.Format("2006-01-02 15:04:05"))
 
 		// 计算开始时间：从结束时间向前推指定天数
 		alignedStart = alignedEnd.AddDate(0, 0, -durationDays)
-		// 确保开始时间对齐到当天00:00
+		// 确保开始时间对齐到任务时区当天00:00
 		alignedStart = time.Date(
 			alignedStart.Year(), alignedStart.Month(), alignedStart.Day(),
 			0, 0, 0, 0, alignedStart.Location(),
@@ -290,12 +280,10 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 		}
 	}
 
-	// 构建查询 URL
-	u, err := url.Parse(baseURL)
+	ds, err := datasource.New(source)
 	if err != nil {
 		return nil, err
 	}
-	u.Path = path.Join(u.Path, "/api/v1/query_range")
 
 	// 记录最终的查询参数
 	log.Printf("[FetchMetrics] Final query parameters:")
@@ -304,15 +292,6 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 	log.Printf("  - Step: %v", step)
 	log.Printf("  - Expected points per day: %.1f", 24*time.Hour.Hours()/step.Hours())
 
-	params := url.Values{}
-	params.Set("query", query)
-	params.Set("start", fmt.Sprintf("%d", alignedStart.Unix()))
-	params.Set("end", fmt.Sprintf("%d", alignedEnd.Unix()))
-	params.Set("step", fmt.Sprintf("%d", int64(step.Seconds())))
-	u.RawQuery = params.Encode()
-
-	log.Printf("[FetchMetrics] Requesting URL: %s", u.String())
-
 	// 记录详细的请求信息
 	requestDetails := fmt.Sprintf("QueryParams: query=%s, start=%s, end=%s, step=%s",
 		query,
@@ -321,32 +300,9 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 		step.String())
 	log.Printf("[FetchMetrics] Request details: %s", requestDetails)
 
-	resp, err := http.Get(u.String())
-	if err != nil {
-		log.Printf("[FetchMetrics] ERROR: HTTP request failed: %v", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
+	vmResp, err := ds.RangeQuery(context.Background(), query, alignedStart, alignedEnd, step)
 	if err != nil {
-		return nil, err
-	}
-
-	// 记录响应状态码
-	log.Printf("[FetchMetrics] Response status code: %d", resp.StatusCode)
-
-	// 如果响应过大，只记录前1000个字符
-	responsePreview := string(body)
-	if len(responsePreview) > 1000 {
-		responsePreview = responsePreview[:1000] + "... (truncated)"
-	}
-	log.Printf("[FetchMetrics] Response preview: %s", responsePreview)
-
-	// 解析 JSON
-	var vmResp VMQueryResponse
-	if err := json.Unmarshal(body, &vmResp); err != nil {
+		log.Printf("[FetchMetrics] ERROR: query failed: %v", err)
 		return nil, err
 	}
 
@@ -890,77 +846,49 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 				lastDataTime.Format("2006-01-02 15:04:05"),
 				currentTime.Format("2006-01-02 15:04:05"))
 			
-			// 使用 query API 获取当前值
-			currentValueURL, err := url.Parse(baseURL)
+			// 使用即时查询获取当前值
+			currentResp, err := ds.InstantQuery(context.Background(), query, time.Time{})
 			if err == nil {
-				currentValueURL.Path = path.Join(currentValueURL.Path, "/api/v1/query")
-				params := url.Values{}
-				params.Set("query", query)
-				currentValueURL.RawQuery = params.Encode()
+				log.Printf("[FetchMetrics] Successfully fetched current values, result count: %d", len(currentResp.Data.Result))
 				
-				resp, err := http.Get(currentValueURL.String())
-				if err == nil {
-					defer resp.Body.Close()
-					body, err := ioutil.ReadAll(resp.Body)
-					if err == nil {
-						// 定义 query API 的响应结构（返回 Value 而不是 Values）
-						var currentResp struct {
-							Status string `json:"status"`
-							Data   struct {
-								ResultType string `json:"resultType"`
-								Result     []struct {
-									Metric map[string]string `json:"metric"`
-									Value  []interface{}     `json:"value"` // [timestamp, value]
-								} `json:"result"`
-							} `json:"data"`
+				// 将当前值添加到 actualPoints
+				currentTimestamp := currentTime.Unix()
+				for _, result := range currentResp.Data.Result {
+					var labelValue string
+					if customLabel != "" {
+						if val, exists := result.Metric[customLabel]; exists {
+							labelValue = val
 						}
-						
-						if err := json.Unmarshal(body, &currentResp); err == nil {
-							if currentResp.Status == "success" {
-								log.Printf("[FetchMetrics] Successfully fetched current values, result count: %d", len(currentResp.Data.Result))
-								
-								// 将当前值添加到 actualPoints
-								currentTimestamp := currentTime.Unix()
-								for _, result := range currentResp.Data.Result {
-									var labelValue string
-									if customLabel != "" {
-										if val, exists := result.Metric[customLabel]; exists {
-											labelValue = val
-										}
-									} else if seriesType != "" {
-										if val, exists := result.Metric[seriesType]; exists {
-											labelValue = val
-										}
-									}
-									
-									if labelValue != "" {
-										if len(result.Value) >= 2 {
-											val := result.Value[1].(string)
-											originalVal, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
-											if err == nil {
-												floatVal := originalVal
-												// 应用单位转换
-												if initialUnit != "" && targetUnit != "" {
-													convertedVal, err := ConvertUnit(originalVal, initialUnit, targetUnit)
-													if err == nil {
-														floatVal = convertedVal
-														log.Printf("[FetchMetrics] Unit conversion for current value: %.2f %s -> %.2f %s",
-															originalVal, initialUnit, convertedVal, targetUnit)
-													}
-												}
-												
-												if actualPoints[labelValue] == nil {
-													actualPoints[labelValue] = make(map[int64]float64)
-												}
-												// 四舍五入到2位小数
-												floatVal = math.Round(floatVal*100) / 100
-												actualPoints[labelValue][currentTimestamp] = floatVal
-												log.Printf("[FetchMetrics] Added current value for %s: %.2f at %s",
-													labelValue, floatVal, currentTime.Format("2006-01-02 15:04:05"))
-											}
-										}
+					} else if seriesType != "" {
+						if val, exists := result.Metric[seriesType]; exists {
+							labelValue = val
+						}
+					}
+					
+					if labelValue != "" {
+						if len(result.Value) >= 2 {
+							val := result.Value[1].(string)
+							originalVal, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+							if err == nil {
+								floatVal := originalVal
+								// 应用单位转换
+								if initialUnit != "" && targetUnit != "" {
+									convertedVal, err := ConvertUnit(originalVal, initialUnit, targetUnit)
+									if err == nil {
+										floatVal = convertedVal
+										log.Printf("[FetchMetrics] Unit conversion for current value: %.2f %s -> %.2f %s",
+											originalVal, initialUnit, convertedVal, targetUnit)
 									}
 								}
+								
+								if actualPoints[labelValue] == nil {
+									actualPoints[labelValue] = make(map[int64]float64)
+								}
+								// 四舍五入到2位小数
+								floatVal = math.Round(floatVal*100) / 100
+								actualPoints[labelValue][currentTimestamp] = floatVal
+								log.Printf("[FetchMetrics] Added current value for %s: %.2f at %s",
+									labelValue, floatVal, currentTime.Format("2006-01-02 15:04:05"))
 							}
 						}
 					}
@@ -997,7 +925,7 @@ func FetchMetrics(baseURL, query string, start, end time.Time, step time.Duratio
 		// 为每个时间戳创建数据点
 		for _, ts := range labelTimeStamps {
 			value := actualPoints[labelValue][ts]
-			timeStr := time.Unix(ts, 0).Format("01/02 15:04")
+			timeStr := time.Unix(ts, 0).In(loc).Format("01/02 15:04")
 
 			dp := models.DataPoint{
 				Time:     timeStr,
@@ -1039,7 +967,7 @@ type LatestMetric struct {
 // FetchLatestMetrics 从VictoriaMetrics获取指标的最新值
 //
 // 参数说明:
-//   - baseURL: 指标源的基础URL
+//   - source: 指标数据源，包含地址和认证配置
 //   - query: PromQL查询语句
 //   - seriesType: 默认的标签名称，用于从指标中提取序列名称（当customLabel为空时使用）
 //   - customLabel: 自定义标签名称，用于从指标数据中提取对应的值作为标签
@@ -1047,53 +975,20 @@ type LatestMetric struct {
 // 返回值:
 //   - []LatestMetric: 每个时间序列的最新指标值列表
 //   - error: 错误信息
-func FetchLatestMetrics(baseURL, query, seriesType, customLabel, initialUnit, targetUnit string) ([]LatestMetric, error) {
+func FetchLatestMetrics(source models.MetricsSource, query, seriesType, customLabel, initialUnit, targetUnit string) ([]LatestMetric, error) {
 	log.Printf("[FetchLatestMetrics] ====== START ======")
 	log.Printf("[FetchLatestMetrics] Query: %s, SeriesType: %s, CustomLabel: %s, InitialUnit: %s, TargetUnit: %s",
 		query, seriesType, customLabel, initialUnit, targetUnit)
 
-	// 构建查询 URL - 使用 query 接口获取即时值
-	u, err := url.Parse(baseURL)
+	ds, err := datasource.New(source)
 	if err != nil {
 		return nil, err
 	}
-	u.Path = path.Join(u.Path, "/api/v1/query")
 
-	params := url.Values{}
-	params.Set("query", query)
-	u.RawQuery = params.Encode()
-
-	log.Printf("[FetchLatestMetrics] Requesting URL: %s", u.String())
-
-	// 发送 HTTP 请求
-	resp, err := http.Get(u.String())
+	// 使用即时查询获取最新值
+	vmResp, err := ds.InstantQuery(context.Background(), query, time.Time{})
 	if err != nil {
-		log.Printf("[FetchLatestMetrics] ERROR: HTTP request failed: %v", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	log.Printf("[FetchLatestMetrics] Response status code: %d", resp.StatusCode)
-
-	// 解析 JSON
-	var vmResp struct {
-		Status string `json:"status"`
-		Data   struct {
-			ResultType string `json:"resultType"`
-			Result     []struct {
-				Metric map[string]string `json:"metric"`
-				Value  []interface{}     `json:"value"` // [timestamp, value]
-			} `json:"result"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &vmResp); err != nil {
+		log.Printf("[FetchLatestMetrics] ERROR: query failed: %v", err)
 		return nil, err
 	}
 
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"fsvchart-notify/internal/database"
+	"fsvchart-notify/internal/models"
+)
+
+const metricsSourceColumns = `id, name, url, COALESCE(type, 'prometheus'), COALESCE(options, ''),
+	COALESCE(auth_type, 'none'), COALESCE(username, ''), COALESCE(password, ''), COALESCE(bearer_token, ''),
+	COALESCE(headers, ''), COALESCE(ca_cert, ''), COALESCE(client_cert, ''), COALESCE(client_key, ''),
+	COALESCE(insecure_skip_verify, 0)`
+
+// scanMetricsSource 读取一行数据源记录
+func scanMetricsSource(row interface{ Scan(...interface{}) error }) (models.MetricsSource, error) {
+	var ms models.MetricsSource
+	var options, headers string
+	var insecure int
+	err := row.Scan(&ms.ID, &ms.Name, &ms.URL, &ms.Type, &options, &ms.AuthType, &ms.Username, &ms.Password,
+		&ms.BearerToken, &headers, &ms.CACert, &ms.ClientCert, &ms.ClientKey, &insecure)
+	if err != nil {
+		return ms, err
+	}
+	ms.InsecureSkipVerify = insecure == 1
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &ms.Options); err != nil {
+			return ms, fmt.Errorf("数据源 %s 的查询选项格式无效: %w", ms.Name, err)
+		}
+	}
+	ms.Headers = map[string]string{}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &ms.Headers); err != nil {
+			return ms, fmt.Errorf("数据源 %s 的自定义请求头格式无效: %w", ms.Name, err)
+		}
+	}
+	return ms, nil
+}
+
+// GetMetricsSource 按 ID 读取数据源
+func GetMetricsSource(id int64) (models.MetricsSource, error) {
+	db := database.GetDB()
+	if db == nil {
+		return models.MetricsSource{}, fmt.Errorf("数据库不可用")
+	}
+	ms, err := scanMetricsSource(db.QueryRow("SELECT "+metricsSourceColumns+" FROM metrics_source WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return ms, fmt.Errorf("数据源 %d 不存在", id)
+	}
+	return ms, err
+}
+
+// GetAllMetricsSources 读取所有数据源
+func GetAllMetricsSources() ([]models.MetricsSource, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库不可用")
+	}
+	rows, err := db.Query("SELECT " + metricsSourceColumns + " FROM metrics_source")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.MetricsSource
+	for rows.Next() {
+		ms, err := scanMetricsSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ms)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fsvchart-notify/internal/database"
+	"fsvchart-notify/internal/models"
+)
+
+// sendRecordTimeLayout send_record.timestamp 的存储格式，统一使用 UTC 保证按时间范围过滤时可直接比较字符串
+const sendRecordTimeLayout = "2006-01-02 15:04:05"
+
+// legacySendRecordLimit 未指定分页参数时返回的最大记录数，与旧版本内存记录的上限一致
+const legacySendRecordLimit = 1000
+
+// SendRecordFilter 发送记录查询条件，零值字段表示不过滤
+type SendRecordFilter struct {
+	TaskID    int64
+	WebhookID int64
+	Status    string
+	Start     time.Time
+	End       time.Time
+	Page      int
+	PageSize  int
+}
+
+// AddSendRecord 写入一条发送记录
+// record 提供任务、WebHook、按钮等信息；err 为空时记录为成功并使用 successMsg，否则记录为失败
+// 飞书签名校验失败记录为 sign_error，便于与其他发送失败区分
+func AddSendRecord(webhookURL string, record models.SendRecord, successMsg string, err error) {
+	record.Timestamp = time.Now()
+	record.Webhook = webhookURL
+	record.Status = "success"
+	record.Message = successMsg
+	if err != nil {
+		record.Status = "error"
+		if errors.Is(err, ErrFeishuSignature) {
+			record.Status = "sign_error"
+		}
+		record.Message = fmt.Sprintf("发送失败: %v", err)
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Printf("[AddSendRecord] 数据库不可用，丢弃发送记录: %s", record.Message)
+		return
+	}
+	_, dbErr := db.Exec(`
+		INSERT INTO send_record (timestamp, status, message, webhook, task_name,
+			task_id, webhook_id, button_text, button_url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.Timestamp.UTC().Format(sendRecordTimeLayout), record.Status, record.Message, record.Webhook,
+		record.TaskName, record.TaskID, record.WebhookID, record.ButtonText, record.ButtonURL)
+	if dbErr != nil {
+		log.Printf("[AddSendRecord] 写入发送记录失败: %v", dbErr)
+	}
+}
+
+// QuerySendRecords 按条件查询发送记录，返回当前页记录和符合条件的总数
+// 未指定分页时返回最近的 1000 条记录，按时间正序排列，兼容旧版本接口
+func QuerySendRecords(filter SendRecordFilter) ([]models.SendRecord, int, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, 0, fmt.Errorf("数据库不可用")
+	}
+
+	var conditions []string
+	var args []interface{}
+	if filter.TaskID > 0 {
+		conditions = append(conditions, "task_id = ?")
+		args = append(args, filter.TaskID)
+	}
+	if filter.WebhookID > 0 {
+		conditions = append(conditions, "webhook_id = ?")
+		args = append(args, filter.WebhookID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.Start.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.Start.UTC().Format(sendRecordTimeLayout))
+	}
+	if !filter.End.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, filter.End.UTC().Format(sendRecordTimeLayout))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM send_record "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := legacySendRecordLimit, 0
+	if filter.Page > 0 {
+		limit = filter.PageSize
+		offset = (filter.Page - 1) * filter.PageSize
+	}
+	query := `
+		SELECT id, timestamp, status, COALESCE(message, ''), COALESCE(webhook, ''),
+		       COALESCE(task_id, 0), COALESCE(webhook_id, 0), COALESCE(task_name, ''),
+		       COALESCE(button_text, ''), COALESCE(button_url, '')
+		FROM send_record ` + where + `
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ? OFFSET ?`
+	rows, err := db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	records := []models.SendRecord{}
+	for rows.Next() {
+		var r models.SendRecord
+		var ts string
+		if err := rows.Scan(&r.ID, &ts, &r.Status, &r.Message, &r.Webhook,
+			&r.TaskID, &r.WebhookID, &r.TaskName, &r.ButtonText, &r.ButtonURL); err != nil {
+			return nil, 0, err
+		}
+		r.Timestamp = parseSendRecordTime(ts)
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// 旧接口按时间正序返回
+	if filter.Page == 0 {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	return records, total, nil
+}
+
+// parseSendRecordTime 解析 send_record.timestamp，兼容驱动写入的带时区格式
+func parseSendRecordTime(s string) time.Time {
+	if t, err := time.ParseInLocation(sendRecordTimeLayout, s, time.UTC); err == nil {
+		return t.Local()
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Local()
+		}
+	}
+	return time.Time{}
+}
+
+// PurgeSendRecords 删除早于 before 的发送记录，返回删除的条数
+func PurgeSendRecords(before time.Time) (int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("数据库不可用")
+	}
+	result, err := db.Exec("DELETE FROM send_record WHERE timestamp < ?", before.UTC().Format(sendRecordTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartSendRecordPurge 启动发送记录清理任务，每小时删除超过保留天数的记录，ctx 取消后退出
+// retentionDays <= 0 时不清理
+func StartSendRecordPurge(ctx context.Context, retentionDays int) {
+	if retentionDays <= 0 {
+		log.Printf("[SendRecordPurge] 未配置保留天数，发送记录将永久保留")
+		return
+	}
+
+	purge := func() {
+		before := time.Now().AddDate(0, 0, -retentionDays)
+		n, err := PurgeSendRecords(before)
+		if err != nil {
+			log.Printf("[SendRecordPurge] 清理发送记录失败: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("[SendRecordPurge] 已清理 %d 条 %d 天前的发送记录", n, retentionDays)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		purge()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purge()
+			}
+		}
+	}()
+	log.Printf("[SendRecordPurge] 发送记录保留 %d 天", retentionDays)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"fsvchart-notify/internal/models"
+)
+
+// 图表序列的统计项
+const (
+	StatMin  = "min"
+	StatMax  = "max"
+	StatAvg  = "avg"
+	StatLast = "last"
+	StatP95  = "p95"
+	StatSum  = "sum"
+)
+
+// statOrder 统计项的可选值，也是统计表格中列的顺序
+var statOrder = []string{StatMin, StatMax, StatAvg, StatLast, StatP95, StatSum}
+
+// statNames 统计项在表格中的列名
+var statNames = map[string]string{
+	StatMin:  "最小值",
+	StatMax:  "最大值",
+	StatAvg:  "平均值",
+	StatLast: "最新值",
+	StatP95:  "P95",
+	StatSum:  "总和",
+}
+
+// SeriesStats 单个序列的统计结果
+type SeriesStats struct {
+	Name   string
+	Values map[string]float64 // 统计项 -> 值
+}
+
+// ValidateStats 校验统计项列表
+func ValidateStats(stats []string) error {
+	seen := make(map[string]bool)
+	for _, s := range stats {
+		if _, ok := statNames[s]; !ok {
+			return fmt.Errorf("无效的统计项 %q，可选值: %s", s, strings.Join(statOrder, "、"))
+		}
+		if seen[s] {
+			return fmt.Errorf("统计项 %q 重复", s)
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+// ParseStats 解析数据库中保存的统计项 JSON，空字符串视为不显示统计
+func ParseStats(data string) ([]string, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var stats []string
+	if err := json.Unmarshal([]byte(data), &stats); err != nil {
+		return nil, fmt.Errorf("统计项配置格式无效: %w", err)
+	}
+	return stats, nil
+}
+
+// ComputeSeriesStats 按序列（DataPoint.Type）计算统计值，序列按名称排序
+// last 取时间最晚的数据点，p95 在排序后的值之间线性插值
+func ComputeSeriesStats(points []models.DataPoint, stats []string) []SeriesStats {
+	if len(stats) == 0 || len(points) == 0 {
+		return nil
+	}
+
+	seriesPoints := make(map[string][]models.DataPoint)
+	var names []string
+	for _, dp := range points {
+		if _, ok := seriesPoints[dp.Type]; !ok {
+			names = append(names, dp.Type)
+		}
+		seriesPoints[dp.Type] = append(seriesPoints[dp.Type], dp)
+	}
+	sort.Strings(names)
+
+	result := make([]SeriesStats, 0, len(names))
+	for _, name := range names {
+		pts := seriesPoints[name]
+		sort.SliceStable(pts, func(i, j int) bool { return pts[i].UnixTime < pts[j].UnixTime })
+
+		values := make([]float64, 0, len(pts))
+		sum := 0.0
+		for _, dp := range pts {
+			values = append(values, dp.Value)
+			sum += dp.Value
+		}
+		sort.Float64s(values)
+
+		s := SeriesStats{Name: name, Values: make(map[string]float64, len(stats))}
+		for _, stat := range stats {
+			switch stat {
+			case StatMin:
+				s.Values[stat] = values[0]
+			case StatMax:
+				s.Values[stat] = values[len(values)-1]
+			case StatAvg:
+				s.Values[stat] = sum / float64(len(values))
+			case StatLast:
+				s.Values[stat] = pts[len(pts)-1].Value
+			case StatP95:
+				s.Values[stat] = percentile(values, 0.95)
+			case StatSum:
+				s.Values[stat] = sum
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// percentile 计算已排序值的分位数，在相邻两个值之间线性插值
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// FeishuStatsTable 生成图表下方的序列统计表格组件，每个序列一行，每个统计项一列
+// 统计项按 min、max、avg、last、p95、sum 的顺序排列；未配置统计项或没有数据时返回 nil
+func FeishuStatsTable(points []models.DataPoint, stats []string, unit string) map[string]interface{} {
+	series := ComputeSeriesStats(points, stats)
+	if len(series) == 0 {
+		return nil
+	}
+
+	columns := []interface{}{FeishuTableColumn("series", "序列", "left")}
+	for _, stat := range statOrder {
+		if containsStat(stats, stat) {
+			columns = append(columns, FeishuTableColumn(stat, statNames[stat], "right"))
+		}
+	}
+
+	rows := make([]interface{}, 0, len(series))
+	for _, s := range series {
+		row := map[string]interface{}{"series": s.Name}
+		for stat, value := range s.Values {
+			row[stat] = FormatValue(value, unit)
+		}
+		rows = append(rows, row)
+	}
+	return FeishuTable(columns, rows)
+}
+
+// containsStat 判断统计项是否在列表中
+func containsStat(stats []string, stat string) bool {
+	for _, s := range stats {
+		if s == stat {
+			return true
+		}
+	}
+	return false
+}
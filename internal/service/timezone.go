@@ -0,0 +1,29 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoadTimezone 解析任务配置的 IANA 时区名称（如 "Asia/Shanghai"、"UTC"）
+// 名称为空时返回 nil，由调用方决定默认时区，保持未配置时区的任务行为不变
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// locationOr 返回 loc，loc 为空时返回 fallback
+func locationOr(loc, fallback *time.Location) *time.Location {
+	if loc == nil {
+		return fallback
+	}
+	return loc
+}
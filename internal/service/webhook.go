@@ -0,0 +1,78 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"fsvchart-notify/internal/database"
+	"fsvchart-notify/internal/models"
+)
+
+const webhookColumns = `w.id, COALESCE(w.name, ''), w.url, COALESCE(w.type, 'feishu'), COALESCE(w.secret, ''), COALESCE(w.options, '')`
+
+// scanWebhook 读取一行 WebHook 记录
+func scanWebhook(row interface{ Scan(...interface{}) error }) (models.FeishuWebhook, error) {
+	var wb models.FeishuWebhook
+	var options string
+	if err := row.Scan(&wb.ID, &wb.Name, &wb.URL, &wb.Type, &wb.Secret, &options); err != nil {
+		return wb, err
+	}
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &wb.Options); err != nil {
+			return wb, fmt.Errorf("WebHook %s 的渠道选项格式无效: %w", wb.Name, err)
+		}
+	}
+	return wb, nil
+}
+
+// queryWebhooks 执行查询并读取所有 WebHook 记录
+func queryWebhooks(query string, args ...interface{}) ([]models.FeishuWebhook, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库不可用")
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.FeishuWebhook
+	for rows.Next() {
+		wb, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, wb)
+	}
+	return list, rows.Err()
+}
+
+// GetWebhook 按 ID 读取 WebHook
+func GetWebhook(id int64) (models.FeishuWebhook, error) {
+	db := database.GetDB()
+	if db == nil {
+		return models.FeishuWebhook{}, fmt.Errorf("数据库不可用")
+	}
+	wb, err := scanWebhook(db.QueryRow("SELECT "+webhookColumns+" FROM feishu_webhook w WHERE w.id = ?", id))
+	if err == sql.ErrNoRows {
+		return wb, fmt.Errorf("WebHook %d 不存在", id)
+	}
+	return wb, err
+}
+
+// GetAllWebhooks 读取所有 WebHook
+func GetAllWebhooks() ([]models.FeishuWebhook, error) {
+	return queryWebhooks("SELECT " + webhookColumns + " FROM feishu_webhook w")
+}
+
+// GetTaskWebhooks 读取任务绑定的所有 WebHook
+func GetTaskWebhooks(taskID int64) ([]models.FeishuWebhook, error) {
+	return queryWebhooks(`
+		SELECT `+webhookColumns+`
+		FROM feishu_webhook w
+		JOIN push_task_webhook ptw ON w.id = ptw.webhook_id
+		WHERE ptw.task_id = ?
+	`, taskID)
+}